@@ -41,11 +41,7 @@ const (
 	clientDescriptionFmt = "info about the %s"
 )
 
-var (
-	cfg map[string]string
-
-	clients = make(map[string]interface{})
-)
+var cfg map[string]string
 
 // AcceptanceTestDriver driver for the test.
 type AcceptanceTestDriver struct {
@@ -123,7 +119,7 @@ func TestAcceptance(t *testing.T) {
 		Config: sdk.ConfigurableAcceptanceTestDriverConfig{
 			Connector:         Connector,
 			SourceConfig:      cfg,
-			DestinationConfig: nil,
+			DestinationConfig: cfg,
 			BeforeTest: func(t *testing.T) {
 				cli := retryablehttp.NewClient()
 				cli.Logger = sdk.Logger(ctx)
@@ -165,8 +161,6 @@ func addResource(ctx context.Context, cli *retryablehttp.Client, cfg, params map
 		return nil, errors.New("response is empty")
 	}
 
-	clients[resource[models.KeyID].(string)] = nil
-
 	return resource, nil
 }
 
@@ -190,19 +184,44 @@ func isEmpty(ctx context.Context, cli *retryablehttp.Client, cfg map[string]stri
 	return nil
 }
 
+// clearResources deletes all objects of the resource, which are created by the test,
+// both by WriteToSource and by the destination, because the account has no objects before the test.
 func clearResources(ctx context.Context, cli *retryablehttp.Client, cfg map[string]string) error {
-	var err error
+	for {
+		var resource models.ResourceResponse
 
-	for k := range clients {
-		_, err = makeRequest(ctx, cli, http.MethodDelete, k, cfg, nil)
+		data, err := makeRequest(ctx, cli, http.MethodGet, "", cfg, map[string]string{"limit": "100"})
 		if err != nil {
-			return fmt.Errorf("make delete request: %w", err)
+			return fmt.Errorf("make get request: %w", err)
 		}
-	}
 
-	clients = make(map[string]interface{})
+		err = json.Unmarshal(data, &resource)
+		if err != nil {
+			return fmt.Errorf("unmarshal response: %w", err)
+		}
 
-	return nil
+		if len(resource.Data) == 0 {
+			return nil
+		}
+
+		for _, object := range resource.Data {
+			id, _ := object[models.KeyID].(string)
+
+			data, err = makeRequest(ctx, cli, http.MethodDelete, id, cfg, nil)
+			if err != nil {
+				return fmt.Errorf("make delete request: %w", err)
+			}
+
+			// the objects, which are not deleted, would be listed again
+			var deleted struct {
+				Deleted bool `json:"deleted"`
+			}
+
+			if err = json.Unmarshal(data, &deleted); err != nil || !deleted.Deleted {
+				return fmt.Errorf("delete %s: %s", id, data)
+			}
+		}
+	}
 }
 
 func makeRequest(ctx context.Context, cli *retryablehttp.Client, method, path string, cfg, params map[string]string,
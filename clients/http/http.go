@@ -17,25 +17,47 @@ package http
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strings"
 
 	"github.com/conduitio-labs/conduit-connector-stripe/models"
 	sdk "github.com/conduitio/conduit-connector-sdk"
 	"github.com/hashicorp/go-retryablehttp"
 )
 
+const (
+	headerContentType = "Content-Type"
+	contentTypeForm   = "application/x-www-form-urlencoded"
+)
+
+// A ResponseError represents an error response from Stripe.
+type ResponseError struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+// Error returns the message of the error.
+func (e *ResponseError) Error() string {
+	return e.Message
+}
+
 // A Client represents retryable http client.
 type Client struct {
 	httpClient *retryablehttp.Client
 }
 
-// NewClient returns a new retryable http client.
-func NewClient(ctx context.Context) Client {
+// NewClient returns a new retryable http client, which makes up to requestsPerSecond requests per second.
+func NewClient(ctx context.Context, requestsPerSecond int) Client {
 	retryClient := retryablehttp.NewClient()
 	retryClient.Logger = sdk.Logger(ctx)
+	retryClient.HTTPClient.Transport = &limitedTransport{
+		next:    retryClient.HTTPClient.Transport,
+		limiter: newLimiter(requestsPerSecond),
+	}
 
 	return Client{
 		httpClient: retryClient,
@@ -44,7 +66,24 @@ func NewClient(ctx context.Context) Client {
 
 // Get makes a GET http-request to the URL with headers.
 func (cli Client) Get(url string, header ...map[string]string) ([]byte, error) {
-	req, err := retryablehttp.NewRequest(http.MethodGet, url, nil)
+	return cli.do(http.MethodGet, url, nil, header...)
+}
+
+// Post makes a POST http-request to the URL with form-encoded parameters and headers.
+func (cli Client) Post(url string, params url.Values, header ...map[string]string) ([]byte, error) {
+	formHeader := map[string]string{headerContentType: contentTypeForm}
+
+	return cli.do(http.MethodPost, url, strings.NewReader(params.Encode()), append(header, formHeader)...)
+}
+
+// Delete makes a DELETE http-request to the URL with headers.
+func (cli Client) Delete(url string, header ...map[string]string) ([]byte, error) {
+	return cli.do(http.MethodDelete, url, nil, header...)
+}
+
+// do makes an http-request with the method to the URL with the body and headers.
+func (cli Client) do(method, url string, body io.Reader, header ...map[string]string) ([]byte, error) {
+	req, err := retryablehttp.NewRequest(method, url, body)
 	if err != nil {
 		return nil, fmt.Errorf("create new request: %w", err)
 	}
@@ -76,7 +115,11 @@ func (cli Client) Get(url string, header ...map[string]string) ([]byte, error) {
 		}
 
 		if errResp.Error.Message != "" {
-			return nil, errors.New(errResp.Error.Message)
+			return nil, &ResponseError{
+				StatusCode: resp.StatusCode,
+				Code:       errResp.Error.Code,
+				Message:    errResp.Error.Message,
+			}
 		}
 
 		return nil, fmt.Errorf(models.UnexpectedErrorWithStatusCode, resp.StatusCode)
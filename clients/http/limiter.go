@@ -0,0 +1,159 @@
+// Copyright © 2022 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/conduitio-labs/conduit-connector-stripe/models"
+	"golang.org/x/time/rate"
+)
+
+const (
+	headerRetryAfter = "Retry-After"
+
+	// defaultRetryAfter is the pause after a rate limited request without the `Retry-After` header.
+	defaultRetryAfter = time.Second
+	// minRequestsPerSecond is the lowest rate the limiter slows down to.
+	minRequestsPerSecond = 1
+	// speedUpSteps is the number of successful requests to restore the rate after it was halved.
+	speedUpSteps = 100
+)
+
+// A limiter represents a token bucket rate limiter, which slows down when Stripe rate limits requests,
+// and speeds up again with every successful request.
+type limiter struct {
+	limiter *rate.Limiter
+	// max is the configured rate.
+	max rate.Limit
+
+	mu sync.Mutex
+	// pausedUntil is the time until which requests are not allowed after a rate limited request.
+	pausedUntil time.Time
+}
+
+// newLimiter returns a new limiter, which allows up to requestsPerSecond requests per second.
+func newLimiter(requestsPerSecond int) *limiter {
+	return &limiter{
+		limiter: rate.NewLimiter(rate.Limit(requestsPerSecond), requestsPerSecond),
+		max:     rate.Limit(requestsPerSecond),
+	}
+}
+
+// Wait blocks until a request is allowed, or the context is canceled.
+func (l *limiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	pause := time.Until(l.pausedUntil)
+	l.mu.Unlock()
+
+	if pause > 0 {
+		timer := time.NewTimer(pause)
+		defer timer.Stop()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	return l.limiter.Wait(ctx)
+}
+
+// SlowDown pauses the requests for the duration and halves the rate.
+func (l *limiter) SlowDown(pause time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if until := time.Now().Add(pause); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+
+	l.limiter.SetLimit(rate.Limit(math.Max(float64(l.limiter.Limit()/2), minRequestsPerSecond)))
+}
+
+// SpeedUp increases the rate after a successful request, until it reaches the configured rate.
+func (l *limiter) SpeedUp() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.limiter.Limit() >= l.max {
+		return
+	}
+
+	l.limiter.SetLimit(min(l.limiter.Limit()+l.max/speedUpSteps, l.max))
+}
+
+// A limitedTransport represents an http.RoundTripper, which waits for the limiter before each request.
+type limitedTransport struct {
+	next    http.RoundTripper
+	limiter *limiter
+}
+
+// RoundTrip waits for the limiter, executes the request, and slows the limiter down
+// if the response is Stripe's rate limit error.
+func (t *limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.limiter.Wait(req.Context()); err != nil {
+		return nil, err
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.limiter.SpeedUp()
+
+		return resp, nil
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if err != nil {
+		return nil, err
+	}
+
+	// restore the body for the http client
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+
+	// Stripe also responds with 429 to lock timeouts, which are not related to the rate
+	errResp := models.ErrorResponse{}
+	if json.Unmarshal(data, &errResp) == nil && errResp.Error.Code == models.ErrorCodeRateLimit {
+		t.limiter.SlowDown(parseRetryAfter(resp.Header.Get(headerRetryAfter)))
+	}
+
+	return resp, nil
+}
+
+// parseRetryAfter returns the duration of the `Retry-After` header in seconds,
+// or the default duration if the header is missing or invalid.
+func parseRetryAfter(value string) time.Duration {
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds <= 0 {
+		return defaultRetryAfter
+	}
+
+	return time.Duration(seconds) * time.Second
+}
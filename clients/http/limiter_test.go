@@ -0,0 +1,115 @@
+// Copyright © 2022 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"golang.org/x/time/rate"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration
+	}{
+		{name: "seconds", value: "3", want: 3 * time.Second},
+		{name: "empty", value: "", want: defaultRetryAfter},
+		{name: "invalid", value: "soon", want: defaultRetryAfter},
+		{name: "zero", value: "0", want: defaultRetryAfter},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			is := is.New(t)
+
+			is.Equal(parseRetryAfter(tt.value), tt.want)
+		})
+	}
+}
+
+func TestLimiter_SlowDownSpeedUp(t *testing.T) {
+	t.Parallel()
+	is := is.New(t)
+
+	l := newLimiter(4)
+
+	l.SlowDown(0)
+	is.Equal(l.limiter.Limit(), rate.Limit(2))
+
+	l.SlowDown(0)
+	l.SlowDown(0)
+	is.Equal(l.limiter.Limit(), rate.Limit(minRequestsPerSecond))
+
+	for range speedUpSteps {
+		l.SpeedUp()
+	}
+	is.Equal(l.limiter.Limit(), rate.Limit(4))
+}
+
+func TestLimitedTransport_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		body      string
+		wantLimit rate.Limit
+	}{
+		{
+			name:      "rate_limit",
+			body:      `{"error":{"code":"rate_limit","message":"Too many requests"}}`,
+			wantLimit: 5,
+		},
+		{
+			name:      "lock_timeout",
+			body:      `{"error":{"code":"lock_timeout","message":"Could not acquire lock"}}`,
+			wantLimit: 10,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			is := is.New(t)
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set(headerRetryAfter, "1")
+				w.WriteHeader(http.StatusTooManyRequests)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			transport := &limitedTransport{next: http.DefaultTransport, limiter: newLimiter(10)}
+
+			req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+			is.NoErr(err)
+
+			resp, err := transport.RoundTrip(req)
+			is.NoErr(err)
+			defer resp.Body.Close()
+
+			is.Equal(resp.StatusCode, http.StatusTooManyRequests)
+			is.Equal(transport.limiter.limiter.Limit(), tt.wantLimit)
+		})
+	}
+}
@@ -17,32 +17,640 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/conduitio-labs/conduit-connector-stripe/models"
+	"github.com/conduitio-labs/conduit-connector-stripe/models/openapi"
+	"github.com/conduitio-labs/conduit-connector-stripe/models/resources"
+)
+
+const (
+	// AllResources is the wildcard value of the resource name to read all supported resources.
+	AllResources = "*"
+
+	// CDCModePoll is the CDC mode which polls Stripe events.
+	CDCModePoll = "poll"
+	// CDCModeWebhook is the CDC mode which receives Stripe events with a webhook listener.
+	CDCModeWebhook = "webhook"
+	// CDCModeNone is the CDC mode which receives no Stripe events, so only the snapshot is read.
+	CDCModeNone = "none"
+
+	// CDCStrategyEvents is the CDC strategy which reads the events of the resources.
+	CDCStrategyEvents = "events"
+	// CDCStrategyPoll is the CDC strategy which polls the lists of the resources for their new and changed objects.
+	CDCStrategyPoll = "poll"
+
+	// OnRetentionGapFail is the action which fails the source,
+	// when the events since the position are no longer retained by Stripe.
+	OnRetentionGapFail = "fail"
+	// OnRetentionGapSnapshot is the action which makes a new snapshot of the resources,
+	// when the events since the position are no longer retained by Stripe.
+	OnRetentionGapSnapshot = "snapshot"
+
+	// NestedListsNone is the way the truncated lists nested in the objects are read as is.
+	NestedListsNone = "none"
+	// NestedListsInline is the way the truncated lists nested in the objects are replaced with all of their objects.
+	NestedListsInline = "inline"
+	// NestedListsRecords is the way the objects of the lists nested in the objects are read as records of their own.
+	NestedListsRecords = "records"
+
+	// NestedObjectsStructured is the way the objects nested in the structured payloads are read as they are.
+	NestedObjectsStructured = "structured"
+	// NestedObjectsJSON is the way the objects nested in the structured payloads are marshaled to JSON strings.
+	NestedObjectsJSON = "json"
+
+	// AllConnectedAccounts is the value of the connected accounts to read all connected accounts of the platform.
+	AllConnectedAccounts = "all"
+	// connectedAccountPrefix is the prefix of the Stripe connected account identifier.
+	connectedAccountPrefix = "acct_"
+)
+
+// liveModeKeyPrefixes are the prefixes of the secret and restricted Stripe keys in live mode.
+var liveModeKeyPrefixes = []string{"sk_live_", "rk_live_"}
+
+var (
+	errNoResourceName              = errors.New("one of resourceName or resourceNames must be set")
+	errAmbiguousResourceName       = errors.New("only one of resourceName or resourceNames can be set")
+	errNoWebhookSecret             = errors.New("webhookSecret must be set in the webhook cdc mode")
+	errAllConnectedAccounts        = errors.New("connectedAccounts cannot contain other accounts along with \"all\"")
+	errWebhookWithAccounts         = errors.New("the webhook cdc mode is not supported with connectedAccounts")
+	errSchemaNotStructured         = errors.New("resourceSchema requires structuredPayload")
+	errSchemaWithExpand            = errors.New("resourceSchema is not supported with expand")
+	errSchemaNestedObjects         = errors.New("resourceSchema requires nestedObjects \"json\"")
+	errNestedJSONNotStructured     = errors.New("nestedObjects \"json\" requires structuredPayload")
+	errEmptyExpand                 = errors.New("the expansion has no paths")
+	errRetentionGapSnapshot        = errors.New("onRetentionGap \"snapshot\" requires snapshot")
+	errEmptyCreatedRange           = errors.New("snapshotCreatedAfter must be before snapshotCreatedBefore")
+	errNegativePollOverlap         = errors.New("pollOverlap cannot be negative")
+	errNegativePollLookback        = errors.New("pollLookback cannot be negative")
+	errPollLookbackWithoutStrategy = errors.New("pollLookback requires cdcStrategy \"poll\"")
+	errNegativeReconcile           = errors.New("reconcileInterval cannot be negative")
+	errNoReconcileStateFile        = errors.New("reconcileInterval requires reconcileStateFile")
+	errSameStateFiles              = errors.New("pollStateFile and reconcileStateFile must be different files")
+	errPollStrategyWebhook         = errors.New("cdcStrategy \"poll\" is not supported in the webhook cdc mode")
+	errNoneWithoutSnapshot         = errors.New("cdcMode \"none\" requires snapshot")
+	errPollStrategyNone            = errors.New("cdcStrategy \"poll\" is not supported in the none cdc mode")
+	errReconcileNone               = errors.New("reconcileInterval is not supported in the none cdc mode")
+	errEventWithResources          = errors.New("the event resource cannot be combined with other resources")
+	errEventTypesWithoutEvent      = errors.New("eventTypes requires the event resource")
+	errSearchWithFilters           = errors.New("searchQuery cannot be combined with snapshotCreatedAfter, " +
+		"snapshotCreatedBefore, listFilters or snapshotWorkers, the conditions must be a part of the query")
+
+	// apiVersionRegexp matches Stripe API versions, such as `2022-11-15` or `2024-09-30.acacia`.
+	apiVersionRegexp = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}(\.[a-z]+)?$`)
 )
 
 type Config struct {
 	// SecretKey is the configuration name for Stripe secret key.
 	SecretKey string `json:"secretKey" validate:"required"`
 	// ResourceName is the configuration name for Stripe resource.
-	ResourceName string `json:"resourceName" validate:"required"`
+	ResourceName string `json:"resourceName"`
+	// ResourceNames is the configuration name for the list of Stripe resources, or `*` to read all of them.
+	ResourceNames []string `json:"resourceNames"`
 	// BatchSize is the configuration name for the number of objects in the batch returned from Stripe.
 	BatchSize int `json:"batchSize" default:"10" validate:"gt=0,lt=100001"`
 	// Snapshot is the configuration name for the Snapshot field.
 	Snapshot bool `json:"snapshot" default:"true"`
+	// SnapshotWorkers is the configuration name for the number of partitions of the time of creation of the objects
+	// the snapshot reads concurrently, the resources are read sequentially if it is 1.
+	SnapshotWorkers int `json:"snapshotWorkers" default:"1" validate:"gt=0,lt=101"`
+	// SnapshotCreatedAfter is the configuration name for the time the objects of the snapshot are created at or after,
+	// which is an RFC 3339 time, or a duration before the start of the snapshot, such as `90d` or `36h`.
+	SnapshotCreatedAfter string `json:"snapshotCreatedAfter"`
+	// SnapshotCreatedBefore is the configuration name for the time the objects of the snapshot are created before,
+	// which is an RFC 3339 time, or a duration before the start of the snapshot, such as `90d` or `36h`.
+	SnapshotCreatedBefore string `json:"snapshotCreatedBefore"`
+	// ListFilters is the configuration name for the filters of the lists of the resources in the snapshot,
+	// such as `status` or `customer`, which must be supported by every configured resource.
+	ListFilters map[string]string `json:"listFilters"`
+	// EventTypes is the configuration name for the list of the patterns of the types of the events
+	// the event resource reads, such as `invoice.*` or `*.failed`, all events are read if it is empty.
+	EventTypes []string `json:"eventTypes"`
+	// SearchQuery is the configuration name for the query of the Stripe Search API,
+	// such as `metadata['tenant']:'acme'`, the snapshot reads only the objects matching it if it is set.
+	SearchQuery string `json:"searchQuery"`
+	// CDCMode is the configuration name for the way the CDC iterator receives Stripe events,
+	// either by polling them, or with a webhook listener, or none to read only the snapshot.
+	CDCMode string `json:"cdcMode" default:"poll" validate:"inclusion=poll|webhook|none"`
+	// CDCStrategy is the configuration name for the way the CDC iterator detects the changes of the resources,
+	// either by reading their events, or by polling their lists and comparing the fingerprints of their objects.
+	CDCStrategy string `json:"cdcStrategy" default:"events" validate:"inclusion=events|poll"`
+	// PollOverlap is the configuration name for the overlap of the windows of the time of creation,
+	// the lists of the resources without events are polled with in the CDC mode, to receive the objects,
+	// which appear in the lists later than the objects created after them.
+	PollOverlap time.Duration `json:"pollOverlap" default:"10m"`
+	// PollLookback is the configuration name for the window of the time of creation before the current time,
+	// the lists of the resources are polled with the poll cdc strategy, to detect the changes of their objects,
+	// the whole lists are polled if it is zero.
+	PollLookback time.Duration `json:"pollLookback"`
+	// PollStateFile is the configuration name for the path of the local file, the identifiers and fingerprints
+	// of the seen polled objects are stored in, so they are not read again after a restart.
+	PollStateFile string `json:"pollStateFile"`
+	// ReconcileInterval is the configuration name for the time between the reconciliations of a resource,
+	// which list all of its objects, and emit the deletes of the objects missing from the list since the previous one,
+	// the reconciliation is disabled if it is zero.
+	ReconcileInterval time.Duration `json:"reconcileInterval"`
+	// ReconcileStateFile is the configuration name for the path of the local file,
+	// the identifiers of the objects listed by the reconciliations are stored in.
+	ReconcileStateFile string `json:"reconcileStateFile"`
+	// WebhookAddress is the configuration name for the address the webhook listener binds to.
+	WebhookAddress string `json:"webhookAddress" default:":8080"`
+	// WebhookSecret is the configuration name for the signing secret of the Stripe webhook endpoint.
+	WebhookSecret string `json:"webhookSecret"`
+	// WebhookTolerance is the configuration name for the maximum difference
+	// between the time of the webhook signature and the current time.
+	WebhookTolerance time.Duration `json:"webhookTolerance" default:"5m"`
+	// ConnectedAccounts is the configuration name for the list of Stripe connected accounts to read on behalf of,
+	// or `all` to read all connected accounts of the platform.
+	ConnectedAccounts []string `json:"connectedAccounts"`
+	// APIVersion is the configuration name for the Stripe API version sent with every request,
+	// the default API version of the account is used if it is empty.
+	APIVersion string `json:"apiVersion"`
+	// BaseURL is the configuration name for the base URL of the Stripe API,
+	// which can point to a local Stripe stand-in, such as stripe-mock, or a proxy.
+	BaseURL string `json:"baseURL" default:"https://api.stripe.com"`
+	// Expand is the configuration name for the comma-separated paths of the related objects to expand
+	// in the payloads of a resource, where the key is the resource name, such as `expand.charge` set to `customer,invoice`,
+	// because the expandable fields differ between the resources.
+	Expand map[string]string `json:"expand"`
+	// StructuredPayload is the configuration name for the flag whether the payloads are structured data,
+	// instead of raw JSON bytes.
+	StructuredPayload bool `json:"structuredPayload" default:"false"`
+	// NestedLists is the configuration name for the way the lists nested in the objects are read,
+	// which Stripe truncates to their first page, such as the lines of the invoices:
+	// `none` reads them as is, `inline` replaces them with all of their objects,
+	// and `records` reads their objects as records of their own.
+	NestedLists string `json:"nestedLists" default:"none" validate:"inclusion=none|inline|records"`
+	// NestedObjects is the configuration name for the way the objects nested in the structured payloads are read,
+	// such as the addresses of the customers: `structured` reads them as they are,
+	// and `json` marshals them to JSON strings, the objects of the arrays are kept.
+	NestedObjects string `json:"nestedObjects" default:"structured" validate:"inclusion=structured|json"`
+	// ResourceSchema is the configuration name for the flag whether the records contain the key and payload schemas
+	// of the resource, which are derived from the Stripe OpenAPI spec.
+	ResourceSchema bool `json:"resourceSchema" default:"false"`
+	// RateLimit is the configuration name for the maximum number of requests per second to Stripe,
+	// if it is zero, the limit is selected by the mode of the secret key: 100 in live mode, and 25 in test mode.
+	RateLimit int `json:"rateLimit" default:"0" validate:"gt=-1"`
+	// OnRetentionGap is the configuration name for the action when the events since the position
+	// are no longer retained by Stripe, which keeps them for 30 days:
+	// `fail` fails the source, and `snapshot` makes a new snapshot of the resources.
+	OnRetentionGap string `json:"onRetentionGap" default:"fail" validate:"inclusion=fail|snapshot"`
 }
 
 // Validate executes manual validations beyond what is defined in struct tags.
 func (c *Config) Validate() error {
 	// c.SecretKey has required validation handled in struct tag
 
-	// c.ResourceName required validation is handled in stuct tag
-	// handling "resource_name" validation
-	_, ok := models.ResourcesMap[c.ResourceName]
-	if !ok {
-		return fmt.Errorf("%q wrong resource name", c.ResourceName)
+	// handling "resource_name" and "resource_names" validation
+	switch {
+	case c.ResourceName == "" && len(c.ResourceNames) == 0:
+		return errNoResourceName
+	case c.ResourceName != "" && len(c.ResourceNames) != 0:
+		return errAmbiguousResourceName
+	}
+
+	for _, resourceName := range c.Resources() {
+		_, ok := models.ResourcesMap[resourceName]
+		if !ok {
+			return fmt.Errorf("%q wrong resource name", resourceName)
+		}
+	}
+
+	// c.CDCMode inclusion validation is handled in struct tag
+	if c.CDCMode == CDCModeWebhook && c.WebhookSecret == "" {
+		return errNoWebhookSecret
+	}
+
+	// c.NestedObjects inclusion validation is handled in struct tag
+	if c.NestedObjects == NestedObjectsJSON && !c.StructuredPayload {
+		return errNestedJSONNotStructured
+	}
+
+	if err := c.validateResourceSchema(); err != nil {
+		return err
+	}
+
+	if err := c.validateExpand(); err != nil {
+		return err
+	}
+
+	if err := c.validateSnapshotFilters(); err != nil {
+		return err
+	}
+
+	if err := c.validateSearchQuery(); err != nil {
+		return err
+	}
+
+	if err := c.validateEventTypes(); err != nil {
+		return err
+	}
+
+	if err := c.validateSnapshotOnly(); err != nil {
+		return err
+	}
+
+	if err := c.validatePolling(); err != nil {
+		return err
+	}
+
+	if err := c.validateReconciliation(); err != nil {
+		return err
+	}
+
+	// c.OnRetentionGap inclusion validation is handled in struct tag
+	if c.OnRetentionGap == OnRetentionGapSnapshot && !c.Snapshot {
+		return errRetentionGapSnapshot
+	}
+
+	if err := validateAPIVersion(c.APIVersion); err != nil {
+		return err
+	}
+
+	if err := validateBaseURL(c.BaseURL); err != nil {
+		return err
+	}
+
+	return c.validateConnectedAccounts()
+}
+
+// RequestsPerSecond returns the maximum number of requests per second to Stripe.
+func (c *Config) RequestsPerSecond() int {
+	return requestsPerSecond(c.SecretKey, c.RateLimit)
+}
+
+// requestsPerSecond returns the rate limit if it is set,
+// or the number of requests per second Stripe allows in the mode of the secret key.
+func requestsPerSecond(secretKey string, rateLimit int) int {
+	if rateLimit > 0 {
+		return rateLimit
+	}
+
+	for _, prefix := range liveModeKeyPrefixes {
+		if strings.HasPrefix(secretKey, prefix) {
+			return models.LiveModeRequestsPerSecond
+		}
+	}
+
+	return models.TestModeRequestsPerSecond
+}
+
+// SnapshotCreatedRange returns the bounds of the time of creation of the objects of the snapshot.
+func (c *Config) SnapshotCreatedRange() (after, before models.TimeBound, err error) {
+	after, err = models.ParseTimeBound(c.SnapshotCreatedAfter)
+	if err != nil {
+		return after, before, fmt.Errorf("parse snapshotCreatedAfter: %w", err)
+	}
+
+	before, err = models.ParseTimeBound(c.SnapshotCreatedBefore)
+	if err != nil {
+		return after, before, fmt.Errorf("parse snapshotCreatedBefore: %w", err)
+	}
+
+	return after, before, nil
+}
+
+// validateSnapshotFilters validates the creation time range and the list filters of the snapshot,
+// which must be supported by all configured resources, and contain the filters required by their lists.
+func (c *Config) validateSnapshotFilters() error {
+	after, before, err := c.SnapshotCreatedRange()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	if !after.IsZero() && !before.IsZero() && after.Unix(now) >= before.Unix(now) {
+		return errEmptyCreatedRange
+	}
+
+	for _, resourceName := range c.Resources() {
+		if _, ok := models.ResourcesWithoutCreatedFilter[resourceName]; ok && (!after.IsZero() || !before.IsZero()) {
+			return fmt.Errorf("the %s resource cannot be filtered by the time of creation", resourceName)
+		}
+
+		for filter := range c.ListFilters {
+			if !slices.Contains(models.ListFiltersMap[resourceName], filter) {
+				return fmt.Errorf("the %s resource cannot be filtered by %q", resourceName, filter)
+			}
+		}
+
+		for _, filter := range models.RequiredListFiltersMap[resourceName] {
+			if _, ok := c.ListFilters[filter]; !ok {
+				return fmt.Errorf("the %s resource requires the listFilters.%s filter", resourceName, filter)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateExpand validates the expansions, which must belong to the configured resources and contain paths.
+func (c *Config) validateExpand() error {
+	for resourceName := range c.Expand {
+		if !slices.Contains(c.Resources(), resourceName) {
+			return fmt.Errorf("expand.%s: the %s resource is not configured", resourceName, resourceName)
+		}
+
+		if len(c.ExpandPaths(resourceName)) == 0 {
+			return fmt.Errorf("expand.%s: %w", resourceName, errEmptyExpand)
+		}
+	}
+
+	return nil
+}
+
+// ExpandPaths returns the paths of the related objects to expand in the payloads of the resource, if any.
+func (c *Config) ExpandPaths(resourceName string) []string {
+	var paths []string
+
+	for _, path := range strings.Split(c.Expand[resourceName], ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			paths = append(paths, path)
+		}
+	}
+
+	return paths
+}
+
+// validateResourceSchema validates that the schemas of all configured resources are in the Stripe OpenAPI spec,
+// if the resource schemas are enabled. The schemas describe the structured payloads, where the expandable fields
+// are identifiers, and the nested objects are JSON strings, because the Avro serde cannot encode them as they are.
+func (c *Config) validateResourceSchema() error {
+	switch {
+	case !c.ResourceSchema:
+		return nil
+	case !c.StructuredPayload:
+		return errSchemaNotStructured
+	case c.NestedObjects != NestedObjectsJSON:
+		return errSchemaNestedObjects
+	case len(c.Expand) > 0:
+		return errSchemaWithExpand
+	}
+
+	for _, resourceName := range c.Resources() {
+		if _, err := openapi.AvroSchema(resourceName); err != nil {
+			return fmt.Errorf("resourceSchema: %w", err)
+		}
+
+		if c.NestedLists != NestedListsRecords {
+			continue
+		}
+
+		for _, list := range models.NestedListsMap[resourceName] {
+			if _, err := openapi.AvroSchema(resourceName + "." + list.Field); err != nil {
+				return fmt.Errorf("resourceSchema: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateSearchQuery validates that all configured resources can be searched, if the search query is set.
+func (c *Config) validateSearchQuery() error {
+	if c.SearchQuery == "" {
+		return nil
+	}
+
+	if c.SnapshotCreatedAfter != "" || c.SnapshotCreatedBefore != "" || len(c.ListFilters) > 0 || c.SnapshotWorkers > 1 {
+		return errSearchWithFilters
+	}
+
+	for _, resourceName := range c.Resources() {
+		if _, ok := models.SearchResources[resourceName]; !ok {
+			return fmt.Errorf("the %s resource cannot be searched", resourceName)
+		}
+	}
+
+	return nil
+}
+
+// validateEventTypes validates that the event resource is the only configured resource,
+// and that the patterns of the event types are set only with it, and are valid.
+func (c *Config) validateEventTypes() error {
+	resourceNames := c.Resources()
+
+	if !slices.Contains(resourceNames, resources.EventResource) {
+		if len(c.EventTypes) > 0 {
+			return errEventTypesWithoutEvent
+		}
+
+		return nil
+	}
+
+	if len(resourceNames) > 1 {
+		return errEventWithResources
+	}
+
+	for _, pattern := range c.EventTypes {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("event type pattern %q: %w", pattern, err)
+		}
+	}
+
+	return nil
+}
+
+// validateSnapshotOnly validates the none cdc mode, which reads only the snapshot, so it requires the snapshot,
+// and the resources without events, which cannot be polled either, are supported only in this mode.
+func (c *Config) validateSnapshotOnly() error {
+	if c.CDCMode == CDCModeNone {
+		switch {
+		case !c.Snapshot:
+			return errNoneWithoutSnapshot
+		case c.CDCStrategy == CDCStrategyPoll:
+			return errPollStrategyNone
+		case c.ReconcileInterval > 0:
+			return errReconcileNone
+		}
+
+		return nil
+	}
+
+	for _, resourceName := range c.Resources() {
+		if _, ok := models.SnapshotOnlyResources[resourceName]; ok {
+			return fmt.Errorf("the %s resource has no events, it is supported only in the none cdc mode", resourceName)
+		}
+	}
+
+	return nil
+}
+
+// validatePolling validates the polling of the resources without events,
+// which are not supported in the webhook cdc mode, because the webhook listener waits for the events.
+func (c *Config) validatePolling() error {
+	switch {
+	case c.PollOverlap < 0:
+		return errNegativePollOverlap
+	case c.PollLookback < 0:
+		return errNegativePollLookback
+	case c.PollLookback > 0 && c.CDCStrategy != CDCStrategyPoll:
+		return errPollLookbackWithoutStrategy
+	}
+
+	// c.CDCStrategy inclusion validation is handled in struct tag
+	if c.CDCStrategy == CDCStrategyPoll {
+		return c.validatePollStrategy()
+	}
+
+	if c.CDCMode != CDCModeWebhook {
+		return nil
+	}
+
+	for _, resourceName := range c.Resources() {
+		if _, ok := models.PolledResources[resourceName]; ok {
+			return fmt.Errorf("the %s resource has no events, it is not supported in the webhook cdc mode", resourceName)
+		}
 	}
 
 	return nil
 }
+
+// validatePollStrategy validates the resources polled with the poll cdc strategy,
+// which are listed on their own, so the child resources listed under their parent objects are not supported,
+// as well as the events, which are read by the event resource as they are.
+func (c *Config) validatePollStrategy() error {
+	if c.CDCMode == CDCModeWebhook {
+		return errPollStrategyWebhook
+	}
+
+	for _, resourceName := range c.Resources() {
+		_, child := models.ChildResourcesMap[resourceName]
+		if child || resourceName == resources.EventResource {
+			return fmt.Errorf("the %s resource is not supported by the poll cdc strategy", resourceName)
+		}
+	}
+
+	return nil
+}
+
+// validateReconciliation validates the reconciliation of the resources, which lists them on their own,
+// so the child resources listed under their parent objects are not supported, as well as the events,
+// which are missing from the list once they are older than their retention.
+func (c *Config) validateReconciliation() error {
+	switch {
+	case c.ReconcileInterval < 0:
+		return errNegativeReconcile
+	case c.ReconcileInterval == 0:
+		return nil
+	case c.ReconcileStateFile == "":
+		return errNoReconcileStateFile
+	case c.PollStateFile != "" && filepath.Clean(c.PollStateFile) == filepath.Clean(c.ReconcileStateFile):
+		// the entries of both files have the same keys, but other contents
+		return errSameStateFiles
+	}
+
+	for _, resourceName := range c.Resources() {
+		_, child := models.ChildResourcesMap[resourceName]
+		if child || resourceName == resources.EventResource {
+			return fmt.Errorf("the %s resource is not supported by the reconciliation", resourceName)
+		}
+	}
+
+	return nil
+}
+
+// validateBaseURL validates that the base URL, if it is set, is an absolute http or https URL.
+func validateBaseURL(baseURL string) error {
+	if baseURL == "" {
+		return nil
+	}
+
+	u, err := url.Parse(baseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q wrong base url, it must be an absolute http or https url", baseURL)
+	}
+
+	return nil
+}
+
+// validateAPIVersion validates the format of the API version, if it is set.
+func validateAPIVersion(apiVersion string) error {
+	if apiVersion != "" && !apiVersionRegexp.MatchString(apiVersion) {
+		return fmt.Errorf("%q wrong api version", apiVersion)
+	}
+
+	return nil
+}
+
+// validateConnectedAccounts validates the identifiers of the connected accounts.
+func (c *Config) validateConnectedAccounts() error {
+	if len(c.ConnectedAccounts) == 0 {
+		return nil
+	}
+
+	if c.CDCMode == CDCModeWebhook {
+		return errWebhookWithAccounts
+	}
+
+	for _, account := range c.ConnectedAccounts {
+		switch {
+		case account == AllConnectedAccounts:
+			if len(c.ConnectedAccounts) > 1 {
+				return errAllConnectedAccounts
+			}
+		case !strings.HasPrefix(account, connectedAccountPrefix):
+			return fmt.Errorf("%q wrong connected account", account)
+		}
+	}
+
+	return nil
+}
+
+// Resources returns the names of the resources to read without duplicates,
+// with the `*` wildcard resolved to all supported resources.
+func (c *Config) Resources() []string {
+	resourceNames := c.ResourceNames
+	if c.ResourceName != "" {
+		resourceNames = []string{c.ResourceName}
+	}
+
+	result := make([]string, 0, len(resourceNames))
+	seen := make(map[string]struct{}, len(resourceNames))
+
+	for _, resourceName := range resourceNames {
+		if resourceName == AllResources {
+			return allResources()
+		}
+
+		if _, ok := seen[resourceName]; ok {
+			continue
+		}
+
+		seen[resourceName] = struct{}{}
+		result = append(result, resourceName)
+	}
+
+	return result
+}
+
+// allResources returns the names of all supported resources in alphabetical order,
+// except for the child resources, which are read under every parent object, so they must be configured explicitly,
+// the resources whose lists require filters, such as the financial account of the Treasury resources,
+// and the event resource, which cannot be combined with other resources.
+func allResources() []string {
+	result := make([]string, 0, len(models.ResourcesMap))
+	for resourceName := range models.ResourcesMap {
+		_, child := models.ChildResourcesMap[resourceName]
+		_, required := models.RequiredListFiltersMap[resourceName]
+
+		if child || required || resourceName == resources.EventResource {
+			continue
+		}
+
+		result = append(result, resourceName)
+	}
+
+	sort.Strings(result)
+
+	return result
+}
@@ -16,8 +16,11 @@ package config
 
 import (
 	"fmt"
+	"path"
 	"testing"
+	"time"
 
+	"github.com/conduitio-labs/conduit-connector-stripe/models"
 	"github.com/conduitio-labs/conduit-connector-stripe/models/resources"
 	"github.com/matryer/is"
 )
@@ -54,6 +57,609 @@ func TestValidateConfig(t *testing.T) {
 			},
 			wantErr: fmt.Errorf("\"invalid_resource\" wrong resource name"),
 		},
+		{
+			name: "success_valid_resource_names",
+			in: &Config{
+				SecretKey:     testSecretKey,
+				ResourceNames: []string{resources.CustomerResource, resources.InvoiceResource},
+				BatchSize:     10,
+				Snapshot:      true,
+			},
+			wantErr: nil,
+		},
+		{
+			name: "success_all_resource_names",
+			in: &Config{
+				SecretKey:     testSecretKey,
+				ResourceNames: []string{AllResources},
+				BatchSize:     10,
+				Snapshot:      true,
+			},
+			wantErr: nil,
+		},
+		{
+			name: "failure_invalid_resource_names",
+			in: &Config{
+				SecretKey:     testSecretKey,
+				ResourceNames: []string{resources.CustomerResource, "invalid_resource"},
+				BatchSize:     10,
+				Snapshot:      true,
+			},
+			wantErr: fmt.Errorf("\"invalid_resource\" wrong resource name"),
+		},
+		{
+			name: "failure_no_resource_name",
+			in: &Config{
+				SecretKey: testSecretKey,
+				BatchSize: 10,
+				Snapshot:  true,
+			},
+			wantErr: errNoResourceName,
+		},
+		{
+			name: "failure_both_resource_name_and_names",
+			in: &Config{
+				SecretKey:     testSecretKey,
+				ResourceName:  resources.CustomerResource,
+				ResourceNames: []string{resources.InvoiceResource},
+				BatchSize:     10,
+				Snapshot:      true,
+			},
+			wantErr: errAmbiguousResourceName,
+		},
+		{
+			name: "failure_webhook_mode_without_secret",
+			in: &Config{
+				SecretKey:    testSecretKey,
+				ResourceName: resources.CustomerResource,
+				BatchSize:    10,
+				Snapshot:     true,
+				CDCMode:      CDCModeWebhook,
+			},
+			wantErr: errNoWebhookSecret,
+		},
+		{
+			name: "success_connected_accounts",
+			in: &Config{
+				SecretKey:         testSecretKey,
+				ResourceName:      resources.CustomerResource,
+				BatchSize:         10,
+				ConnectedAccounts: []string{"acct_1032D82eZvKYlo2C", "acct_1LajCFJit566F2Yt"},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "failure_invalid_connected_account",
+			in: &Config{
+				SecretKey:         testSecretKey,
+				ResourceName:      resources.CustomerResource,
+				BatchSize:         10,
+				ConnectedAccounts: []string{"cus_LY6gsj"},
+			},
+			wantErr: fmt.Errorf("\"cus_LY6gsj\" wrong connected account"),
+		},
+		{
+			name: "failure_all_connected_accounts_with_others",
+			in: &Config{
+				SecretKey:         testSecretKey,
+				ResourceName:      resources.CustomerResource,
+				BatchSize:         10,
+				ConnectedAccounts: []string{AllConnectedAccounts, "acct_1032D82eZvKYlo2C"},
+			},
+			wantErr: errAllConnectedAccounts,
+		},
+		{
+			name: "failure_connected_accounts_in_webhook_mode",
+			in: &Config{
+				SecretKey:         testSecretKey,
+				ResourceName:      resources.CustomerResource,
+				BatchSize:         10,
+				CDCMode:           CDCModeWebhook,
+				WebhookSecret:     "whsec_test",
+				ConnectedAccounts: []string{AllConnectedAccounts},
+			},
+			wantErr: errWebhookWithAccounts,
+		},
+		{
+			name: "failure_resource_schema_without_structured_payload",
+			in: &Config{
+				SecretKey:      testSecretKey,
+				ResourceName:   resources.CustomerResource,
+				BatchSize:      10,
+				ResourceSchema: true,
+			},
+			wantErr: errSchemaNotStructured,
+		},
+		{
+			name: "failure_resource_schema_with_expand",
+			in: &Config{
+				SecretKey:         testSecretKey,
+				ResourceName:      resources.CustomerResource,
+				BatchSize:         10,
+				Expand:            map[string]string{resources.CustomerResource: "default_source"},
+				StructuredPayload: true,
+				NestedObjects:     NestedObjectsJSON,
+				ResourceSchema:    true,
+			},
+			wantErr: errSchemaWithExpand,
+		},
+		{
+			name: "failure_resource_schema_with_structured_nested_objects",
+			in: &Config{
+				SecretKey:         testSecretKey,
+				ResourceName:      resources.CustomerResource,
+				BatchSize:         10,
+				StructuredPayload: true,
+				NestedObjects:     NestedObjectsStructured,
+				ResourceSchema:    true,
+			},
+			wantErr: errSchemaNestedObjects,
+		},
+		{
+			name: "failure_nested_objects_json_without_structured_payload",
+			in: &Config{
+				SecretKey:     testSecretKey,
+				ResourceName:  resources.CustomerResource,
+				BatchSize:     10,
+				NestedObjects: NestedObjectsJSON,
+			},
+			wantErr: errNestedJSONNotStructured,
+		},
+		{
+			name: "success_resource_schema",
+			in: &Config{
+				SecretKey:         testSecretKey,
+				ResourceNames:     []string{resources.InvoiceResource, resources.CustomerSourceResource},
+				BatchSize:         10,
+				StructuredPayload: true,
+				NestedObjects:     NestedObjectsJSON,
+				NestedLists:       NestedListsRecords,
+				ResourceSchema:    true,
+			},
+			wantErr: nil,
+		},
+		{
+			name: "failure_resource_schema_without_schema",
+			in: &Config{
+				SecretKey:         testSecretKey,
+				ResourceName:      resources.OrderResource,
+				BatchSize:         10,
+				StructuredPayload: true,
+				NestedObjects:     NestedObjectsJSON,
+				ResourceSchema:    true,
+			},
+			wantErr: fmt.Errorf("resourceSchema: resource order: no schema"),
+		},
+		{
+			name: "success_expand",
+			in: &Config{
+				SecretKey:     testSecretKey,
+				ResourceNames: []string{resources.CustomerResource, resources.ChargeResource},
+				BatchSize:     10,
+				Expand:        map[string]string{resources.ChargeResource: "customer, invoice"},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "failure_expand_of_other_resource",
+			in: &Config{
+				SecretKey:    testSecretKey,
+				ResourceName: resources.CustomerResource,
+				BatchSize:    10,
+				Expand:       map[string]string{resources.ChargeResource: "customer"},
+			},
+			wantErr: fmt.Errorf("expand.charge: the charge resource is not configured"),
+		},
+		{
+			name: "failure_expand_without_paths",
+			in: &Config{
+				SecretKey:    testSecretKey,
+				ResourceName: resources.CustomerResource,
+				BatchSize:    10,
+				Expand:       map[string]string{resources.CustomerResource: " , "},
+			},
+			wantErr: fmt.Errorf("expand.customer: %w", errEmptyExpand),
+		},
+		{
+			name: "success_snapshot_on_retention_gap",
+			in: &Config{
+				SecretKey:      testSecretKey,
+				ResourceName:   resources.CustomerResource,
+				BatchSize:      10,
+				Snapshot:       true,
+				OnRetentionGap: OnRetentionGapSnapshot,
+			},
+			wantErr: nil,
+		},
+		{
+			name: "failure_snapshot_on_retention_gap_without_snapshot",
+			in: &Config{
+				SecretKey:      testSecretKey,
+				ResourceName:   resources.CustomerResource,
+				BatchSize:      10,
+				OnRetentionGap: OnRetentionGapSnapshot,
+			},
+			wantErr: errRetentionGapSnapshot,
+		},
+		{
+			name: "success_api_version",
+			in: &Config{
+				SecretKey:    testSecretKey,
+				ResourceName: resources.CustomerResource,
+				BatchSize:    10,
+				APIVersion:   "2024-09-30.acacia",
+			},
+			wantErr: nil,
+		},
+		{
+			name: "failure_invalid_api_version",
+			in: &Config{
+				SecretKey:    testSecretKey,
+				ResourceName: resources.CustomerResource,
+				BatchSize:    10,
+				APIVersion:   "latest",
+			},
+			wantErr: fmt.Errorf("\"latest\" wrong api version"),
+		},
+		{
+			name: "success_base_url",
+			in: &Config{
+				SecretKey:    testSecretKey,
+				ResourceName: resources.CustomerResource,
+				BatchSize:    10,
+				BaseURL:      "http://localhost:12111",
+			},
+			wantErr: nil,
+		},
+		{
+			name: "failure_relative_base_url",
+			in: &Config{
+				SecretKey:    testSecretKey,
+				ResourceName: resources.CustomerResource,
+				BatchSize:    10,
+				BaseURL:      "localhost:12111",
+			},
+			wantErr: fmt.Errorf("\"localhost:12111\" wrong base url, it must be an absolute http or https url"),
+		},
+		{
+			name: "failure_not_http_base_url",
+			in: &Config{
+				SecretKey:    testSecretKey,
+				ResourceName: resources.CustomerResource,
+				BatchSize:    10,
+				BaseURL:      "ftp://localhost:12111",
+			},
+			wantErr: fmt.Errorf("\"ftp://localhost:12111\" wrong base url, it must be an absolute http or https url"),
+		},
+		{
+			name: "success_snapshot_filters",
+			in: &Config{
+				SecretKey:             testSecretKey,
+				ResourceNames:         []string{resources.InvoiceResource, resources.SubscriptionResource},
+				BatchSize:             10,
+				SnapshotCreatedAfter:  "90d",
+				SnapshotCreatedBefore: "2030-01-01T00:00:00Z",
+				ListFilters:           map[string]string{"status": "paid", "customer": "cus_LY6gsj"},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "failure_invalid_snapshot_created_after",
+			in: &Config{
+				SecretKey:            testSecretKey,
+				ResourceName:         resources.InvoiceResource,
+				BatchSize:            10,
+				SnapshotCreatedAfter: "yesterday",
+			},
+			wantErr: fmt.Errorf("parse snapshotCreatedAfter: \"yesterday\" is neither an RFC 3339 time nor a duration"),
+		},
+		{
+			name: "failure_empty_snapshot_created_range",
+			in: &Config{
+				SecretKey:             testSecretKey,
+				ResourceName:          resources.InvoiceResource,
+				BatchSize:             10,
+				SnapshotCreatedAfter:  "30d",
+				SnapshotCreatedBefore: "90d",
+			},
+			wantErr: errEmptyCreatedRange,
+		},
+		{
+			name: "failure_snapshot_created_range_not_supported",
+			in: &Config{
+				SecretKey:            testSecretKey,
+				ResourceName:         resources.QuoteResource,
+				BatchSize:            10,
+				SnapshotCreatedAfter: "90d",
+			},
+			wantErr: fmt.Errorf("the quote resource cannot be filtered by the time of creation"),
+		},
+		{
+			name: "failure_list_filter_not_supported",
+			in: &Config{
+				SecretKey:     testSecretKey,
+				ResourceNames: []string{resources.InvoiceResource, resources.CustomerResource},
+				BatchSize:     10,
+				ListFilters:   map[string]string{"status": "paid"},
+			},
+			wantErr: fmt.Errorf("the customer resource cannot be filtered by \"status\""),
+		},
+		{
+			name: "success_required_list_filter",
+			in: &Config{
+				SecretKey: testSecretKey,
+				ResourceNames: []string{
+					resources.TreasuryTransactionResource, resources.TreasuryInboundTransferResource,
+				},
+				BatchSize:   10,
+				ListFilters: map[string]string{"financial_account": "fa_1LajCFJit566F2Yt"},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "failure_required_list_filter",
+			in: &Config{
+				SecretKey:    testSecretKey,
+				ResourceName: resources.TreasuryOutboundPaymentResource,
+				BatchSize:    10,
+			},
+			wantErr: fmt.Errorf("the treasury.outbound_payment resource requires the listFilters.financial_account filter"),
+		},
+		{
+			name: "success_search_query",
+			in: &Config{
+				SecretKey:     testSecretKey,
+				ResourceNames: []string{resources.CustomerResource, resources.InvoiceResource},
+				BatchSize:     10,
+				SearchQuery:   "metadata['tenant']:'acme'",
+			},
+			wantErr: nil,
+		},
+		{
+			name: "failure_search_query_not_supported",
+			in: &Config{
+				SecretKey:    testSecretKey,
+				ResourceName: resources.RefundResource,
+				BatchSize:    10,
+				SearchQuery:  "metadata['tenant']:'acme'",
+			},
+			wantErr: fmt.Errorf("the refund resource cannot be searched"),
+		},
+		{
+			name: "failure_search_query_with_list_filters",
+			in: &Config{
+				SecretKey:    testSecretKey,
+				ResourceName: resources.InvoiceResource,
+				BatchSize:    10,
+				SearchQuery:  "metadata['tenant']:'acme'",
+				ListFilters:  map[string]string{"status": "paid"},
+			},
+			wantErr: errSearchWithFilters,
+		},
+		{
+			name: "success_event_types",
+			in: &Config{
+				SecretKey:    testSecretKey,
+				ResourceName: resources.EventResource,
+				BatchSize:    10,
+				EventTypes:   []string{"invoice.*", "*.failed"},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "failure_event_with_resources",
+			in: &Config{
+				SecretKey:     testSecretKey,
+				ResourceNames: []string{resources.EventResource, resources.CustomerResource},
+				BatchSize:     10,
+			},
+			wantErr: errEventWithResources,
+		},
+		{
+			name: "failure_event_types_without_event",
+			in: &Config{
+				SecretKey:    testSecretKey,
+				ResourceName: resources.CustomerResource,
+				BatchSize:    10,
+				EventTypes:   []string{"customer.*"},
+			},
+			wantErr: errEventTypesWithoutEvent,
+		},
+		{
+			name: "failure_event_types_pattern",
+			in: &Config{
+				SecretKey:    testSecretKey,
+				ResourceName: resources.EventResource,
+				BatchSize:    10,
+				EventTypes:   []string{"invoice.[paid"},
+			},
+			wantErr: fmt.Errorf("event type pattern \"invoice.[paid\": %w", path.ErrBadPattern),
+		},
+		{
+			name: "failure_negative_poll_overlap",
+			in: &Config{
+				SecretKey:    testSecretKey,
+				ResourceName: resources.BalanceTransactionResource,
+				BatchSize:    10,
+				PollOverlap:  -time.Minute,
+			},
+			wantErr: errNegativePollOverlap,
+		},
+		{
+			name: "failure_negative_poll_lookback",
+			in: &Config{
+				SecretKey:    testSecretKey,
+				ResourceName: resources.CustomerResource,
+				BatchSize:    10,
+				CDCStrategy:  CDCStrategyPoll,
+				PollLookback: -time.Hour,
+			},
+			wantErr: errNegativePollLookback,
+		},
+		{
+			name: "failure_poll_lookback_without_poll_strategy",
+			in: &Config{
+				SecretKey:    testSecretKey,
+				ResourceName: resources.CustomerResource,
+				BatchSize:    10,
+				PollLookback: time.Hour,
+			},
+			wantErr: errPollLookbackWithoutStrategy,
+		},
+		{
+			name: "failure_polled_resource_in_webhook_mode",
+			in: &Config{
+				SecretKey:     testSecretKey,
+				ResourceName:  resources.BalanceTransactionResource,
+				BatchSize:     10,
+				CDCMode:       CDCModeWebhook,
+				WebhookSecret: "whsec_test",
+			},
+			wantErr: fmt.Errorf("the balance_transaction resource has no events, it is not supported in the webhook cdc mode"),
+		},
+		{
+			name: "success_poll_strategy",
+			in: &Config{
+				SecretKey:     testSecretKey,
+				ResourceNames: []string{resources.ReviewResource, resources.ReportingReportTypeResource},
+				BatchSize:     10,
+				CDCStrategy:   CDCStrategyPoll,
+			},
+			wantErr: nil,
+		},
+		{
+			name: "failure_poll_strategy_in_webhook_mode",
+			in: &Config{
+				SecretKey:     testSecretKey,
+				ResourceName:  resources.ReviewResource,
+				BatchSize:     10,
+				CDCMode:       CDCModeWebhook,
+				CDCStrategy:   CDCStrategyPoll,
+				WebhookSecret: "whsec_test",
+			},
+			wantErr: errPollStrategyWebhook,
+		},
+		{
+			name: "failure_poll_strategy_child_resource",
+			in: &Config{
+				SecretKey:    testSecretKey,
+				ResourceName: resources.TaxIDResource,
+				BatchSize:    10,
+				CDCStrategy:  CDCStrategyPoll,
+			},
+			wantErr: fmt.Errorf("the tax_id resource is not supported by the poll cdc strategy"),
+		},
+		{
+			name: "success_snapshot_only_resource",
+			in: &Config{
+				SecretKey:    testSecretKey,
+				ResourceName: resources.CustomerBalanceTransactionResource,
+				BatchSize:    10,
+				Snapshot:     true,
+				CDCMode:      CDCModeNone,
+			},
+		},
+		{
+			name: "failure_snapshot_only_resource_with_cdc",
+			in: &Config{
+				SecretKey:    testSecretKey,
+				ResourceName: resources.CustomerBalanceTransactionResource,
+				BatchSize:    10,
+				Snapshot:     true,
+				CDCMode:      CDCModePoll,
+			},
+			wantErr: fmt.Errorf("the customer_balance_transaction resource has no events, " +
+				"it is supported only in the none cdc mode"),
+		},
+		{
+			name: "failure_none_cdc_mode_without_snapshot",
+			in: &Config{
+				SecretKey:    testSecretKey,
+				ResourceName: resources.CustomerResource,
+				BatchSize:    10,
+				CDCMode:      CDCModeNone,
+			},
+			wantErr: errNoneWithoutSnapshot,
+		},
+		{
+			name: "failure_none_cdc_mode_poll_strategy",
+			in: &Config{
+				SecretKey:    testSecretKey,
+				ResourceName: resources.CustomerResource,
+				BatchSize:    10,
+				Snapshot:     true,
+				CDCMode:      CDCModeNone,
+				CDCStrategy:  CDCStrategyPoll,
+			},
+			wantErr: errPollStrategyNone,
+		},
+		{
+			name: "failure_none_cdc_mode_reconciliation",
+			in: &Config{
+				SecretKey:          testSecretKey,
+				ResourceName:       resources.PaymentMethodResource,
+				BatchSize:          10,
+				Snapshot:           true,
+				CDCMode:            CDCModeNone,
+				ReconcileInterval:  24 * time.Hour,
+				ReconcileStateFile: "stripe-reconcile.json",
+			},
+			wantErr: errReconcileNone,
+		},
+		{
+			name: "success_reconciliation",
+			in: &Config{
+				SecretKey:          testSecretKey,
+				ResourceName:       resources.PaymentMethodResource,
+				BatchSize:          10,
+				ReconcileInterval:  24 * time.Hour,
+				ReconcileStateFile: "stripe-reconcile.json",
+			},
+			wantErr: nil,
+		},
+		{
+			name: "failure_reconciliation_without_state_file",
+			in: &Config{
+				SecretKey:         testSecretKey,
+				ResourceName:      resources.PaymentMethodResource,
+				BatchSize:         10,
+				ReconcileInterval: 24 * time.Hour,
+			},
+			wantErr: errNoReconcileStateFile,
+		},
+		{
+			name: "failure_same_poll_and_reconcile_state_files",
+			in: &Config{
+				SecretKey:          testSecretKey,
+				ResourceName:       resources.CustomerResource,
+				BatchSize:          10,
+				PollStateFile:      "/var/lib/conduit/stripe.json",
+				ReconcileInterval:  24 * time.Hour,
+				ReconcileStateFile: "/var/lib/conduit/./stripe.json",
+			},
+			wantErr: errSameStateFiles,
+		},
+		{
+			name: "failure_negative_reconcile_interval",
+			in: &Config{
+				SecretKey:         testSecretKey,
+				ResourceName:      resources.PaymentMethodResource,
+				BatchSize:         10,
+				ReconcileInterval: -time.Hour,
+			},
+			wantErr: errNegativeReconcile,
+		},
+		{
+			name: "failure_reconciliation_event_resource",
+			in: &Config{
+				SecretKey:          testSecretKey,
+				ResourceName:       resources.EventResource,
+				BatchSize:          10,
+				ReconcileInterval:  24 * time.Hour,
+				ReconcileStateFile: "stripe-reconcile.json",
+			},
+			wantErr: fmt.Errorf("the event resource is not supported by the reconciliation"),
+		},
 	}
 
 	for _, tt := range tests {
@@ -71,3 +677,80 @@ func TestValidateConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestConfig_Resources(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		in   *Config
+		want []string
+	}{
+		{
+			name: "resource_name",
+			in:   &Config{ResourceName: resources.CustomerResource},
+			want: []string{resources.CustomerResource},
+		},
+		{
+			name: "resource_names_without_duplicates",
+			in: &Config{ResourceNames: []string{
+				resources.InvoiceResource, resources.CustomerResource, resources.InvoiceResource,
+			}},
+			want: []string{resources.InvoiceResource, resources.CustomerResource},
+		},
+		{
+			name: "all_resources",
+			in:   &Config{ResourceNames: []string{resources.CustomerResource, AllResources}},
+			want: allResources(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			is := is.New(t)
+
+			is.Equal(tt.in.Resources(), tt.want)
+		})
+	}
+}
+
+func TestConfig_RequestsPerSecond(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		in   *Config
+		want int
+	}{
+		{
+			name: "test_mode_key",
+			in:   &Config{SecretKey: testSecretKey},
+			want: models.TestModeRequestsPerSecond,
+		},
+		{
+			name: "live_mode_secret_key",
+			in:   &Config{SecretKey: "sk_live_123456789"},
+			want: models.LiveModeRequestsPerSecond,
+		},
+		{
+			name: "live_mode_restricted_key",
+			in:   &Config{SecretKey: "rk_live_123456789"},
+			want: models.LiveModeRequestsPerSecond,
+		},
+		{
+			name: "rate_limit",
+			in:   &Config{SecretKey: "sk_live_123456789", RateLimit: 10},
+			want: 10,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			is := is.New(t)
+
+			is.Equal(tt.in.RequestsPerSecond(), tt.want)
+		})
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright © 2022 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:generate paramgen -output=paramgen_dest.go DestinationConfig
+
+package config
+
+import (
+	"fmt"
+
+	"github.com/conduitio-labs/conduit-connector-stripe/models"
+	"github.com/conduitio-labs/conduit-connector-stripe/models/resources"
+)
+
+type DestinationConfig struct {
+	// SecretKey is the configuration name for Stripe secret key.
+	SecretKey string `json:"secretKey" validate:"required"`
+	// ResourceName is the configuration name for Stripe resource.
+	ResourceName string `json:"resourceName" validate:"required"`
+	// APIVersion is the configuration name for the Stripe API version sent with every request,
+	// the default API version of the account is used if it is empty.
+	APIVersion string `json:"apiVersion"`
+	// BaseURL is the configuration name for the base URL of the Stripe API,
+	// which can point to a local Stripe stand-in, such as stripe-mock, or a proxy.
+	BaseURL string `json:"baseURL" default:"https://api.stripe.com"`
+	// RateLimit is the configuration name for the maximum number of requests per second to Stripe,
+	// if it is zero, the limit is selected by the mode of the secret key: 100 in live mode, and 25 in test mode.
+	RateLimit int `json:"rateLimit" default:"0" validate:"gt=-1"`
+}
+
+// Validate executes manual validations beyond what is defined in struct tags.
+func (c *DestinationConfig) Validate() error {
+	_, ok := models.ResourcesMap[c.ResourceName]
+	if !ok {
+		return fmt.Errorf("%q wrong resource name", c.ResourceName)
+	}
+
+	// the objects of the child resources are written under their parent objects, which the records do not identify,
+	// and the events cannot be written
+	if _, ok = models.ChildResourcesMap[c.ResourceName]; ok || c.ResourceName == resources.EventResource {
+		return fmt.Errorf("the %s resource is not supported by the destination", c.ResourceName)
+	}
+
+	if err := validateAPIVersion(c.APIVersion); err != nil {
+		return err
+	}
+
+	return validateBaseURL(c.BaseURL)
+}
+
+// RequestsPerSecond returns the maximum number of requests per second to Stripe.
+func (c *DestinationConfig) RequestsPerSecond() int {
+	return requestsPerSecond(c.SecretKey, c.RateLimit)
+}
+
+// Config returns a Config to initialize a Stripe client with.
+func (c *DestinationConfig) Config() Config {
+	return Config{
+		SecretKey:    c.SecretKey,
+		ResourceName: c.ResourceName,
+		APIVersion:   c.APIVersion,
+		BaseURL:      c.BaseURL,
+		RateLimit:    c.RateLimit,
+	}
+}
@@ -8,14 +8,53 @@ import (
 )
 
 const (
-	ConfigBatchSize    = "batchSize"
-	ConfigResourceName = "resourceName"
-	ConfigSecretKey    = "secretKey"
-	ConfigSnapshot     = "snapshot"
+	ConfigApiVersion            = "apiVersion"
+	ConfigBaseURL               = "baseURL"
+	ConfigBatchSize             = "batchSize"
+	ConfigCdcMode               = "cdcMode"
+	ConfigCdcStrategy           = "cdcStrategy"
+	ConfigConnectedAccounts     = "connectedAccounts"
+	ConfigEventTypes            = "eventTypes"
+	ConfigExpand                = "expand.*"
+	ConfigListFilters           = "listFilters.*"
+	ConfigNestedLists           = "nestedLists"
+	ConfigNestedObjects         = "nestedObjects"
+	ConfigOnRetentionGap        = "onRetentionGap"
+	ConfigPollLookback          = "pollLookback"
+	ConfigPollOverlap           = "pollOverlap"
+	ConfigPollStateFile         = "pollStateFile"
+	ConfigRateLimit             = "rateLimit"
+	ConfigReconcileInterval     = "reconcileInterval"
+	ConfigReconcileStateFile    = "reconcileStateFile"
+	ConfigResourceName          = "resourceName"
+	ConfigResourceNames         = "resourceNames"
+	ConfigResourceSchema        = "resourceSchema"
+	ConfigSearchQuery           = "searchQuery"
+	ConfigSecretKey             = "secretKey"
+	ConfigSnapshot              = "snapshot"
+	ConfigSnapshotCreatedAfter  = "snapshotCreatedAfter"
+	ConfigSnapshotCreatedBefore = "snapshotCreatedBefore"
+	ConfigSnapshotWorkers       = "snapshotWorkers"
+	ConfigStructuredPayload     = "structuredPayload"
+	ConfigWebhookAddress        = "webhookAddress"
+	ConfigWebhookSecret         = "webhookSecret"
+	ConfigWebhookTolerance      = "webhookTolerance"
 )
 
 func (Config) Parameters() map[string]config.Parameter {
 	return map[string]config.Parameter{
+		ConfigApiVersion: {
+			Default:     "",
+			Description: "APIVersion is the configuration name for the Stripe API version sent with every request,\nthe default API version of the account is used if it is empty.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
+		ConfigBaseURL: {
+			Default:     "https://api.stripe.com",
+			Description: "BaseURL is the configuration name for the base URL of the Stripe API,\nwhich can point to a local Stripe stand-in, such as stripe-mock, or a proxy.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
 		ConfigBatchSize: {
 			Default:     "10",
 			Description: "BatchSize is the configuration name for the number of objects in the batch returned from Stripe.",
@@ -25,14 +64,132 @@ func (Config) Parameters() map[string]config.Parameter {
 				config.ValidationLessThan{V: 100001},
 			},
 		},
-		ConfigResourceName: {
+		ConfigCdcMode: {
+			Default:     "poll",
+			Description: "CDCMode is the configuration name for the way the CDC iterator receives Stripe events,\neither by polling them, or with a webhook listener, or none to read only the snapshot.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{
+				config.ValidationInclusion{List: []string{"poll", "webhook", "none"}},
+			},
+		},
+		ConfigCdcStrategy: {
+			Default:     "events",
+			Description: "CDCStrategy is the configuration name for the way the CDC iterator detects the changes of the resources,\neither by reading their events, or by polling their lists and comparing the fingerprints of their objects.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{
+				config.ValidationInclusion{List: []string{"events", "poll"}},
+			},
+		},
+		ConfigConnectedAccounts: {
 			Default:     "",
-			Description: "ResourceName is the configuration name for Stripe resource.",
+			Description: "ConnectedAccounts is the configuration name for the list of Stripe connected accounts to read on behalf of,\nor `all` to read all connected accounts of the platform.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
+		ConfigEventTypes: {
+			Default:     "",
+			Description: "EventTypes is the configuration name for the list of the patterns of the types of the events\nthe event resource reads, such as `invoice.*` or `*.failed`, all events are read if it is empty.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
+		ConfigExpand: {
+			Default:     "",
+			Description: "Expand is the configuration name for the comma-separated paths of the related objects to expand\nin the payloads of a resource, where the key is the resource name, such as `expand.charge` set to `customer,invoice`,\nbecause the expandable fields differ between the resources.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
+		ConfigListFilters: {
+			Default:     "",
+			Description: "ListFilters is the configuration name for the filters of the lists of the resources in the snapshot,\nsuch as `status` or `customer`, which must be supported by every configured resource.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
+		ConfigNestedLists: {
+			Default:     "none",
+			Description: "NestedLists is the configuration name for the way the lists nested in the objects are read,\nwhich Stripe truncates to their first page, such as the lines of the invoices:\n`none` reads them as is, `inline` replaces them with all of their objects,\nand `records` reads their objects as records of their own.",
 			Type:        config.ParameterTypeString,
 			Validations: []config.Validation{
-				config.ValidationRequired{},
+				config.ValidationInclusion{List: []string{"none", "inline", "records"}},
+			},
+		},
+		ConfigNestedObjects: {
+			Default:     "structured",
+			Description: "NestedObjects is the configuration name for the way the objects nested in the structured payloads are read,\nsuch as the addresses of the customers: `structured` reads them as they are,\nand `json` marshals them to JSON strings, the objects of the arrays are kept.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{
+				config.ValidationInclusion{List: []string{"structured", "json"}},
+			},
+		},
+		ConfigOnRetentionGap: {
+			Default:     "fail",
+			Description: "OnRetentionGap is the configuration name for the action when the events since the position\nare no longer retained by Stripe, which keeps them for 30 days:\n`fail` fails the source, and `snapshot` makes a new snapshot of the resources.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{
+				config.ValidationInclusion{List: []string{"fail", "snapshot"}},
 			},
 		},
+		ConfigPollLookback: {
+			Default:     "",
+			Description: "PollLookback is the configuration name for the window of the time of creation before the current time,\nthe lists of the resources are polled with the poll cdc strategy, to detect the changes of their objects,\nthe whole lists are polled if it is zero.",
+			Type:        config.ParameterTypeDuration,
+			Validations: []config.Validation{},
+		},
+		ConfigPollOverlap: {
+			Default:     "10m",
+			Description: "PollOverlap is the configuration name for the overlap of the windows of the time of creation,\nthe lists of the resources without events are polled with in the CDC mode, to receive the objects,\nwhich appear in the lists later than the objects created after them.",
+			Type:        config.ParameterTypeDuration,
+			Validations: []config.Validation{},
+		},
+		ConfigPollStateFile: {
+			Default:     "",
+			Description: "PollStateFile is the configuration name for the path of the local file, the identifiers and fingerprints\nof the seen polled objects are stored in, so they are not read again after a restart.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
+		ConfigRateLimit: {
+			Default:     "0",
+			Description: "RateLimit is the configuration name for the maximum number of requests per second to Stripe,\nif it is zero, the limit is selected by the mode of the secret key: 100 in live mode, and 25 in test mode.",
+			Type:        config.ParameterTypeInt,
+			Validations: []config.Validation{
+				config.ValidationGreaterThan{V: -1},
+			},
+		},
+		ConfigReconcileInterval: {
+			Default:     "",
+			Description: "ReconcileInterval is the configuration name for the time between the reconciliations of a resource,\nwhich list all of its objects, and emit the deletes of the objects missing from the list since the previous one,\nthe reconciliation is disabled if it is zero.",
+			Type:        config.ParameterTypeDuration,
+			Validations: []config.Validation{},
+		},
+		ConfigReconcileStateFile: {
+			Default:     "",
+			Description: "ReconcileStateFile is the configuration name for the path of the local file,\nthe identifiers of the objects listed by the reconciliations are stored in.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
+		ConfigResourceName: {
+			Default:     "",
+			Description: "ResourceName is the configuration name for Stripe resource.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
+		ConfigResourceNames: {
+			Default:     "",
+			Description: "ResourceNames is the configuration name for the list of Stripe resources, or `*` to read all of them.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
+		ConfigResourceSchema: {
+			Default:     "false",
+			Description: "ResourceSchema is the configuration name for the flag whether the records contain the key and payload schemas\nof the resource, which are derived from the Stripe OpenAPI spec.",
+			Type:        config.ParameterTypeBool,
+			Validations: []config.Validation{},
+		},
+		ConfigSearchQuery: {
+			Default:     "",
+			Description: "SearchQuery is the configuration name for the query of the Stripe Search API,\nsuch as `metadata['tenant']:'acme'`, the snapshot reads only the objects matching it if it is set.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
 		ConfigSecretKey: {
 			Default:     "",
 			Description: "SecretKey is the configuration name for Stripe secret key.",
@@ -47,5 +204,50 @@ func (Config) Parameters() map[string]config.Parameter {
 			Type:        config.ParameterTypeBool,
 			Validations: []config.Validation{},
 		},
+		ConfigSnapshotCreatedAfter: {
+			Default:     "",
+			Description: "SnapshotCreatedAfter is the configuration name for the time the objects of the snapshot are created at or after,\nwhich is an RFC 3339 time, or a duration before the start of the snapshot, such as `90d` or `36h`.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
+		ConfigSnapshotCreatedBefore: {
+			Default:     "",
+			Description: "SnapshotCreatedBefore is the configuration name for the time the objects of the snapshot are created before,\nwhich is an RFC 3339 time, or a duration before the start of the snapshot, such as `90d` or `36h`.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
+		ConfigSnapshotWorkers: {
+			Default:     "1",
+			Description: "SnapshotWorkers is the configuration name for the number of partitions of the time of creation of the objects\nthe snapshot reads concurrently, the resources are read sequentially if it is 1.",
+			Type:        config.ParameterTypeInt,
+			Validations: []config.Validation{
+				config.ValidationGreaterThan{V: 0},
+				config.ValidationLessThan{V: 101},
+			},
+		},
+		ConfigStructuredPayload: {
+			Default:     "false",
+			Description: "StructuredPayload is the configuration name for the flag whether the payloads are structured data,\ninstead of raw JSON bytes.",
+			Type:        config.ParameterTypeBool,
+			Validations: []config.Validation{},
+		},
+		ConfigWebhookAddress: {
+			Default:     ":8080",
+			Description: "WebhookAddress is the configuration name for the address the webhook listener binds to.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
+		ConfigWebhookSecret: {
+			Default:     "",
+			Description: "WebhookSecret is the configuration name for the signing secret of the Stripe webhook endpoint.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
+		ConfigWebhookTolerance: {
+			Default:     "5m",
+			Description: "WebhookTolerance is the configuration name for the maximum difference\nbetween the time of the webhook signature and the current time.",
+			Type:        config.ParameterTypeDuration,
+			Validations: []config.Validation{},
+		},
 	}
 }
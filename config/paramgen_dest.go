@@ -0,0 +1,57 @@
+// Code generated by paramgen. DO NOT EDIT.
+// Source: github.com/ConduitIO/conduit-commons/tree/main/paramgen
+
+package config
+
+import (
+	"github.com/conduitio/conduit-commons/config"
+)
+
+const (
+	DestinationConfigApiVersion   = "apiVersion"
+	DestinationConfigBaseURL      = "baseURL"
+	DestinationConfigRateLimit    = "rateLimit"
+	DestinationConfigResourceName = "resourceName"
+	DestinationConfigSecretKey    = "secretKey"
+)
+
+func (DestinationConfig) Parameters() map[string]config.Parameter {
+	return map[string]config.Parameter{
+		DestinationConfigApiVersion: {
+			Default:     "",
+			Description: "APIVersion is the configuration name for the Stripe API version sent with every request,\nthe default API version of the account is used if it is empty.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
+		DestinationConfigBaseURL: {
+			Default:     "https://api.stripe.com",
+			Description: "BaseURL is the configuration name for the base URL of the Stripe API,\nwhich can point to a local Stripe stand-in, such as stripe-mock, or a proxy.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
+		DestinationConfigRateLimit: {
+			Default:     "0",
+			Description: "RateLimit is the configuration name for the maximum number of requests per second to Stripe,\nif it is zero, the limit is selected by the mode of the secret key: 100 in live mode, and 25 in test mode.",
+			Type:        config.ParameterTypeInt,
+			Validations: []config.Validation{
+				config.ValidationGreaterThan{V: -1},
+			},
+		},
+		DestinationConfigResourceName: {
+			Default:     "",
+			Description: "ResourceName is the configuration name for Stripe resource.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{
+				config.ValidationRequired{},
+			},
+		},
+		DestinationConfigSecretKey: {
+			Default:     "",
+			Description: "SecretKey is the configuration name for Stripe secret key.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{
+				config.ValidationRequired{},
+			},
+		},
+	}
+}
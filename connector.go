@@ -15,6 +15,7 @@
 package stripe
 
 import (
+	"github.com/conduitio-labs/conduit-connector-stripe/destination"
 	"github.com/conduitio-labs/conduit-connector-stripe/source"
 	sdk "github.com/conduitio/conduit-connector-sdk"
 )
@@ -23,5 +24,5 @@ import (
 var Connector = sdk.Connector{
 	NewSpecification: Specification,
 	NewSource:        source.NewSource,
-	NewDestination:   nil,
+	NewDestination:   destination.NewDestination,
 }
@@ -0,0 +1,238 @@
+// Copyright © 2022 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package destination
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/conduitio-labs/conduit-connector-stripe/clients/http"
+	"github.com/conduitio-labs/conduit-connector-stripe/config"
+	"github.com/conduitio-labs/conduit-connector-stripe/models"
+	"github.com/conduitio-labs/conduit-connector-stripe/stripe"
+	commonsConfig "github.com/conduitio/conduit-commons/config"
+	"github.com/conduitio/conduit-commons/opencdc"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+)
+
+//go:generate mockgen -package mock -source destination.go -destination ./mock/destination.go
+
+// errEmptyKey occurs when the record key does not contain the object identifier.
+var errEmptyKey = errors.New("record key does not contain the object identifier")
+
+// readOnlyFields are the fields of the Stripe objects, which are set by Stripe, so they are not sent,
+// such as the fields of the payloads of the records read by the source.
+var readOnlyFields = []string{models.KeyID, models.KeyObject, models.KeyCreated, models.KeyLivemode}
+
+// A Writer defines the interface of methods that write objects to Stripe.
+type Writer interface {
+	CreateResource(idempotencyKey string, params map[string]interface{}) (map[string]interface{}, error)
+	UpdateResource(id, idempotencyKey string, params map[string]interface{}) (map[string]interface{}, error)
+	DeleteResource(id string) error
+}
+
+// A Destination represents the destination connector.
+type Destination struct {
+	sdk.UnimplementedDestination
+	cfg     config.DestinationConfig
+	writer  Writer
+	httpCli http.Client
+}
+
+// NewDestination initialises a new destination.
+func NewDestination() sdk.Destination {
+	return sdk.DestinationWithMiddleware(&Destination{}, sdk.DefaultDestinationMiddleware()...)
+}
+
+// Parameters returns a map of named Parameters that describe how to configure the Destination.
+func (d *Destination) Parameters() commonsConfig.Parameters {
+	return d.cfg.Parameters()
+}
+
+// Configure parses and stores configurations, returns an error in case of invalid configuration.
+func (d *Destination) Configure(ctx context.Context, cfgRaw commonsConfig.Config) error {
+	err := sdk.Util.ParseConfig(ctx, cfgRaw, &d.cfg, NewDestination().Parameters())
+	if err != nil {
+		return err
+	}
+
+	err = d.cfg.Validate()
+	if err != nil {
+		return fmt.Errorf("error validating configuration: %w", err)
+	}
+
+	return nil
+}
+
+// Open initializes an http client and a Stripe writer, and validates the API version if it is set.
+func (d *Destination) Open(ctx context.Context) error {
+	d.httpCli = http.NewClient(ctx, d.cfg.RequestsPerSecond())
+
+	stripeSvc := stripe.New(d.cfg.Config(), d.httpCli)
+
+	if d.cfg.APIVersion != "" {
+		if err := stripeSvc.ValidateAPIVersion(); err != nil {
+			return fmt.Errorf("validate api version: %w", err)
+		}
+	}
+
+	d.writer = stripeSvc
+
+	return nil
+}
+
+// Write writes records to Stripe, and returns the number of written records.
+func (d *Destination) Write(ctx context.Context, records []opencdc.Record) (int, error) {
+	for i := range records {
+		err := sdk.Util.Destination.Route(ctx, records[i],
+			d.create,
+			d.update,
+			d.delete,
+			d.create,
+		)
+		if err != nil {
+			return i, fmt.Errorf("write record with position %s: %w", records[i].Position, err)
+		}
+	}
+
+	return len(records), nil
+}
+
+// Teardown closes any connections which were previously connected from previous requests.
+func (d *Destination) Teardown(ctx context.Context) error {
+	sdk.Logger(ctx).Info().Msg("tearing down a stripe destination")
+
+	d.httpCli.Close()
+
+	return nil
+}
+
+// create creates a new Stripe object from the record payload.
+func (d *Destination) create(_ context.Context, record opencdc.Record) error {
+	params, err := parsePayload(record.Payload.After)
+	if err != nil {
+		return fmt.Errorf("parse payload: %w", err)
+	}
+
+	stripReadOnlyFields(params)
+
+	_, err = d.writer.CreateResource(d.idempotencyKey(record), params)
+	if err != nil {
+		return fmt.Errorf("create resource: %w", err)
+	}
+
+	return nil
+}
+
+// update updates the Stripe object identified by the record key with the record payload.
+func (d *Destination) update(_ context.Context, record opencdc.Record) error {
+	id, err := parseKey(record.Key)
+	if err != nil {
+		return fmt.Errorf("parse key: %w", err)
+	}
+
+	params, err := parsePayload(record.Payload.After)
+	if err != nil {
+		return fmt.Errorf("parse payload: %w", err)
+	}
+
+	// the identifier is a part of the URL and cannot be updated
+	stripReadOnlyFields(params)
+
+	_, err = d.writer.UpdateResource(id, d.idempotencyKey(record), params)
+	if err != nil {
+		return fmt.Errorf("update resource: %w", err)
+	}
+
+	return nil
+}
+
+// delete deletes the Stripe object identified by the record key.
+func (d *Destination) delete(_ context.Context, record opencdc.Record) error {
+	id, err := parseKey(record.Key)
+	if err != nil {
+		return fmt.Errorf("parse key: %w", err)
+	}
+
+	err = d.writer.DeleteResource(id)
+	if err != nil {
+		return fmt.Errorf("delete resource: %w", err)
+	}
+
+	return nil
+}
+
+// idempotencyKey returns the idempotency key of the request of the record, which is the hash of the resource name,
+// the operation and the position of the record, so the retries of the request, and the record written again
+// after a restart, are applied by Stripe only once (within the 24 hours Stripe keeps the keys).
+func (d *Destination) idempotencyKey(record opencdc.Record) string {
+	hash := sha256.New()
+	_, _ = hash.Write([]byte(d.cfg.ResourceName + "\x00" + record.Operation.String() + "\x00"))
+	_, _ = hash.Write(record.Position)
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// stripReadOnlyFields removes the read-only fields from the parameters of the Stripe object.
+func stripReadOnlyFields(params map[string]interface{}) {
+	for _, field := range readOnlyFields {
+		delete(params, field)
+	}
+}
+
+// parseKey returns the object identifier from the record key,
+// which is either a structured key with the `id` field or a raw identifier.
+func parseKey(key opencdc.Data) (string, error) {
+	var id string
+
+	switch k := key.(type) {
+	case opencdc.StructuredData:
+		id, _ = k[models.KeyID].(string)
+	case opencdc.RawData:
+		structured := make(map[string]interface{})
+		if err := json.Unmarshal(k, &structured); err == nil {
+			id, _ = structured[models.KeyID].(string)
+		} else {
+			id = string(k)
+		}
+	}
+
+	if id == "" {
+		return "", errEmptyKey
+	}
+
+	return id, nil
+}
+
+// parsePayload returns the Stripe object parameters from the record payload.
+// The payload is decoded from its JSON representation so that structured and raw payloads
+// result in the same value types.
+func parsePayload(payload opencdc.Data) (map[string]interface{}, error) {
+	params := make(map[string]interface{})
+
+	if payload == nil || len(payload.Bytes()) == 0 {
+		return params, nil
+	}
+
+	if err := json.Unmarshal(payload.Bytes(), &params); err != nil {
+		return nil, fmt.Errorf("unmarshal payload: %w", err)
+	}
+
+	return params, nil
+}
@@ -0,0 +1,216 @@
+// Copyright © 2022 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package destination
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/conduitio-labs/conduit-connector-stripe/config"
+	"github.com/conduitio-labs/conduit-connector-stripe/destination/mock"
+	"github.com/conduitio-labs/conduit-connector-stripe/models"
+	"github.com/conduitio/conduit-commons/opencdc"
+	"go.uber.org/mock/gomock"
+)
+
+func TestDestination_Configure(t *testing.T) {
+	destination := new(Destination)
+
+	tests := []struct {
+		name        string
+		in          map[string]string
+		want        Destination
+		wantErr     bool
+		expectedErr string
+	}{
+		{
+			name: "valid config",
+			in: map[string]string{
+				config.DestinationConfigSecretKey:    "sk_51JB",
+				config.DestinationConfigResourceName: "customer",
+			},
+			want: Destination{
+				cfg: config.DestinationConfig{
+					SecretKey:    "sk_51JB",
+					ResourceName: "customer",
+					BaseURL:      models.BaseURL,
+				},
+			},
+		},
+		{
+			name: "invalid resource name",
+			in: map[string]string{
+				config.DestinationConfigSecretKey:    "sk_51JB",
+				config.DestinationConfigResourceName: "invalid_resource",
+			},
+			wantErr:     true,
+			expectedErr: `error validating configuration: "invalid_resource" wrong resource name`,
+		},
+		{
+			name: "child resource name",
+			in: map[string]string{
+				config.DestinationConfigSecretKey:    "sk_51JB",
+				config.DestinationConfigResourceName: "tax_id",
+			},
+			wantErr:     true,
+			expectedErr: `error validating configuration: the tax_id resource is not supported by the destination`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := destination.Configure(context.Background(), tt.in)
+			if err != nil {
+				if !tt.wantErr {
+					t.Errorf("parse error = \"%s\", wantErr %t", err.Error(), tt.wantErr)
+
+					return
+				}
+
+				if err.Error() != tt.expectedErr {
+					t.Errorf("expected error \"%s\", got \"%s\"", tt.expectedErr, err.Error())
+
+					return
+				}
+
+				return
+			}
+
+			if !reflect.DeepEqual(destination.cfg, tt.want.cfg) {
+				t.Errorf("parse = %v, want %v", destination.cfg, tt.want.cfg)
+
+				return
+			}
+		})
+	}
+}
+
+func TestDestination_Write(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		m := mock.NewMockWriter(ctrl)
+
+		records := []opencdc.Record{
+			{
+				Position:  opencdc.Position("1"),
+				Operation: opencdc.OperationSnapshot,
+				Payload: opencdc.Change{
+					After: opencdc.RawData(
+						`{"id":"cus_LY6gsj","object":"customer","created":1652790000,"livemode":false,` +
+							`"name":"snapshot","metadata":{"tenant":"acme"}}`,
+					),
+				},
+			},
+			{
+				Position:  opencdc.Position("2"),
+				Operation: opencdc.OperationCreate,
+				Payload: opencdc.Change{
+					After: opencdc.StructuredData{models.KeyName: "create"},
+				},
+			},
+			{
+				Position:  opencdc.Position("3"),
+				Operation: opencdc.OperationUpdate,
+				Key:       opencdc.StructuredData{models.KeyID: "cus_LY6gsj"},
+				Payload: opencdc.Change{
+					After: opencdc.RawData(`{"id":"cus_LY6gsj","object":"customer","name":"update"}`),
+				},
+			},
+			{
+				Operation: opencdc.OperationDelete,
+				Key:       opencdc.RawData("cus_LY6gsj"),
+			},
+		}
+
+		d := &Destination{writer: m}
+
+		gomock.InOrder(
+			m.EXPECT().CreateResource(d.idempotencyKey(records[0]), map[string]interface{}{
+				models.KeyName: "snapshot",
+				"metadata":     map[string]interface{}{"tenant": "acme"},
+			}).Return(nil, nil),
+			m.EXPECT().CreateResource(d.idempotencyKey(records[1]), map[string]interface{}{models.KeyName: "create"}).
+				Return(nil, nil),
+			m.EXPECT().UpdateResource("cus_LY6gsj", d.idempotencyKey(records[2]), map[string]interface{}{
+				models.KeyName: "update",
+			}).Return(nil, nil),
+			m.EXPECT().DeleteResource("cus_LY6gsj").Return(nil),
+		)
+
+		n, err := d.Write(context.Background(), records)
+		if err != nil {
+			t.Errorf("write error = \"%s\"", err.Error())
+		}
+
+		if n != len(records) {
+			t.Errorf("written: got = %d, want %d", n, len(records))
+		}
+	})
+
+	t.Run("failure stops the batch", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		m := mock.NewMockWriter(ctrl)
+
+		records := []opencdc.Record{
+			{
+				Operation: opencdc.OperationDelete,
+				Key:       opencdc.StructuredData{models.KeyID: "cus_LY6gsj"},
+			},
+			{
+				Operation: opencdc.OperationDelete,
+				Key:       opencdc.StructuredData{},
+			},
+			{
+				Operation: opencdc.OperationDelete,
+				Key:       opencdc.StructuredData{models.KeyID: "cus_LY6gsk"},
+			},
+		}
+
+		m.EXPECT().DeleteResource("cus_LY6gsj").Return(nil)
+
+		d := &Destination{writer: m}
+
+		n, err := d.Write(context.Background(), records)
+		if !errors.Is(err, errEmptyKey) {
+			t.Errorf("expected error \"%s\", got \"%v\"", errEmptyKey, err)
+		}
+
+		if n != 1 {
+			t.Errorf("written: got = %d, want %d", n, 1)
+		}
+	})
+}
+
+func TestDestination_IdempotencyKey(t *testing.T) {
+	d := &Destination{cfg: config.DestinationConfig{ResourceName: "customer"}}
+
+	record := opencdc.Record{Position: opencdc.Position("1"), Operation: opencdc.OperationCreate}
+
+	// the key of the record written again is the same
+	if d.idempotencyKey(record) != d.idempotencyKey(record) {
+		t.Errorf("the keys of the same record differ")
+	}
+
+	otherPosition := opencdc.Record{Position: opencdc.Position("2"), Operation: opencdc.OperationCreate}
+	otherOperation := opencdc.Record{Position: opencdc.Position("1"), Operation: opencdc.OperationUpdate}
+
+	for _, other := range []opencdc.Record{otherPosition, otherOperation} {
+		if d.idempotencyKey(record) == d.idempotencyKey(other) {
+			t.Errorf("the key of %v is the same as the key of %v", other, record)
+		}
+	}
+}
@@ -14,14 +14,33 @@
 
 package models
 
+import "time"
+
 const (
-	APIURL                = "https://api.stripe.com/v1"
+	BaseURL               = "https://api.stripe.com"
+	APIPath               = "/v1"
+	APIURL                = BaseURL + APIPath
 	PathFmt               = "/%s"
 	HeaderAuthKey         = "Authorization"
 	HeaderAuthValueFormat = "Bearer %s"
+	HeaderAccountKey      = "Stripe-Account"
+	HeaderVersionKey      = "Stripe-Version"
+	HeaderIdempotencyKey  = "Idempotency-Key"
 
 	// UnexpectedErrorWithStatusCode represents an unexpected error message with status code.
 	UnexpectedErrorWithStatusCode = "unexpected error with status code %d"
+	// ErrorCodeRateLimit is the code of Stripe's error, when too many requests hit the API too quickly.
+	ErrorCodeRateLimit = "rate_limit"
+	// ErrorCodeResourceMissing is the code of Stripe's error, when the requested object does not exist.
+	ErrorCodeResourceMissing = "resource_missing"
+
+	// EventRetention is the period Stripe retains the events for.
+	EventRetention = 30 * 24 * time.Hour
+
+	// LiveModeRequestsPerSecond is the number of read operations per second Stripe allows in live mode.
+	LiveModeRequestsPerSecond = 100
+	// TestModeRequestsPerSecond is the number of read operations per second Stripe allows in test mode.
+	TestModeRequestsPerSecond = 25
 
 	KeyID          = "id"
 	KeyName        = "name"
@@ -29,5 +48,28 @@ const (
 	KeyAmount      = "amount"
 	KeyDescription = "description"
 	KeyCreated     = "created"
+	KeyLivemode    = "livemode"
 	KeyDeleted     = "deleted"
+	KeyData        = "data"
+	KeyType        = "type"
+	KeyHasMore     = "has_more"
+	KeyURL         = "url"
+	KeyParentID    = "parent_id"
+
+	// MetadataResource is the metadata key of the Stripe resource name of the record.
+	MetadataResource = "stripe.resource"
+	// MetadataParentID is the metadata key of the identifier of the parent object of the record of a nested list.
+	MetadataParentID = "stripe.parent_id"
+	// MetadataAccount is the metadata key of the Stripe connected account of the record.
+	MetadataAccount = "stripe.account"
+	// MetadataAPIVersion is the metadata key of the Stripe API version of the record payload.
+	MetadataAPIVersion = "stripe.api_version"
+	// MetadataEventID is the metadata key of the identifier of the Stripe event of the record.
+	MetadataEventID = "stripe.event_id"
+	// MetadataEventType is the metadata key of the type of the Stripe event of the record, such as `invoice.paid`.
+	MetadataEventType = "stripe.event_type"
+	// MetadataLivemode is the metadata key of the flag whether the object of the record exists in live mode.
+	MetadataLivemode = "stripe.livemode"
+	// MetadataRequestID is the metadata key of the identifier of the API request which caused the event of the record.
+	MetadataRequestID = "stripe.request_id"
 )
@@ -0,0 +1,483 @@
+// Copyright © 2022 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package openapi derives Avro schemas of the Stripe resources from the vendored Stripe OpenAPI spec.
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/hamba/avro/v2"
+)
+
+const (
+	// refPrefix is the prefix of the references to the component schemas.
+	refPrefix = "#/components/schemas/"
+	// namespace is the namespace of the Avro schemas.
+	namespace = "stripe"
+
+	// propJSON is the property of the string schemas of the fields, whose values are marshaled to JSON strings,
+	// because their schemas cannot be expressed in Avro, such as the polymorphic objects.
+	propJSON = "stripe.json"
+
+	typeString  = "string"
+	typeInteger = "integer"
+	typeNumber  = "number"
+	typeBoolean = "boolean"
+	typeObject  = "object"
+	typeArray   = "array"
+)
+
+// ErrNoSchema occurs when the resource has no schema in the spec.
+var ErrNoSchema = errors.New("no schema")
+
+// variantResources are the resources without a component schema in the spec, whose objects are any of the components,
+// where the key is the resource and the value is a slice of the components, such as the sources of the customers.
+var variantResources = map[string][]string{
+	"customer_source": {"account", "bank_account", "card", "source"},
+}
+
+// spec is the Stripe OpenAPI spec, which is refreshed with `make openapi`.
+//
+//go:embed spec3.json
+var spec []byte
+
+var (
+	components     map[string]*specSchema
+	componentsErr  error
+	componentsOnce sync.Once
+)
+
+// A specSchema represents the subset of the OpenAPI schema object used by Stripe to describe the objects.
+type specSchema struct {
+	Ref                  string                 `json:"$ref"`
+	Type                 string                 `json:"type"`
+	Properties           map[string]*specSchema `json:"properties"`
+	AdditionalProperties json.RawMessage        `json:"additionalProperties"`
+	Items                *specSchema            `json:"items"`
+	AnyOf                []*specSchema          `json:"anyOf"`
+	OneOf                []*specSchema          `json:"oneOf"`
+}
+
+// AvroSchema returns the Avro schema of the object of the resource.
+// All fields of the records are nullable, because the fields of the objects depend on the API version.
+// The expandable fields are strings, because the objects contain only identifiers of the related objects,
+// and the nested objects are JSON strings, except for the items of the arrays.
+func AvroSchema(resourceName string) (avro.Schema, error) {
+	componentsOnce.Do(func() {
+		var s struct {
+			Components struct {
+				Schemas map[string]*specSchema `json:"schemas"`
+			} `json:"components"`
+		}
+
+		componentsErr = json.Unmarshal(spec, &s)
+		components = s.Components.Schemas
+	})
+
+	if componentsErr != nil {
+		return nil, fmt.Errorf("unmarshal spec: %w", componentsErr)
+	}
+
+	component, ok := components[resourceName]
+	if !ok {
+		component, ok = mergeVariants(variantResources[resourceName])
+	}
+
+	if !ok {
+		component, ok = nestedListItems(resourceName)
+	}
+
+	if !ok {
+		return nil, fmt.Errorf("resource %s: %w", resourceName, ErrNoSchema)
+	}
+
+	sch, err := convert(component, avroName(resourceName), []string{resourceName})
+	if err != nil {
+		return nil, fmt.Errorf("resource %s: %w", resourceName, err)
+	}
+
+	return sch, nil
+}
+
+// mergeVariants returns the object schema with the properties of all components,
+// where a property whose schemas differ between the components has no schema, so it is a JSON string.
+// It reports whether all components are in the spec.
+func mergeVariants(names []string) (*specSchema, bool) {
+	if len(names) == 0 {
+		return nil, false
+	}
+
+	merged := &specSchema{Type: typeObject, Properties: make(map[string]*specSchema)}
+
+	for _, name := range names {
+		component, ok := components[name]
+		if !ok {
+			return nil, false
+		}
+
+		for property, sch := range component.Properties {
+			if other, ok := merged.Properties[property]; ok && !reflect.DeepEqual(other, sch) {
+				sch = &specSchema{}
+			}
+
+			merged.Properties[property] = sch
+		}
+	}
+
+	return merged, true
+}
+
+// nestedListItems returns the schema of the objects of the list nested in the objects of a resource,
+// whose name is the resource and the field of the list, such as `invoice.lines`.
+// It reports whether there is such a list in the spec.
+func nestedListItems(name string) (*specSchema, bool) {
+	i := strings.LastIndex(name, ".")
+	if i < 0 {
+		return nil, false
+	}
+
+	parent, ok := components[name[:i]]
+	if !ok {
+		return nil, false
+	}
+
+	list := resolve(parent.Properties[name[i+1:]])
+	if list == nil || list.Properties["data"] == nil || list.Properties["data"].Items == nil {
+		return nil, false
+	}
+
+	return list.Properties["data"].Items, true
+}
+
+// resolve returns the component schema the schema refers to, or the schema itself if it is not a reference,
+// where the single variant of `anyOf` is the schema of a nullable reference. It returns nil if there is no schema.
+func resolve(s *specSchema) *specSchema {
+	if s != nil && len(s.AnyOf) == 1 {
+		s = s.AnyOf[0]
+	}
+
+	if s == nil || s.Ref == "" {
+		return s
+	}
+
+	return components[strings.TrimPrefix(s.Ref, refPrefix)]
+}
+
+// KeyAvroSchema returns the Avro schema of the keys of the records of the resource,
+// which contain the identifier of the parent object if the resource is a child resource.
+func KeyAvroSchema(resourceName string, child bool) (avro.Schema, error) {
+	field, err := avro.NewField("id", avro.NewPrimitiveSchema(avro.String, nil))
+	if err != nil {
+		return nil, fmt.Errorf("new id field: %w", err)
+	}
+
+	fields := []*avro.Field{field}
+
+	if child {
+		field, err = avro.NewField("parent_id", avro.NewPrimitiveSchema(avro.String, nil))
+		if err != nil {
+			return nil, fmt.Errorf("new parent_id field: %w", err)
+		}
+
+		fields = append(fields, field)
+	}
+
+	sch, err := avro.NewRecordSchema(avroName(resourceName)+"_key", namespace, fields)
+	if err != nil {
+		return nil, fmt.Errorf("new key record schema: %w", err)
+	}
+
+	return sch, nil
+}
+
+// convert returns the Avro schema of the spec schema, where the name is the name of the record, if it is one,
+// and refs are the names of the component schemas being converted, to detect recursive references.
+// The schemas which cannot be expressed in Avro, such as the recursive references, are JSON strings.
+func convert(s *specSchema, name string, refs []string) (avro.Schema, error) {
+	if s.Ref != "" {
+		ref := strings.TrimPrefix(s.Ref, refPrefix)
+
+		component, ok := components[ref]
+		if !ok || slices.Contains(refs, ref) {
+			return jsonSchema(), nil
+		}
+
+		return convert(component, name, append(refs, ref))
+	}
+
+	variants := s.AnyOf
+	if len(variants) == 0 {
+		variants = s.OneOf
+	}
+
+	if len(variants) > 0 {
+		return convertVariants(variants, name, refs)
+	}
+
+	switch s.Type {
+	case typeString:
+		return avro.NewPrimitiveSchema(avro.String, nil), nil
+	case typeInteger:
+		return avro.NewPrimitiveSchema(avro.Long, nil), nil
+	case typeNumber:
+		return avro.NewPrimitiveSchema(avro.Double, nil), nil
+	case typeBoolean:
+		return avro.NewPrimitiveSchema(avro.Boolean, nil), nil
+	case typeArray:
+		if s.Items == nil {
+			return jsonSchema(), nil
+		}
+
+		items, err := convert(s.Items, name+"_item", refs)
+		if err != nil {
+			return nil, err
+		}
+
+		return avro.NewArraySchema(items), nil
+	case typeObject:
+		return convertObject(s, name, refs)
+	}
+
+	return jsonSchema(), nil
+}
+
+// convertVariants returns the Avro schema of the `anyOf` or `oneOf` variants.
+// A string variant means an expandable field, which contains the identifier of the related object,
+// a single variant is converted as is, and several variants, such as the polymorphic objects, are JSON strings,
+// because the Avro unions of records cannot tell the objects apart.
+func convertVariants(variants []*specSchema, name string, refs []string) (avro.Schema, error) {
+	for i := range variants {
+		if variants[i].Type == typeString {
+			return avro.NewPrimitiveSchema(avro.String, nil), nil
+		}
+	}
+
+	if len(variants) > 1 {
+		return jsonSchema(), nil
+	}
+
+	return convert(variants[0], name, refs)
+}
+
+// jsonSchema returns the Avro schema of the field, whose values are marshaled to JSON strings.
+func jsonSchema() avro.Schema {
+	return avro.NewPrimitiveSchema(avro.String, nil, avro.WithProps(map[string]any{propJSON: true}))
+}
+
+// convertObject returns the Avro record of the object with properties, or the Avro map of the dictionary.
+func convertObject(s *specSchema, name string, refs []string) (avro.Schema, error) {
+	if len(s.Properties) == 0 {
+		var values specSchema
+		if err := json.Unmarshal(s.AdditionalProperties, &values); err != nil {
+			// the object of unknown properties is a JSON string
+			return jsonSchema(), nil
+		}
+
+		sch, err := convert(&values, name+"_value", refs)
+		if err != nil {
+			return nil, err
+		}
+
+		return avro.NewMapSchema(nestedSchema(sch)), nil
+	}
+
+	properties := make([]string, 0, len(s.Properties))
+	for property := range s.Properties {
+		properties = append(properties, property)
+	}
+
+	slices.Sort(properties)
+
+	fields := make([]*avro.Field, 0, len(properties))
+
+	for _, property := range properties {
+		sch, err := convert(s.Properties[property], name+"_"+avroName(property), refs)
+		if err != nil {
+			return nil, err
+		}
+
+		union, err := avro.NewUnionSchema([]avro.Schema{avro.NewPrimitiveSchema(avro.Null, nil), nestedSchema(sch)})
+		if err != nil {
+			return nil, fmt.Errorf("new union schema of %s.%s: %w", name, property, err)
+		}
+
+		field, err := avro.NewField(property, union, avro.WithDefault(nil))
+		if err != nil {
+			return nil, fmt.Errorf("new field %s.%s: %w", name, property, err)
+		}
+
+		fields = append(fields, field)
+	}
+
+	sch, err := avro.NewRecordSchema(name, namespace, fields)
+	if err != nil {
+		return nil, fmt.Errorf("new record schema %s: %w", name, err)
+	}
+
+	return sch, nil
+}
+
+// nestedSchema returns the schema of the field or the value of a map, where the records and maps are JSON strings,
+// because the Avro serde resolves the unions of records and maps only with the values wrapped in the name of their type.
+func nestedSchema(sch avro.Schema) avro.Schema {
+	switch sch.(type) {
+	case *avro.RecordSchema, *avro.MapSchema:
+		return jsonSchema()
+	default:
+		return sch
+	}
+}
+
+// avroName returns the name, which is valid in Avro, such as `checkout_session` for `checkout.session`.
+func avroName(name string) string {
+	return strings.ReplaceAll(name, ".", "_")
+}
+
+// Normalize converts the values of the decoded Stripe object in place to the Go types of the Avro schema,
+// such as json.Number to int64 for a long, and the values of the fields, which cannot be expressed in Avro,
+// to JSON strings. The shape of the object is left as is: the Avro serde of the records resolves the unions,
+// and the missing fields are null by default.
+func Normalize(value interface{}, sch avro.Schema) interface{} {
+	switch s := sch.(type) {
+	case *avro.RecordSchema:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return value
+		}
+
+		for _, field := range s.Fields() {
+			if v, ok := object[field.Name()]; ok {
+				object[field.Name()] = Normalize(v, field.Type())
+			}
+		}
+
+		return object
+	case *avro.UnionSchema:
+		if value == nil {
+			return nil
+		}
+
+		for _, t := range s.Types() {
+			if t.Type() != avro.Null {
+				return Normalize(value, t)
+			}
+		}
+	case *avro.ArraySchema:
+		items, ok := value.([]interface{})
+		if !ok {
+			return value
+		}
+
+		for i := range items {
+			items[i] = Normalize(items[i], s.Items())
+		}
+
+		return items
+	case *avro.MapSchema:
+		values, ok := value.(map[string]interface{})
+		if !ok {
+			return value
+		}
+
+		for k := range values {
+			values[k] = Normalize(values[k], s.Values())
+		}
+
+		return values
+	case *avro.PrimitiveSchema:
+		if s.Prop(propJSON) == true {
+			return normalizeJSON(value)
+		}
+
+		return normalizeNumber(value, s.Type())
+	}
+
+	return value
+}
+
+// MarshalNestedObjects marshals the nested objects of the decoded Stripe object in place to JSON strings,
+// which is the shape of the payloads the schemas describe, because the Avro serde cannot encode nullable records
+// and maps as they are. The objects of the arrays are kept, and their nested objects are marshaled the same way.
+func MarshalNestedObjects(object map[string]interface{}) {
+	for k, v := range object {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			object[k] = normalizeJSON(v)
+		case []interface{}:
+			marshalItems(v)
+		}
+	}
+}
+
+// marshalItems marshals the nested objects of the objects of the array in place to JSON strings.
+func marshalItems(items []interface{}) {
+	for _, item := range items {
+		switch item := item.(type) {
+		case map[string]interface{}:
+			MarshalNestedObjects(item)
+		case []interface{}:
+			marshalItems(item)
+		}
+	}
+}
+
+// normalizeJSON marshals the value to a JSON string, unless it is a string already.
+func normalizeJSON(value interface{}) interface{} {
+	if _, ok := value.(string); ok {
+		return value
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+
+	return string(data)
+}
+
+// normalizeNumber converts the number to int64 for a long, or to float64 for a double.
+func normalizeNumber(value interface{}, typ avro.Type) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if typ == avro.Long {
+			if n, err := v.Int64(); err == nil {
+				return n
+			}
+		}
+
+		if typ == avro.Double {
+			if n, err := v.Float64(); err == nil {
+				return n
+			}
+		}
+	case float64:
+		if typ == avro.Long {
+			return int64(v)
+		}
+	case int64:
+		if typ == avro.Double {
+			return float64(v)
+		}
+	}
+
+	return value
+}
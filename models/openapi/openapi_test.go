@@ -0,0 +1,228 @@
+// Copyright © 2022 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/conduitio-labs/conduit-connector-stripe/models"
+	"github.com/conduitio-labs/conduit-connector-stripe/models/resources"
+	"github.com/conduitio/conduit-commons/schema/avro"
+	"github.com/matryer/is"
+)
+
+func TestAvroSchema(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		resourceName string
+		wantErr      error
+	}{
+		{
+			name:         "customer",
+			resourceName: resources.CustomerResource,
+		},
+		{
+			name:         "price",
+			resourceName: resources.PriceResource,
+		},
+		{
+			name:         "resource_without_schema",
+			resourceName: "unknown",
+			wantErr:      ErrNoSchema,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			is := is.New(t)
+
+			sch, err := AvroSchema(tt.resourceName)
+			if tt.wantErr != nil {
+				is.True(errors.Is(err, tt.wantErr))
+
+				return
+			}
+
+			is.NoErr(err)
+
+			data, err := json.Marshal(sch)
+			is.NoErr(err)
+
+			_, err = avro.Parse(data)
+			is.NoErr(err)
+		})
+	}
+}
+
+func TestAvroSchema_AllResources(t *testing.T) {
+	t.Parallel()
+
+	// the Orders API is no longer in the spec
+	withoutSchema := map[string]struct{}{
+		resources.OrderResource: {},
+	}
+
+	for resourceName := range models.ResourcesMap {
+		t.Run(resourceName, func(t *testing.T) {
+			t.Parallel()
+			is := is.New(t)
+
+			sch, err := AvroSchema(resourceName)
+			if _, ok := withoutSchema[resourceName]; ok {
+				is.True(errors.Is(err, ErrNoSchema))
+
+				return
+			}
+
+			is.NoErr(err)
+
+			data, err := json.Marshal(sch)
+			is.NoErr(err)
+
+			_, err = avro.Parse(data)
+			is.NoErr(err)
+		})
+	}
+
+	for resourceName := range models.NestedListResources {
+		t.Run(resourceName, func(t *testing.T) {
+			t.Parallel()
+			is := is.New(t)
+
+			_, err := AvroSchema(resourceName)
+			is.NoErr(err)
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	t.Parallel()
+	is := is.New(t)
+
+	sch, err := AvroSchema(resources.CustomerResource)
+	is.NoErr(err)
+
+	var object map[string]interface{}
+
+	// the numbers are decoded the same way as by the Stripe client
+	dec := json.NewDecoder(bytes.NewReader([]byte(`{
+		"id": "cus_LY6gsj",
+		"object": "customer",
+		"address": {"city": "Berlin", "country": "DE"},
+		"balance": 9007199254740993,
+		"created": 1651153903,
+		"default_source": "card_1LajCF",
+		"discount": {"coupon": {"id": "25OFF", "percent_off": 25, "valid": true}},
+		"invoice_settings": {"custom_fields": null, "footer": "Thanks"},
+		"livemode": false,
+		"metadata": {"tenant": "acme"},
+		"preferred_locales": ["en", "de"],
+		"shipping": null,
+		"sources": {"object": "list", "data": [{"id": "card_1LajCF", "object": "card"}], "has_more": false}
+	}`)))
+	dec.UseNumber()
+	is.NoErr(dec.Decode(&object))
+
+	MarshalNestedObjects(object)
+	object = Normalize(object, sch).(map[string]interface{})
+
+	// the nested objects are JSON strings, the numbers are converted, and the rest is left as is
+	want := map[string]interface{}{
+		"id":                "cus_LY6gsj",
+		"object":            "customer",
+		"address":           `{"city":"Berlin","country":"DE"}`,
+		"balance":           int64(9007199254740993),
+		"created":           int64(1651153903),
+		"default_source":    "card_1LajCF",
+		"discount":          `{"coupon":{"id":"25OFF","percent_off":25,"valid":true}}`,
+		"invoice_settings":  `{"custom_fields":null,"footer":"Thanks"}`,
+		"livemode":          false,
+		"metadata":          `{"tenant":"acme"}`,
+		"preferred_locales": []interface{}{"en", "de"},
+		"shipping":          nil,
+		"sources":           `{"data":[{"id":"card_1LajCF","object":"card"}],"has_more":false,"object":"list"}`,
+	}
+	is.Equal(object, want)
+
+	data, err := json.Marshal(sch)
+	is.NoErr(err)
+
+	serde, err := avro.Parse(data)
+	is.NoErr(err)
+
+	encoded, err := serde.Marshal(object)
+	is.NoErr(err)
+
+	var decoded map[string]interface{}
+	is.NoErr(serde.Unmarshal(encoded, &decoded))
+
+	// the fields missing from the payload are decoded as nulls
+	is.Equal(withoutNulls(decoded), withoutNulls(want))
+}
+
+// withoutNulls returns the value without the null fields of its objects.
+func withoutNulls(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+
+		for k := range v {
+			if v[k] != nil {
+				result[k] = withoutNulls(v[k])
+			}
+		}
+
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+
+		for i := range v {
+			result[i] = withoutNulls(v[i])
+		}
+
+		return result
+	}
+
+	return value
+}
+
+func TestKeyAvroSchema(t *testing.T) {
+	t.Parallel()
+	is := is.New(t)
+
+	sch, err := KeyAvroSchema(resources.TaxIDResource, true)
+	is.NoErr(err)
+
+	data, err := json.Marshal(sch)
+	is.NoErr(err)
+
+	serde, err := avro.Parse(data)
+	is.NoErr(err)
+
+	key := map[string]interface{}{"id": "txi_1", "parent_id": "cus_LY6gsj"}
+
+	encoded, err := serde.Marshal(key)
+	is.NoErr(err)
+
+	var decoded map[string]interface{}
+	is.NoErr(serde.Unmarshal(encoded, &decoded))
+	is.Equal(decoded, key)
+}
@@ -0,0 +1,58 @@
+// Copyright © 2022 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resources
+
+// The child resources exist only under their parent objects,
+// so their lists are the formats of the paths with the identifier of the parent object.
+const (
+	CustomerBalanceTransactionResource = "customer_balance_transaction"
+	CustomerBalanceTransactionsList    = "customers/%s/balance_transactions"
+
+	CustomerSourceResource      = "customer_source"
+	CustomerSourcesList         = "customers/%s/sources"
+	CustomerSourceCreatedEvent  = "customer.source.created"
+	CustomerSourceDeletedEvent  = "customer.source.deleted"
+	CustomerSourceExpiringEvent = "customer.source.expiring"
+	CustomerSourceUpdatedEvent  = "customer.source.updated"
+
+	FeeRefundResource     = "fee_refund"
+	FeeRefundsList        = "application_fees/%s/refunds"
+	FeeRefundUpdatedEvent = "application_fee.refund.updated"
+
+	TaxIDResource     = "tax_id"
+	TaxIDsList        = "customers/%s/tax_ids"
+	TaxIDCreatedEvent = "customer.tax_id.created"
+	TaxIDDeletedEvent = "customer.tax_id.deleted"
+	TaxIDUpdatedEvent = "customer.tax_id.updated"
+)
+
+var (
+	CustomerSourceEvents = []string{
+		CustomerSourceCreatedEvent,
+		CustomerSourceDeletedEvent,
+		CustomerSourceExpiringEvent,
+		CustomerSourceUpdatedEvent,
+	}
+
+	FeeRefundEvents = []string{
+		FeeRefundUpdatedEvent,
+	}
+
+	TaxIDEvents = []string{
+		TaxIDCreatedEvent,
+		TaxIDDeletedEvent,
+		TaxIDUpdatedEvent,
+	}
+)
@@ -15,6 +15,10 @@
 package resources
 
 const (
+	// BalanceTransactionResource has no events, so its new objects are polled in the CDC mode.
+	BalanceTransactionResource = "balance_transaction"
+	BalanceTransactionsList    = "balance_transactions"
+
 	ChargeResource       = "charge"
 	ChargesList          = "charges"
 	ChargeCapturedEvent  = "charge.captured"
@@ -31,6 +35,10 @@ const (
 	CustomerDeletedEvent = "customer.deleted"
 	CustomerUpdatedEvent = "customer.updated"
 
+	// EventResource is the pseudo-resource of the events themselves, which are read as they are.
+	EventResource = "event"
+	EventsList    = "events"
+
 	DisputeResource             = "dispute"
 	DisputesList                = "disputes"
 	DisputeClosedEvent          = "charge.dispute.closed"
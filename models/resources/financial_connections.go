@@ -0,0 +1,37 @@
+// Copyright © 2022 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resources
+
+const (
+	FinancialConnectionsAccountResource                   = "financial_connections.account"
+	FinancialConnectionsAccountsList                      = "financial_connections/accounts"
+	FinancialConnectionsAccountCreatedEvent               = "financial_connections.account.created"
+	FinancialConnectionsAccountDeactivatedEvent           = "financial_connections.account.deactivated"
+	FinancialConnectionsAccountDisconnectedEvent          = "financial_connections.account.disconnected"
+	FinancialConnectionsAccountReactivatedEvent           = "financial_connections.account.reactivated"
+	FinancialConnectionsAccountRefreshedBalanceEvent      = "financial_connections.account.refreshed_balance"
+	FinancialConnectionsAccountRefreshedOwnershipEvent    = "financial_connections.account.refreshed_ownership"
+	FinancialConnectionsAccountRefreshedTransactionsEvent = "financial_connections.account.refreshed_transactions"
+)
+
+var FinancialConnectionsAccountEvents = []string{
+	FinancialConnectionsAccountCreatedEvent,
+	FinancialConnectionsAccountDeactivatedEvent,
+	FinancialConnectionsAccountDisconnectedEvent,
+	FinancialConnectionsAccountReactivatedEvent,
+	FinancialConnectionsAccountRefreshedBalanceEvent,
+	FinancialConnectionsAccountRefreshedOwnershipEvent,
+	FinancialConnectionsAccountRefreshedTransactionsEvent,
+}
@@ -0,0 +1,69 @@
+// Copyright © 2022 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resources
+
+const (
+	TreasuryFinancialAccountResource                   = "treasury.financial_account"
+	TreasuryFinancialAccountsList                      = "treasury/financial_accounts"
+	TreasuryFinancialAccountClosedEvent                = "treasury.financial_account.closed"
+	TreasuryFinancialAccountCreatedEvent               = "treasury.financial_account.created"
+	TreasuryFinancialAccountFeaturesStatusUpdatedEvent = "treasury.financial_account.features_status_updated"
+
+	TreasuryInboundTransferResource       = "treasury.inbound_transfer"
+	TreasuryInboundTransfersList          = "treasury/inbound_transfers"
+	TreasuryInboundTransferCanceledEvent  = "treasury.inbound_transfer.canceled"
+	TreasuryInboundTransferCreatedEvent   = "treasury.inbound_transfer.created"
+	TreasuryInboundTransferFailedEvent    = "treasury.inbound_transfer.failed"
+	TreasuryInboundTransferSucceededEvent = "treasury.inbound_transfer.succeeded"
+
+	TreasuryOutboundPaymentResource                        = "treasury.outbound_payment"
+	TreasuryOutboundPaymentsList                           = "treasury/outbound_payments"
+	TreasuryOutboundPaymentCanceledEvent                   = "treasury.outbound_payment.canceled"
+	TreasuryOutboundPaymentCreatedEvent                    = "treasury.outbound_payment.created"
+	TreasuryOutboundPaymentExpectedArrivalDateUpdatedEvent = "treasury.outbound_payment.expected_arrival_date_updated"
+	TreasuryOutboundPaymentFailedEvent                     = "treasury.outbound_payment.failed"
+	TreasuryOutboundPaymentPostedEvent                     = "treasury.outbound_payment.posted"
+	TreasuryOutboundPaymentReturnedEvent                   = "treasury.outbound_payment.returned"
+	TreasuryOutboundPaymentTrackingDetailsUpdatedEvent     = "treasury.outbound_payment.tracking_details_updated"
+
+	// TreasuryTransactionResource has no events, so its new objects are polled in the CDC mode.
+	TreasuryTransactionResource = "treasury.transaction"
+	TreasuryTransactionsList    = "treasury/transactions"
+)
+
+var (
+	TreasuryFinancialAccountEvents = []string{
+		TreasuryFinancialAccountClosedEvent,
+		TreasuryFinancialAccountCreatedEvent,
+		TreasuryFinancialAccountFeaturesStatusUpdatedEvent,
+	}
+
+	TreasuryInboundTransferEvents = []string{
+		TreasuryInboundTransferCanceledEvent,
+		TreasuryInboundTransferCreatedEvent,
+		TreasuryInboundTransferFailedEvent,
+		TreasuryInboundTransferSucceededEvent,
+	}
+
+	TreasuryOutboundPaymentEvents = []string{
+		TreasuryOutboundPaymentCanceledEvent,
+		TreasuryOutboundPaymentCreatedEvent,
+		TreasuryOutboundPaymentExpectedArrivalDateUpdatedEvent,
+		TreasuryOutboundPaymentFailedEvent,
+		TreasuryOutboundPaymentPostedEvent,
+		TreasuryOutboundPaymentReturnedEvent,
+		TreasuryOutboundPaymentTrackingDetailsUpdatedEvent,
+	}
+)
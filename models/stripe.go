@@ -15,6 +15,9 @@
 package models
 
 import (
+	"bytes"
+	"encoding/json"
+	"path"
 	"strings"
 
 	"github.com/conduitio-labs/conduit-connector-stripe/models/resources"
@@ -23,7 +26,27 @@ import (
 
 // A ResourceResponse represents a response resource data from Stripe.
 type ResourceResponse struct {
-	Data []map[string]interface{} `json:"data"`
+	Data    []map[string]interface{} `json:"data"`
+	HasMore bool                     `json:"has_more"`
+	// NextPage is the token of the next page of the search results, it is not set in the lists.
+	NextPage string `json:"next_page,omitempty"`
+}
+
+// A ListParams represents the parameters of a list request of the resource objects.
+type ListParams struct {
+	// ParentID is the identifier of the parent object of the list of a child resource.
+	ParentID string
+	// StartingAfter is the identifier of the object the list starts after.
+	StartingAfter string
+	// CreatedGTE is the Unix time the objects are created at or after, it is not set if it is zero.
+	CreatedGTE int64
+	// CreatedLT is the Unix time the objects are created before, it is not set if it is zero.
+	CreatedLT int64
+	// Filters are the filters of the list, where the key is the parameter and the value is its value.
+	Filters map[string]string
+	// WithoutExpand reports whether the configured expansions of the resource are not sent,
+	// such as for the lists of the parent objects, which only their identifiers are read from.
+	WithoutExpand bool
 }
 
 // A EventResponse represents a response event data from Stripe.
@@ -37,15 +60,64 @@ type EventsData []EventData
 
 // A EventData represents a data of event response.
 type EventData struct {
-	ID      string          `json:"id"`
-	Created int64           `json:"created"`
-	Data    EventDataObject `json:"data"`
-	Type    string          `json:"type"`
+	ID         string          `json:"id"`
+	Created    int64           `json:"created"`
+	Data       EventDataObject `json:"data"`
+	Type       string          `json:"type"`
+	Livemode   bool            `json:"livemode"`
+	APIVersion string          `json:"api_version"`
+	Request    EventRequest    `json:"request"`
+
+	// Raw is the event as it is received from Stripe, with all of its fields, such as `pending_webhooks`.
+	Raw json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON decodes the event, and keeps the event as it is in Raw.
+func (e *EventData) UnmarshalJSON(data []byte) error {
+	// an alias type without the method, to decode the object without recursion
+	type eventData EventData
+
+	// the numbers are decoded as json.Number, the same way as in the responses of the Stripe client
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	if err := dec.Decode((*eventData)(e)); err != nil {
+		return err
+	}
+
+	e.Raw = append(json.RawMessage(nil), data...)
+
+	return nil
+}
+
+// An EventRequest represents the API request which caused the event.
+type EventRequest struct {
+	ID             string `json:"id"`
+	IdempotencyKey string `json:"idempotency_key"`
+}
+
+// UnmarshalJSON decodes the request of the event, which is only the request identifier in the API versions
+// before 2017-05-25, and null if the event was not caused by an API request.
+func (r *EventRequest) UnmarshalJSON(data []byte) error {
+	var id string
+	if err := json.Unmarshal(data, &id); err == nil {
+		r.ID = id
+
+		return nil
+	}
+
+	// an alias type without the method, to decode the object without recursion
+	type eventRequest EventRequest
+
+	return json.Unmarshal(data, (*eventRequest)(r))
 }
 
 // An EventDataObject represents a full object of event data.
 type EventDataObject struct {
 	Object map[string]interface{} `json:"object"`
+	// PreviousAttributes are the values of the updated attributes prior to the event,
+	// they are sent only in the events of type `*.updated`.
+	PreviousAttributes map[string]interface{} `json:"previous_attributes,omitempty"`
 }
 
 // An ErrorResponse represents a response error from Stripe.
@@ -53,6 +125,7 @@ type ErrorResponse struct {
 	Error struct {
 		Message string `json:"message"`
 		Type    string `json:"type"`
+		Code    string `json:"code"`
 	} `json:"error"`
 }
 
@@ -75,6 +148,7 @@ var ResourcesMap = map[string]string{
 	resources.TransferResource:                    resources.TransfersList,
 	resources.ChargeResource:                      resources.ChargesList,
 	resources.CustomerResource:                    resources.CustomersList,
+	resources.BalanceTransactionResource:          resources.BalanceTransactionsList,
 	resources.DisputeResource:                     resources.DisputesList,
 	resources.FileResource:                        resources.FilesList,
 	resources.PaymentIntentResource:               resources.PaymentIntentsList,
@@ -89,6 +163,11 @@ var ResourcesMap = map[string]string{
 	resources.IssuingCardResource:                 resources.IssuingCardsList,
 	resources.IssuingDisputeResource:              resources.IssuingDisputesList,
 	resources.IssuingTransactionResource:          resources.IssuingTransactionsList,
+	resources.TreasuryFinancialAccountResource:    resources.TreasuryFinancialAccountsList,
+	resources.TreasuryInboundTransferResource:     resources.TreasuryInboundTransfersList,
+	resources.TreasuryOutboundPaymentResource:     resources.TreasuryOutboundPaymentsList,
+	resources.TreasuryTransactionResource:         resources.TreasuryTransactionsList,
+	resources.FinancialConnectionsAccountResource: resources.FinancialConnectionsAccountsList,
 	resources.OrderResource:                       resources.OrdersList,
 	resources.PaymentLinkResource:                 resources.PaymentLinksList,
 	resources.PaymentMethodResource:               resources.PaymentMethodsList,
@@ -101,6 +180,200 @@ var ResourcesMap = map[string]string{
 	resources.ReportingReportTypeResource:         resources.ReportingReportTypesList,
 	resources.ScheduledQueryRunResource:           resources.ScheduledQueryRunsList,
 	resources.TerminalReaderResource:              resources.TerminalReadersList,
+	resources.EventResource:                       resources.EventsList,
+	resources.CustomerBalanceTransactionResource:  resources.CustomerBalanceTransactionsList,
+	resources.CustomerSourceResource:              resources.CustomerSourcesList,
+	resources.FeeRefundResource:                   resources.FeeRefundsList,
+	resources.TaxIDResource:                       resources.TaxIDsList,
+}
+
+// A ChildResource represents a resource, which exists only under its parent objects,
+// such as the tax IDs of the customers.
+type ChildResource struct {
+	// Parent is the resource of the parent objects.
+	Parent string
+	// ParentField is the field of the objects of the child resource with the identifier of their parent object.
+	ParentField string
+}
+
+// ChildResourcesMap represents a dictionary with the child resources,
+// where the key is the child resource and the value is its parent,
+// the lists of the child resources in ResourcesMap are the formats of the paths with the identifier of the parent.
+var ChildResourcesMap = map[string]ChildResource{
+	resources.CustomerBalanceTransactionResource: {Parent: resources.CustomerResource, ParentField: "customer"},
+	resources.CustomerSourceResource:             {Parent: resources.CustomerResource, ParentField: "customer"},
+	resources.FeeRefundResource:                  {Parent: resources.ApplicationFeeResource, ParentField: "fee"},
+	resources.TaxIDResource:                      {Parent: resources.CustomerResource, ParentField: "customer"},
+}
+
+// MatchEventType reports whether the event type matches any of the patterns, such as `invoice.*` or `*.failed`,
+// where the wildcard matches any sequence of characters, including dots.
+// Any event type matches if there are no patterns.
+func MatchEventType(patterns []string, eventType string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+
+	for _, pattern := range patterns {
+		// the event types contain no slashes, so the wildcard of the path pattern matches any of their characters
+		if ok, _ := path.Match(pattern, eventType); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ParentID returns the identifier of the parent object of the object of the child resource,
+// or an empty string if the resource is not a child resource, or the object has no parent.
+func ParentID(resourceName string, object map[string]interface{}) string {
+	child, ok := ChildResourcesMap[resourceName]
+	if !ok {
+		return ""
+	}
+
+	switch parent := object[child.ParentField].(type) {
+	case string:
+		return parent
+	case map[string]interface{}:
+		// the parent object is expanded
+		id, _ := parent[KeyID].(string)
+
+		return id
+	default:
+		return ""
+	}
+}
+
+// ResourcesWithoutCreatedFilter represents a set of the resources,
+// whose lists cannot be filtered by the time of creation of the objects.
+var ResourcesWithoutCreatedFilter = map[string]struct{}{
+	resources.BillingPortalConfigurationResource:  {},
+	resources.OrderResource:                       {},
+	resources.PaymentLinkResource:                 {},
+	resources.PaymentMethodResource:               {},
+	resources.QuoteResource:                       {},
+	resources.ReportingReportTypeResource:         {},
+	resources.ScheduledQueryRunResource:           {},
+	resources.TerminalReaderResource:              {},
+	resources.TreasuryInboundTransferResource:     {},
+	resources.FinancialConnectionsAccountResource: {},
+	resources.CustomerBalanceTransactionResource:  {},
+	resources.CustomerSourceResource:              {},
+	resources.FeeRefundResource:                   {},
+	resources.TaxIDResource:                       {},
+}
+
+// PolledResources represents a set of the resources without events,
+// whose new objects are received by polling their lists in the CDC mode.
+var PolledResources = map[string]struct{}{
+	resources.BalanceTransactionResource:  {},
+	resources.TreasuryTransactionResource: {},
+}
+
+// SnapshotOnlyResources represents a set of the resources without events, which cannot be polled either,
+// because they are listed only under their parent objects, so they are read only by the snapshot.
+var SnapshotOnlyResources = map[string]struct{}{
+	resources.CustomerBalanceTransactionResource: {},
+}
+
+// RequiredListFiltersMap represents a dictionary with the list filters required by the list endpoints of the resources,
+// where the key is a resource name and the value is a slice of the filters.
+var RequiredListFiltersMap = map[string][]string{
+	resources.TreasuryInboundTransferResource: {"financial_account"},
+	resources.TreasuryOutboundPaymentResource: {"financial_account"},
+	resources.TreasuryTransactionResource:     {"financial_account"},
+}
+
+// SearchResources represents a set of the resources, which can be searched with the Stripe Search API.
+var SearchResources = map[string]struct{}{
+	resources.ChargeResource:        {},
+	resources.CustomerResource:      {},
+	resources.InvoiceResource:       {},
+	resources.PaymentIntentResource: {},
+	resources.PriceResource:         {},
+	resources.ProductResource:       {},
+	resources.SubscriptionResource:  {},
+}
+
+// A NestedList represents a list nested in the objects of a resource, which Stripe truncates to its first page.
+type NestedList struct {
+	// Field is the field of the list in the object, it is not set in the object if the list is not included by default.
+	Field string
+	// PathFmt is the format of the path of the list relative to the API path, with the identifier of the object.
+	PathFmt string
+}
+
+// NestedListsMap represents a dictionary with the nested lists of the resources,
+// where the key is the resource and the value is a slice of the lists nested in its objects.
+var NestedListsMap = map[string][]NestedList{
+	resources.CheckoutSessionResource: {{Field: "line_items", PathFmt: "/checkout/sessions/%s/line_items"}},
+	resources.CreditNoteResource:      {{Field: "lines", PathFmt: "/credit_notes/%s/lines"}},
+	resources.InvoiceResource:         {{Field: "lines", PathFmt: "/invoices/%s/lines"}},
+	resources.PaymentLinkResource:     {{Field: "line_items", PathFmt: "/payment_links/%s/line_items"}},
+	resources.QuoteResource:           {{Field: "line_items", PathFmt: "/quotes/%s/line_items"}},
+	resources.SubscriptionResource:    {{Field: "items", PathFmt: "/subscription_items?subscription=%s"}},
+}
+
+// NestedListResources represents a set of the resources of the records of the nested lists,
+// which are the resources and the fields of the lists, such as `invoice.lines`.
+var NestedListResources = (func() map[string]struct{} {
+	nestedListResources := make(map[string]struct{})
+
+	for resource, lists := range NestedListsMap {
+		for _, list := range lists {
+			nestedListResources[resource+"."+list.Field] = struct{}{}
+		}
+	}
+
+	return nestedListResources
+})()
+
+// ListFiltersMap represents a dictionary with the filters of the lists of the resources,
+// where the key is the resource and the value is a slice of the parameters its list can be filtered by.
+var ListFiltersMap = map[string][]string{
+	resources.CreditNoteResource:                  {"customer", "invoice"},
+	resources.BillingPortalConfigurationResource:  {"active", "is_default"},
+	resources.InvoiceResource:                     {"collection_method", "customer", "status", "subscription"},
+	resources.InvoiceItemResource:                 {"customer", "invoice", "pending"},
+	resources.PlanResource:                        {"active", "product"},
+	resources.QuoteResource:                       {"customer", "status", "test_clock"},
+	resources.SubscriptionResource:                {"collection_method", "customer", "price", "status", "test_clock"},
+	resources.SubscriptionScheduleResource:        {"customer", "scheduled"},
+	resources.CheckoutSessionResource:             {"customer", "payment_intent", "payment_link", "status", "subscription"},
+	resources.ApplicationFeeResource:              {"charge"},
+	resources.TopUpResource:                       {"status"},
+	resources.TransferResource:                    {"destination", "transfer_group"},
+	resources.ChargeResource:                      {"customer", "payment_intent", "transfer_group"},
+	resources.CustomerResource:                    {"email", "test_clock"},
+	resources.BalanceTransactionResource:          {"currency", "payout", "source", "type"},
+	resources.DisputeResource:                     {"charge", "payment_intent"},
+	resources.FileResource:                        {"purpose"},
+	resources.PaymentIntentResource:               {"customer"},
+	resources.SetupIntentResource:                 {"customer", "payment_method"},
+	resources.PayoutResource:                      {"destination", "status"},
+	resources.RefundResource:                      {"charge", "payment_intent"},
+	resources.RadarEarlyFraudWarningResource:      {"charge", "payment_intent"},
+	resources.IdentityVerificationSessionResource: {"client_reference_id", "status"},
+	resources.IssuingAuthorizationResource:        {"card", "cardholder", "status"},
+	resources.IssuingCardholderResource:           {"email", "phone_number", "status", "type"},
+	resources.IssuingCardResource:                 {"cardholder", "exp_month", "exp_year", "last4", "status", "type"},
+	resources.IssuingDisputeResource:              {"status", "transaction"},
+	resources.IssuingTransactionResource:          {"card", "cardholder", "type"},
+	resources.TreasuryInboundTransferResource:     {"financial_account", "status"},
+	resources.TreasuryOutboundPaymentResource:     {"customer", "financial_account", "status"},
+	resources.TreasuryTransactionResource:         {"financial_account", "status"},
+	resources.FinancialConnectionsAccountResource: {"session"},
+	resources.OrderResource:                       {"customer"},
+	resources.PaymentLinkResource:                 {"active"},
+	resources.PaymentMethodResource:               {"customer", "type"},
+	resources.ProductResource:                     {"active", "shippable", "url"},
+	resources.PriceResource:                       {"active", "currency", "product", "type"},
+	resources.PromotionCodeResource:               {"active", "code", "coupon", "customer"},
+	resources.TaxRateResource:                     {"active", "inclusive"},
+	resources.TerminalReaderResource:              {"device_type", "location", "serial_number", "status"},
+	resources.CustomerSourceResource:              {"object"},
+	resources.EventResource:                       {"delivery_success", "type"},
 }
 
 // EventsMap represents a dictionary with all events in each resource,
@@ -134,6 +407,10 @@ var EventsMap = map[string][]string{
 	resources.IssuingCardResource:                 resources.IssuingCardEvents,
 	resources.IssuingDisputeResource:              resources.IssuingDisputeEvents,
 	resources.IssuingTransactionResource:          resources.IssuingTransactionEvents,
+	resources.TreasuryFinancialAccountResource:    resources.TreasuryFinancialAccountEvents,
+	resources.TreasuryInboundTransferResource:     resources.TreasuryInboundTransferEvents,
+	resources.TreasuryOutboundPaymentResource:     resources.TreasuryOutboundPaymentEvents,
+	resources.FinancialConnectionsAccountResource: resources.FinancialConnectionsAccountEvents,
 	resources.OrderResource:                       resources.OrderEvents,
 	resources.PaymentLinkResource:                 resources.PaymentLinkEvents,
 	resources.PaymentMethodResource:               resources.PaymentMethodEvents,
@@ -146,6 +423,9 @@ var EventsMap = map[string][]string{
 	resources.ReportingReportTypeResource:         resources.ReportingReportTypeEvents,
 	resources.ScheduledQueryRunResource:           resources.ScheduledQueryRunEvents,
 	resources.TerminalReaderResource:              resources.TerminalReaderEvents,
+	resources.CustomerSourceResource:              resources.CustomerSourceEvents,
+	resources.FeeRefundResource:                   resources.FeeRefundEvents,
+	resources.TaxIDResource:                       resources.TaxIDEvents,
 }
 
 // EventsOperation represents a dictionary with operations of events,
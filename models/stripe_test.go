@@ -0,0 +1,126 @@
+// Copyright © 2022 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestEventRequest_UnmarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		in   string
+		want EventRequest
+	}{
+		{
+			name: "object",
+			in:   `{"id":"req_sKVzcHmVp5Ybo3","idempotency_key":"8a5f1b5e"}`,
+			want: EventRequest{ID: "req_sKVzcHmVp5Ybo3", IdempotencyKey: "8a5f1b5e"},
+		},
+		{
+			name: "identifier_before_2017_05_25",
+			in:   `"req_sKVzcHmVp5Ybo3"`,
+			want: EventRequest{ID: "req_sKVzcHmVp5Ybo3"},
+		},
+		{
+			name: "null",
+			in:   `null`,
+			want: EventRequest{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			is := is.New(t)
+
+			var event EventData
+
+			is.NoErr(json.Unmarshal([]byte(`{"id":"evt_1652447199","request":`+tt.in+`}`), &event))
+			is.Equal(event.Request, tt.want)
+		})
+	}
+}
+
+func TestEventData_UnmarshalJSON(t *testing.T) {
+	t.Parallel()
+	is := is.New(t)
+
+	in := `{"id":"evt_1","type":"invoice.paid","pending_webhooks":2,` +
+		`"data":{"object":{"id":"in_1","total":9007199254740993}}}`
+
+	var event EventData
+	is.NoErr(json.Unmarshal([]byte(in), &event))
+
+	is.Equal(event.ID, "evt_1")
+	is.Equal(event.Type, "invoice.paid")
+	is.Equal(event.Data.Object["total"], json.Number("9007199254740993"))
+	is.Equal(string(event.Raw), in)
+}
+
+func TestMatchEventType(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		patterns  []string
+		eventType string
+		want      bool
+	}{
+		{
+			name:      "no_patterns",
+			eventType: "invoice.paid",
+			want:      true,
+		},
+		{
+			name:      "prefix",
+			patterns:  []string{"invoice.*"},
+			eventType: "invoice.payment_failed",
+			want:      true,
+		},
+		{
+			name:      "suffix_with_dots",
+			patterns:  []string{"customer.*", "*.failed"},
+			eventType: "issuing_dispute.funds.failed",
+			want:      true,
+		},
+		{
+			name:      "exact",
+			patterns:  []string{"charge.succeeded"},
+			eventType: "charge.succeeded",
+			want:      true,
+		},
+		{
+			name:      "no_match",
+			patterns:  []string{"invoice.*", "*.failed"},
+			eventType: "charge.succeeded",
+			want:      false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			is := is.New(t)
+
+			is.Equal(MatchEventType(tt.patterns, tt.eventType), tt.want)
+		})
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright © 2022 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// daySuffix is the suffix of the durations in days, which are not supported by time.ParseDuration.
+const daySuffix = "d"
+
+var errNegativeTimeBound = errors.New("the duration must be positive")
+
+// A TimeBound represents a bound of the time of creation of the objects,
+// which is either an absolute time, or a duration before a reference time.
+type TimeBound struct {
+	// Time is the absolute time of the bound.
+	Time time.Time
+	// Before is the duration of the bound before the reference time.
+	Before time.Duration
+}
+
+// ParseTimeBound parses the time bound, which is an RFC 3339 time, such as `2024-01-01T00:00:00Z`,
+// or a duration, such as `90d` or `36h`. An empty string is a zero time bound.
+func ParseTimeBound(s string) (TimeBound, error) {
+	if s == "" {
+		return TimeBound{}, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return TimeBound{Time: t}, nil
+	}
+
+	var (
+		d   time.Duration
+		err error
+	)
+
+	if days, ok := strings.CutSuffix(s, daySuffix); ok {
+		var n int
+
+		n, err = strconv.Atoi(days)
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		d, err = time.ParseDuration(s)
+	}
+
+	if err != nil {
+		return TimeBound{}, fmt.Errorf("%q is neither an RFC 3339 time nor a duration", s)
+	}
+
+	if d <= 0 {
+		return TimeBound{}, fmt.Errorf("%q: %w", s, errNegativeTimeBound)
+	}
+
+	return TimeBound{Before: d}, nil
+}
+
+// IsZero reports whether the time bound is not set.
+func (b TimeBound) IsZero() bool {
+	return b.Time.IsZero() && b.Before == 0
+}
+
+// Unix returns the Unix time of the bound, where the duration is subtracted from the reference Unix time,
+// or zero if the time bound is not set.
+func (b TimeBound) Unix(reference int64) int64 {
+	switch {
+	case !b.Time.IsZero():
+		return b.Time.Unix()
+	case b.Before != 0:
+		return reference - int64(b.Before/time.Second)
+	}
+
+	return 0
+}
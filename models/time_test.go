@@ -0,0 +1,92 @@
+// Copyright © 2022 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestParseTimeBound(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		in      string
+		want    TimeBound
+		wantErr bool
+	}{
+		{
+			name: "empty",
+			in:   "",
+			want: TimeBound{},
+		},
+		{
+			name: "rfc3339",
+			in:   "2024-01-01T00:00:00Z",
+			want: TimeBound{Time: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			name: "days",
+			in:   "90d",
+			want: TimeBound{Before: 90 * 24 * time.Hour},
+		},
+		{
+			name: "duration",
+			in:   "36h",
+			want: TimeBound{Before: 36 * time.Hour},
+		},
+		{
+			name:    "negative_duration",
+			in:      "-1h",
+			wantErr: true,
+		},
+		{
+			name:    "invalid",
+			in:      "yesterday",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			is := is.New(t)
+
+			got, err := ParseTimeBound(tt.in)
+			if tt.wantErr {
+				is.True(err != nil)
+
+				return
+			}
+
+			is.NoErr(err)
+			is.Equal(got, tt.want)
+		})
+	}
+}
+
+func TestTimeBound_Unix(t *testing.T) {
+	t.Parallel()
+	is := is.New(t)
+
+	const reference = 1652790765
+
+	is.Equal(TimeBound{}.Unix(reference), int64(0))
+	is.Equal(TimeBound{Before: time.Hour}.Unix(reference), int64(reference-3600))
+	is.Equal(TimeBound{Time: time.Unix(1651153850, 0)}.Unix(reference), int64(1651153850))
+}
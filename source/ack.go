@@ -0,0 +1,108 @@
+// Copyright © 2022 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package source
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+)
+
+// errUnknownPosition occurs when the acknowledged position does not belong to any of the records read.
+var errUnknownPosition = errors.New("acknowledged position was not read")
+
+// An inFlight represents the positions of the records which were read, but not committed yet.
+type inFlight struct {
+	mu sync.Mutex
+
+	// positions are the positions of the records which are not committed, in the order they were read.
+	positions []opencdc.Position
+	// unacked is the number of the records read but not acknowledged, where the key is the position.
+	unacked map[string]int
+	// acked is the number of the records acknowledged but not committed, where the key is the position.
+	acked map[string]int
+	// committed is the last position, up to which all records are acknowledged.
+	committed opencdc.Position
+}
+
+// Add adds the position of the record read.
+func (f *inFlight) Add(position opencdc.Position) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.unacked == nil {
+		f.unacked = make(map[string]int)
+		f.acked = make(map[string]int)
+	}
+
+	f.positions = append(f.positions, position)
+	f.unacked[string(position)]++
+}
+
+// Ack acknowledges the position, and commits the lowest contiguous acknowledged positions.
+// It returns the positions committed by the acknowledgment in the order they were read,
+// or errUnknownPosition if the position was not read.
+func (f *inFlight) Ack(position opencdc.Position) ([]opencdc.Position, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := string(position)
+
+	if f.unacked[key] == 0 {
+		return nil, errUnknownPosition
+	}
+
+	decrement(f.unacked, key)
+	f.acked[key]++
+
+	var committed []opencdc.Position
+
+	// commit the positions from the oldest one, until one which is not acknowledged
+	for len(f.positions) > 0 && f.acked[string(f.positions[0])] > 0 {
+		decrement(f.acked, string(f.positions[0]))
+
+		f.committed = f.positions[0]
+		f.positions = f.positions[1:]
+
+		committed = append(committed, f.committed)
+	}
+
+	return committed, nil
+}
+
+// Len returns the number of the records, which are not committed.
+func (f *inFlight) Len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return len(f.positions)
+}
+
+// Committed returns the last position, up to which all records are acknowledged.
+func (f *inFlight) Committed() opencdc.Position {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.committed
+}
+
+// decrement decrements the counter of the key, and deletes the key if the counter is zero.
+func decrement(counters map[string]int, key string) {
+	counters[key]--
+	if counters[key] == 0 {
+		delete(counters, key)
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright © 2022 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package source
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/conduitio-labs/conduit-connector-stripe/source/mock"
+	"github.com/conduitio/conduit-commons/opencdc"
+	"github.com/matryer/is"
+	"go.uber.org/mock/gomock"
+)
+
+func TestInFlight_Ack(t *testing.T) {
+	is := is.New(t)
+
+	var (
+		first  = opencdc.Position(`{"mode":"cdc","cursor":"","index":1}`)
+		second = opencdc.Position(`{"mode":"cdc","cursor":"","index":2}`)
+		third  = opencdc.Position(`{"mode":"cdc","cursor":"evt_1652447199","index":0}`)
+	)
+
+	f := &inFlight{}
+	f.Add(first)
+	f.Add(second)
+	f.Add(third)
+
+	// the second record is acknowledged before the first one, so nothing is committed
+	committed, err := f.Ack(second)
+	is.NoErr(err)
+	is.Equal(len(committed), 0)
+	is.Equal(f.Len(), 3)
+
+	committed, err = f.Ack(first)
+	is.NoErr(err)
+	is.Equal(committed, []opencdc.Position{first, second})
+	is.Equal(f.Committed(), second)
+	is.Equal(f.Len(), 1)
+
+	_, err = f.Ack(second)
+	is.True(errors.Is(err, errUnknownPosition))
+
+	committed, err = f.Ack(third)
+	is.NoErr(err)
+	is.Equal(committed, []opencdc.Position{third})
+	is.Equal(f.Len(), 0)
+}
+
+func TestSource_Ack(t *testing.T) {
+	is := is.New(t)
+
+	var (
+		first  = opencdc.Position(`{"mode":"cdc","cursor":"","index":1}`)
+		second = opencdc.Position(`{"mode":"cdc","cursor":"","index":2}`)
+	)
+
+	ctrl := gomock.NewController(t)
+
+	// the iterator gets the committed positions in the order of the records
+	it := mock.NewMockIterator(ctrl)
+	gomock.InOrder(
+		it.EXPECT().Ack(gomock.Any(), first).Return(nil),
+		it.EXPECT().Ack(gomock.Any(), second).Return(nil),
+	)
+
+	source := &Source{iterator: it, inFlight: &inFlight{}}
+	source.inFlight.Add(first)
+	source.inFlight.Add(second)
+
+	is.NoErr(source.Ack(context.Background(), second))
+	is.NoErr(source.Ack(context.Background(), first))
+}
+
+func TestSource_AckUnknownPosition(t *testing.T) {
+	is := is.New(t)
+
+	source := &Source{inFlight: &inFlight{}}
+
+	// the position read before the source was reopened is ignored
+	err := source.Ack(context.Background(), opencdc.Position(`{"mode":"cdc","cursor":"evt_1652447199","index":0}`))
+	is.NoErr(err)
+}
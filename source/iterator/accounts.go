@@ -0,0 +1,155 @@
+// Copyright © 2022 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+
+	"github.com/conduitio-labs/conduit-connector-stripe/models"
+	"github.com/conduitio/conduit-commons/opencdc"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+)
+
+// A ConnectedAccounts represents a struct of iterator of the connected accounts,
+// which reads each account with its own iterator and position.
+type ConnectedAccounts struct {
+	iterators map[string]*Iterator
+	accounts  []string
+	position  *Position
+
+	// unacked are the iterators of the accounts of the records returned, which are not acknowledged yet,
+	// in the order of the records.
+	unacked []*Iterator
+}
+
+// NewConnectedAccounts initializes an iterator of the resources of the connected accounts,
+// where the key of the stripeSvcs is the account identifier, and the value is the Stripe client of the account.
+func NewConnectedAccounts(
+	stripeSvcs map[string]Stripe, pos *Position, opts Options,
+) *ConnectedAccounts {
+	accounts := make([]string, 0, len(stripeSvcs))
+	for account := range stripeSvcs {
+		accounts = append(accounts, account)
+	}
+
+	sort.Strings(accounts)
+
+	if pos.Accounts == nil {
+		pos.Accounts = make(map[string]*Position, len(accounts))
+	}
+
+	iterators := make(map[string]*Iterator, len(accounts))
+
+	for _, account := range accounts {
+		// the account is new, or it is the first run of the connector
+		if _, ok := pos.Accounts[account]; !ok {
+			pos.Accounts[account] = newPosition()
+		}
+
+		accountOpts := opts
+		accountOpts.Account = account
+
+		iterators[account] = New(stripeSvcs[account], pos.Accounts[account], accountOpts)
+	}
+
+	if !slices.Contains(accounts, pos.Account) && len(accounts) > 0 {
+		pos.Account = accounts[0]
+	}
+
+	return &ConnectedAccounts{
+		iterators: iterators,
+		accounts:  accounts,
+		position:  pos,
+	}
+}
+
+// Next returns the next record of the current account.
+// If the current account has no records, it moves on to the next account,
+// and returns sdk.ErrBackoffRetry only if none of the accounts has records.
+func (iter *ConnectedAccounts) Next(ctx context.Context) (opencdc.Record, error) {
+	for range iter.accounts {
+		record, err := iter.iterators[iter.position.Account].Next(ctx)
+		if err != nil {
+			if errors.Is(err, sdk.ErrBackoffRetry) {
+				iter.nextAccount()
+
+				continue
+			}
+
+			return opencdc.Record{}, fmt.Errorf("read connected account %s: %w", iter.position.Account, err)
+		}
+
+		record.Metadata[models.MetadataAccount] = iter.position.Account
+
+		record.Position, err = iter.recordPosition(record.Position)
+		if err != nil {
+			return opencdc.Record{}, fmt.Errorf("build record position: %w", err)
+		}
+
+		iter.unacked = append(iter.unacked, iter.iterators[iter.position.Account])
+
+		return record, nil
+	}
+
+	return opencdc.Record{}, sdk.ErrBackoffRetry
+}
+
+// Ack acknowledges the oldest record returned, which is not acknowledged yet, with the iterator of its account.
+func (iter *ConnectedAccounts) Ack(ctx context.Context, position opencdc.Position) error {
+	if len(iter.unacked) == 0 {
+		return nil
+	}
+
+	return dequeue(&iter.unacked).Ack(ctx, position)
+}
+
+// Stop stops the iterators of the accounts, which write their local states.
+func (iter *ConnectedAccounts) Stop(ctx context.Context) error {
+	for _, account := range iter.accounts {
+		if err := iter.iterators[account].Stop(ctx); err != nil {
+			return fmt.Errorf("stop connected account %s: %w", account, err)
+		}
+	}
+
+	return nil
+}
+
+// recordPosition returns the position of the accounts with the position of the record of the current account,
+// because the record may be behind the position of the account, such as the records of the nested lists.
+func (iter *ConnectedAccounts) recordPosition(position opencdc.Position) (opencdc.Position, error) {
+	accountPos, err := ParseSDKPosition(position)
+	if err != nil {
+		return nil, err
+	}
+
+	pos := *iter.position
+
+	pos.Accounts = maps.Clone(iter.position.Accounts)
+	pos.Accounts[iter.position.Account] = accountPos
+
+	return pos.marshalPosition()
+}
+
+// nextAccount moves the position to the next account in the alphabetical order.
+func (iter *ConnectedAccounts) nextAccount() {
+	i := slices.Index(iter.accounts, iter.position.Account)
+
+	iter.position.Account = iter.accounts[(i+1)%len(iter.accounts)]
+}
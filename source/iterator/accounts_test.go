@@ -0,0 +1,126 @@
+// Copyright © 2022 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/conduitio-labs/conduit-connector-stripe/models"
+	"github.com/conduitio-labs/conduit-connector-stripe/models/resources"
+	"github.com/conduitio-labs/conduit-connector-stripe/source/iterator/mock"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"go.uber.org/mock/gomock"
+)
+
+func TestConnectedAccountsIterator_Next(t *testing.T) {
+	const (
+		accountFirst  = "acct_1032D82eZvKYlo2C"
+		accountSecond = "acct_1LajCFJit566F2Yt"
+	)
+
+	var (
+		ctrl = gomock.NewController(t)
+
+		mFirst  = mock.NewMockStripe(ctrl)
+		mSecond = mock.NewMockStripe(ctrl)
+	)
+
+	eventFirst := models.EventData{
+		ID:      "evt_1652447179",
+		Created: 1652447179,
+		Data: models.EventDataObject{Object: map[string]interface{}{
+			models.KeyID: "cus_1651153850",
+		}},
+		Type: resources.CustomerCreatedEvent,
+	}
+
+	eventSecond := models.EventData{
+		ID:      "evt_1652447199",
+		Created: 1652447199,
+		Data: models.EventDataObject{Object: map[string]interface{}{
+			models.KeyID: "cus_1651153899",
+		}},
+		Type: resources.CustomerCreatedEvent,
+	}
+
+	var (
+		createdAtFirst  = time.Now().Unix()
+		createdAtSecond = createdAtFirst + 34
+	)
+
+	pos := &Position{
+		Accounts: map[string]*Position{
+			accountFirst:  {IteratorMode: modeCDC, CreatedAt: createdAtFirst},
+			accountSecond: {IteratorMode: modeCDC, CreatedAt: createdAtSecond},
+		},
+	}
+
+	gomock.InOrder(
+		mFirst.EXPECT().GetEvent(createdAtFirst, "", "").
+			Return(models.EventResponse{Data: models.EventsData{eventFirst}}, nil),
+		mFirst.EXPECT().GetEvent(createdAtFirst, "", eventFirst.ID).Return(models.EventResponse{}, nil),
+		mSecond.EXPECT().GetEvent(createdAtSecond, "", "").
+			Return(models.EventResponse{Data: models.EventsData{eventSecond}}, nil),
+		mSecond.EXPECT().GetEvent(createdAtSecond, "", eventSecond.ID).Return(models.EventResponse{}, nil),
+		mFirst.EXPECT().GetEvent(createdAtFirst, "", eventFirst.ID).Return(models.EventResponse{}, nil),
+	)
+
+	expectNoExpansion(mFirst)
+	expectNoExpansion(mSecond)
+
+	iter := NewConnectedAccounts(map[string]Stripe{
+		accountFirst:  mFirst,
+		accountSecond: mSecond,
+	}, pos, Options{ResourceNames: []string{resources.CustomerResource}})
+
+	for _, want := range []struct {
+		account string
+		event   models.EventData
+	}{
+		{account: accountFirst, event: eventFirst},
+		{account: accountSecond, event: eventSecond},
+	} {
+		record, err := iter.Next(context.Background())
+		if err != nil {
+			t.Fatalf("next error = \"%s\"", err.Error())
+		}
+
+		if record.Metadata[models.MetadataAccount] != want.account {
+			t.Errorf("account: got = %v, want %v", record.Metadata[models.MetadataAccount], want.account)
+		}
+
+		rp, err := pos.marshalPosition()
+		if err != nil {
+			t.Errorf("format sdk position error = \"%s\"", err.Error())
+		}
+
+		if !reflect.DeepEqual(record.Position, rp) {
+			t.Errorf("position: got = %v, want %v", string(record.Position), string(rp))
+		}
+
+		if pos.Accounts[want.account].Cursor != want.event.ID {
+			t.Errorf("cursor: got = %v, want %v", pos.Accounts[want.account].Cursor, want.event.ID)
+		}
+	}
+
+	_, err := iter.Next(context.Background())
+	if !errors.Is(err, sdk.ErrBackoffRetry) {
+		t.Errorf("expected error \"%s\", got \"%v\"", sdk.ErrBackoffRetry, err)
+	}
+}
@@ -15,61 +15,222 @@
 package iterator
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
+	"strconv"
 	"time"
 
 	"github.com/conduitio-labs/conduit-connector-stripe/models"
+	"github.com/conduitio-labs/conduit-connector-stripe/models/resources"
+	"github.com/conduitio-labs/conduit-connector-stripe/stripe"
 	"github.com/conduitio/conduit-commons/opencdc"
 	sdk "github.com/conduitio/conduit-connector-sdk"
 )
 
+// ErrRetentionGap occurs when the events since the position are no longer retained by Stripe,
+// so the changes of the resources between the position and the oldest retained event are lost.
+var ErrRetentionGap = errors.New("the events since the position are no longer retained by Stripe")
+
 // A CDC represents a struct of cdc iterator.
 type CDC struct {
 	stripeSvc Stripe
 	position  *Position
 
+	// eventsResource is a dictionary of the configured resources,
+	// where the key is an event type and the value is the resource name.
+	eventsResource map[string]string
+
+	// events reports whether the events themselves are read as the event resource,
+	// and eventTypes are the patterns of their types.
+	events     bool
+	eventTypes []string
+
+	// structuredPayload reports whether the payloads are structured data.
+	structuredPayload bool
+
 	// eventData is a slice of the event data from the Stripe response.
 	eventData []models.EventData
+
+	// retentionChecked reports whether the position was checked against the retention of the events.
+	retentionChecked bool
+
+	// nested is the reader of the lists nested in the objects.
+	nested nestedLists
+	// pending are the records of the nested lists and their event, which are not returned yet.
+	pending []opencdc.Record
 }
 
-// NewCDC initializes cdc iterator.
-func NewCDC(stripeSvc Stripe, pos *Position) *CDC {
+// NewCDC initializes cdc iterator of the resources.
+func NewCDC(stripeSvc Stripe, pos *Position, opts Options) *CDC {
+	eventsResource := make(map[string]string)
+
+	for _, resourceName := range opts.ResourceNames {
+		for _, event := range models.EventsMap[resourceName] {
+			eventsResource[event] = resourceName
+		}
+	}
+
 	return &CDC{
-		stripeSvc: stripeSvc,
-		position:  pos,
+		stripeSvc:         stripeSvc,
+		position:          pos,
+		eventsResource:    eventsResource,
+		events:            slices.Contains(opts.ResourceNames, resources.EventResource),
+		eventTypes:        opts.EventTypes,
+		structuredPayload: opts.StructuredPayload,
+		nested:            newNestedLists(stripeSvc, opts),
 	}
 }
 
 // Next returns the next record.
+// Events of resources which are not configured are skipped.
 func (i *CDC) Next() (opencdc.Record, error) {
-	if i.eventData == nil || i.position.Index == 0 {
-		if err := i.getData(); err != nil {
-			return opencdc.Record{}, fmt.Errorf("get event data: %w", err)
+	if len(i.pending) > 0 {
+		return dequeue(&i.pending), nil
+	}
+
+	previous, err := i.previousPosition()
+	if err != nil {
+		return opencdc.Record{}, err
+	}
+
+	event, resourceName, err := i.nextEvent()
+	if err != nil {
+		return opencdc.Record{}, err
+	}
+
+	return i.buildRecords(event, resourceName, previous)
+}
+
+// previousPosition returns the position before the next event, which the records of its nested lists have,
+// if the objects of the nested lists are read as records.
+func (i *CDC) previousPosition() (opencdc.Position, error) {
+	if !i.nested.records {
+		return nil, nil
+	}
+
+	position, err := i.position.marshalPosition()
+	if err != nil {
+		return nil, fmt.Errorf("build record position: %w", err)
+	}
+
+	return position, nil
+}
+
+// nextEvent returns the next event of the configured resources, and the name of its resource.
+func (i *CDC) nextEvent() (models.EventData, string, error) {
+	for {
+		if i.eventData == nil || i.position.Index == 0 {
+			if err := i.getData(); err != nil {
+				return models.EventData{}, "", fmt.Errorf("get event data: %w", err)
+			}
+
+			if len(i.eventData) == 0 {
+				return models.EventData{}, "", sdk.ErrBackoffRetry
+			}
 		}
 
-		if len(i.eventData) == 0 {
-			return opencdc.Record{}, sdk.ErrBackoffRetry
+		event := i.eventData[i.position.Index]
+
+		i.position.Index++
+
+		// update `Cursor` in the position if it is the last element of the resulting slice
+		if len(i.eventData) == i.position.Index {
+			i.position.Index = 0
+			i.position.Cursor = i.eventData[len(i.eventData)-1].ID
 		}
+
+		resourceName, ok := i.eventResource(event.Type)
+		if !ok {
+			continue
+		}
+
+		return event, resourceName, nil
 	}
+}
 
-	metadata := i.buildRecordMetadata()
+// hasEvents reports whether any of the configured resources has events.
+func (i *CDC) hasEvents() bool {
+	return i.events || len(i.eventsResource) > 0
+}
 
-	key := i.buildRecordKey()
+// eventResource returns the name of the configured resource of the event type,
+// which is the event resource if the events themselves are read and the type matches their patterns,
+// or false if the resource of the event type is not configured.
+func (i *CDC) eventResource(eventType string) (string, bool) {
+	if i.events {
+		return resources.EventResource, models.MatchEventType(i.eventTypes, eventType)
+	}
+
+	resourceName, ok := i.eventsResource[eventType]
 
-	payload, err := i.buildRecordPayload()
+	return resourceName, ok
+}
+
+// buildRecords returns the first record of the event of the resource, which is the record of its first nested object,
+// if the objects of the nested lists are read as records, or the record of the event otherwise.
+// The rest of the records are returned by the next calls, with the record of the event being the last one.
+func (i *CDC) buildRecords(
+	event models.EventData, resourceName string, previous opencdc.Position,
+) (opencdc.Record, error) {
+	record, err := i.buildRecord(event, resourceName)
 	if err != nil {
-		return opencdc.Record{}, fmt.Errorf("build record payload: %w", err)
+		return opencdc.Record{}, err
+	}
+
+	// the nested lists of the deleted objects cannot be retrieved
+	if record.Operation == opencdc.OperationDelete {
+		return record, nil
+	}
+
+	nested, err := i.nested.buildRecords(resourceName, event.Data.Object, record.Operation, previous, record.Metadata)
+	if err != nil {
+		return opencdc.Record{}, fmt.Errorf("build records of nested lists: %w", err)
 	}
 
-	index := i.position.Index
+	if len(nested) == 0 {
+		return record, nil
+	}
 
-	i.position.Index++
+	i.pending = append(nested[1:], record)
 
-	// update `Cursor` in the position if it is the last element of the resulting slice
-	if len(i.eventData) == i.position.Index {
-		i.position.Index = 0
-		i.position.Cursor = i.eventData[len(i.eventData)-1].ID
+	return nested[0], nil
+}
+
+// buildRecord returns the record of the event of the resource.
+func (i *CDC) buildRecord(event models.EventData, resourceName string) (opencdc.Record, error) {
+	if resourceName == resources.EventResource {
+		return i.buildEventRecord(event)
+	}
+
+	metadata := i.buildRecordMetadata(event, resourceName)
+
+	key := i.buildRecordKey(event, resourceName)
+
+	// deleted objects cannot be retrieved, so they are not expanded
+	if models.EventsOperation[event.Type] != opencdc.OperationDelete {
+		object, retrieved, err := i.stripeSvc.ExpandObject(resourceName, event.Data.Object)
+		if err != nil {
+			return opencdc.Record{}, fmt.Errorf("expand object: %w", err)
+		}
+
+		// the retrieved object is rendered with the API version of the client, which the source sets, if it is pinned
+		if retrieved {
+			delete(metadata, models.MetadataAPIVersion)
+		}
+
+		if err = i.nested.inlineLists(resourceName, object); err != nil {
+			return opencdc.Record{}, fmt.Errorf("inline nested lists: %w", err)
+		}
+
+		event.Data.Object = object
+	}
+
+	payload, err := i.buildRecordPayload(event)
+	if err != nil {
+		return opencdc.Record{}, fmt.Errorf("build record payload: %w", err)
 	}
 
 	position, err := i.position.marshalPosition()
@@ -78,7 +239,7 @@ func (i *CDC) Next() (opencdc.Record, error) {
 	}
 
 	// there is no default case, because opencdc.OperationUpdate is the default operation
-	switch models.EventsOperation[i.eventData[index].Type] {
+	switch models.EventsOperation[event.Type] {
 	case opencdc.OperationCreate:
 		return sdk.Util.Source.NewRecordCreate(
 			position,
@@ -87,11 +248,16 @@ func (i *CDC) Next() (opencdc.Record, error) {
 			payload,
 		), nil
 	case opencdc.OperationUpdate:
+		before, err := i.buildRecordPayloadBefore(event)
+		if err != nil {
+			return opencdc.Record{}, fmt.Errorf("build record payload before: %w", err)
+		}
+
 		return sdk.Util.Source.NewRecordUpdate(
 			position,
 			metadata,
 			key,
-			nil,
+			before,
 			payload,
 		), nil
 	case opencdc.OperationDelete:
@@ -106,19 +272,58 @@ func (i *CDC) Next() (opencdc.Record, error) {
 	return opencdc.Record{}, nil
 }
 
+// buildEventRecord returns the record of the event as it is, including all of its fields,
+// such as `data.previous_attributes`, `request` and `pending_webhooks`, which is created with the event.
+func (i *CDC) buildEventRecord(event models.EventData) (opencdc.Record, error) {
+	payload, err := buildEventPayload(event, i.structuredPayload)
+	if err != nil {
+		return opencdc.Record{}, fmt.Errorf("build record payload: %w", err)
+	}
+
+	position, err := i.position.marshalPosition()
+	if err != nil {
+		return opencdc.Record{}, fmt.Errorf("build record position: %w", err)
+	}
+
+	return sdk.Util.Source.NewRecordCreate(
+		position,
+		i.buildRecordMetadata(event, resources.EventResource),
+		opencdc.StructuredData{models.KeyID: event.ID},
+		payload,
+	), nil
+}
+
 // getData calls methods to assign Stripe event data to the iterator.
 func (i *CDC) getData() error {
+	if !i.retentionChecked {
+		if err := i.checkRetention(); err != nil {
+			return err
+		}
+
+		i.retentionChecked = true
+	}
+
 	if i.position.Cursor == "" {
+		polledAt := time.Now().Unix()
+
 		// because the data is sorted by date of creation in descending order
-		// and the shift `ending_before` is not known, it takes all the data and reverses it
-		return i.getDataWithStartingAfter()
+		// and the shift `ending_before` is not known, it finds the oldest page of the data first
+		if err := i.getDataWithStartingAfter(); err != nil {
+			return err
+		}
+
+		i.position.PolledAt = polledAt
+
+		return nil
 	}
 
 	return i.getDataWithEndingBefore()
 }
 
 // getDataWithStartingAfter makes requests with `starting_after` parameter
-// to receive all the event data, and assigns Stripe event data to the iterator.
+// to walk through the event data to its oldest page, and assigns that page to the iterator.
+// Only the last received page is kept, so the memory does not grow with the number of events,
+// and the newer events are received with `ending_before` parameter once the cursor is set to this page.
 func (i *CDC) getDataWithStartingAfter() error {
 	var (
 		eventsData models.EventsData
@@ -126,7 +331,7 @@ func (i *CDC) getDataWithStartingAfter() error {
 		startingAfter string
 	)
 
-	// get all the event data
+	// walk through all the event data
 	for {
 		// receive the data with `starting_after` parameter
 		resp, err := i.stripeSvc.GetEvent(i.position.CreatedAt, startingAfter, "")
@@ -138,7 +343,7 @@ func (i *CDC) getDataWithStartingAfter() error {
 			// update startingAfter parameter for the next request
 			startingAfter = resp.Data[len(resp.Data)-1].ID
 
-			eventsData = append(eventsData, resp.Data...)
+			eventsData = resp.Data
 		}
 
 		// break the loop if there is no more data
@@ -165,6 +370,10 @@ func (i *CDC) getDataWithEndingBefore() error {
 	// receive the data with `ending_before` parameter
 	resp, err := i.stripeSvc.GetEvent(i.position.CreatedAt, "", i.position.Cursor)
 	if err != nil {
+		if errors.Is(err, stripe.ErrEventMissing) {
+			return fmt.Errorf("event %s: %w", i.position.Cursor, ErrRetentionGap)
+		}
+
 		return fmt.Errorf("get list of event objects: %w", err)
 	}
 
@@ -180,28 +389,160 @@ func (i *CDC) getDataWithEndingBefore() error {
 	return nil
 }
 
+// checkRetention checks that the events since the position are retained by Stripe,
+// which is done once, when the iterator starts reading the events.
+// The position is lost if its cursor event no longer exists, or if it has no cursor,
+// and both the position and its latest successful poll are older than the retention,
+// because the events created since then may be deleted.
+func (i *CDC) checkRetention() error {
+	if i.position.Cursor == "" {
+		createdAt := time.Unix(max(i.position.CreatedAt, i.position.PolledAt), 0)
+		if time.Since(createdAt) > models.EventRetention {
+			return fmt.Errorf("position polled at %s: %w", createdAt.UTC().Format(time.RFC3339), ErrRetentionGap)
+		}
+
+		return nil
+	}
+
+	exists, err := i.stripeSvc.EventExists(i.position.Cursor)
+	if err != nil {
+		return fmt.Errorf("check event %s: %w", i.position.Cursor, err)
+	}
+
+	if !exists {
+		return fmt.Errorf("event %s: %w", i.position.Cursor, ErrRetentionGap)
+	}
+
+	return nil
+}
+
 // buildRecordMetadata returns the metadata for the record.
-func (i *CDC) buildRecordMetadata() map[string]string {
+func (i *CDC) buildRecordMetadata(event models.EventData, resourceName string) map[string]string {
 	metadata := opencdc.Metadata{}
 
-	metadata.SetCreatedAt(time.Unix(i.eventData[i.position.Index].Created, 0))
+	metadata.SetCreatedAt(time.Unix(event.Created, 0))
+	metadata[models.MetadataResource] = resourceName
+	metadata[models.MetadataEventID] = event.ID
+	metadata[models.MetadataEventType] = event.Type
+	metadata[models.MetadataLivemode] = strconv.FormatBool(event.Livemode)
+
+	if parentID := models.ParentID(resourceName, event.Data.Object); parentID != "" {
+		metadata[models.MetadataParentID] = parentID
+	}
+
+	// the payload is rendered with the API version of the event, which is not set for the events before 2014
+	if event.APIVersion != "" {
+		metadata[models.MetadataAPIVersion] = event.APIVersion
+	}
+
+	// the request is not set for the events which were not caused by an API request, such as automatic payouts
+	if event.Request.ID != "" {
+		metadata[models.MetadataRequestID] = event.Request.ID
+	}
 
 	return metadata
 }
 
 // buildRecordKey returns the key for the record.
-func (i *CDC) buildRecordKey() opencdc.Data {
-	return opencdc.StructuredData{
-		models.KeyID: i.eventData[i.position.Index].Data.Object[models.KeyID].(string),
+func (i *CDC) buildRecordKey(event models.EventData, resourceName string) opencdc.Data {
+	key := opencdc.StructuredData{
+		models.KeyID: event.Data.Object[models.KeyID].(string),
 	}
+
+	// the objects of the child resources are keyed by their parent object
+	if parentID := models.ParentID(resourceName, event.Data.Object); parentID != "" {
+		key[models.KeyParentID] = parentID
+	}
+
+	return key
+}
+
+// buildRecordPayloadBefore returns the payload of the object prior to the update event,
+// which is the object with the previous attributes of the event overlaid on it,
+// or nil if the event has no previous attributes.
+func (i *CDC) buildRecordPayloadBefore(event models.EventData) (opencdc.Data, error) {
+	if event.Data.PreviousAttributes == nil {
+		return nil, nil
+	}
+
+	before, _ := overlay(copyValue(event.Data.Object), event.Data.PreviousAttributes).(map[string]interface{})
+
+	return buildPayload(before, i.structuredPayload)
 }
 
 // buildRecordPayload returns the payload for the record.
-func (i *CDC) buildRecordPayload() (opencdc.Data, error) {
-	payload, err := json.Marshal(i.eventData[i.position.Index].Data.Object)
-	if err != nil {
-		return nil, fmt.Errorf("marshal payload: %w", err)
+func (i *CDC) buildRecordPayload(event models.EventData) (opencdc.Data, error) {
+	return buildPayload(event.Data.Object, i.structuredPayload)
+}
+
+// buildEventPayload returns the payload of the event as it is received from Stripe.
+func buildEventPayload(event models.EventData, structured bool) (opencdc.Data, error) {
+	raw := event.Raw
+	if raw == nil {
+		var err error
+
+		raw, err = json.Marshal(event)
+		if err != nil {
+			return nil, fmt.Errorf("marshal event: %w", err)
+		}
 	}
 
-	return opencdc.RawData(payload), nil
+	if !structured {
+		return opencdc.RawData(raw), nil
+	}
+
+	var object map[string]interface{}
+
+	// the numbers are decoded as json.Number, the same way as in the responses of the Stripe client
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+
+	if err := dec.Decode(&object); err != nil {
+		return nil, fmt.Errorf("decode event: %w", err)
+	}
+
+	return opencdc.StructuredData(object), nil
+}
+
+// overlay returns the value with the previous value overlaid on it,
+// where the objects are overlaid recursively, because Stripe sends only the previous keys of the updated objects,
+// and other values, including arrays, are replaced with the previous value.
+func overlay(value, previous interface{}) interface{} {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return previous
+	}
+
+	previousObject, ok := previous.(map[string]interface{})
+	if !ok {
+		return previous
+	}
+
+	for k := range previousObject {
+		object[k] = overlay(object[k], previousObject[k])
+	}
+
+	return object
+}
+
+// copyValue returns a deep copy of the decoded JSON value, so the copy does not share objects and arrays with it.
+func copyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		object := make(map[string]interface{}, len(v))
+		for k := range v {
+			object[k] = copyValue(v[k])
+		}
+
+		return object
+	case []interface{}:
+		array := make([]interface{}, len(v))
+		for i := range v {
+			array[i] = copyValue(v[i])
+		}
+
+		return array
+	default:
+		return v
+	}
 }
@@ -16,6 +16,7 @@ package iterator
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"reflect"
 	"testing"
@@ -24,7 +25,9 @@ import (
 	"github.com/conduitio-labs/conduit-connector-stripe/models"
 	"github.com/conduitio-labs/conduit-connector-stripe/models/resources"
 	"github.com/conduitio-labs/conduit-connector-stripe/source/iterator/mock"
+	"github.com/conduitio-labs/conduit-connector-stripe/stripe"
 	"github.com/conduitio/conduit-commons/opencdc"
+	sdk "github.com/conduitio/conduit-connector-sdk"
 	"go.uber.org/mock/gomock"
 )
 
@@ -106,13 +109,22 @@ func TestCDCIterator_Next(t *testing.T) {
 
 		pos := &Position{
 			IteratorMode: modeCDC,
-			CreatedAt:    1652790765,
+			CreatedAt:    time.Now().Unix(),
 		}
 
-		m.EXPECT().GetEvent(pos.CreatedAt, "", "").Return(responseFirst, nil)
-		m.EXPECT().GetEvent(pos.CreatedAt, responseFirst.Data[len(responseFirst.Data)-1].ID, "").Return(responseSecond, nil)
+		gomock.InOrder(
+			m.EXPECT().GetEvent(pos.CreatedAt, "", "").Return(responseFirst, nil),
+			m.EXPECT().GetEvent(pos.CreatedAt, responseFirst.Data[len(responseFirst.Data)-1].ID, "").
+				Return(responseSecond, nil),
+			// only the oldest page is kept, the newer events are received with `ending_before`
+			m.EXPECT().GetEvent(pos.CreatedAt, "", responseSecond.Data[0].ID).
+				Return(models.EventResponse{Data: responseFirst.Data}, nil),
+		)
+
+		expectNoExpansion(m)
+		expectEventsRetained(m)
 
-		iter := NewCDC(m, pos)
+		iter := NewCDC(m, pos, Options{ResourceNames: []string{resources.PlanResource}})
 
 		// reverse loop due to starting_after case
 		for i := len(result.Data) - 1; i >= 0; i-- {
@@ -219,7 +231,10 @@ func TestCDCIterator_Next(t *testing.T) {
 		m.EXPECT().GetEvent(pos.CreatedAt, "", cursor).Return(responseFirst, nil)
 		m.EXPECT().GetEvent(pos.CreatedAt, "", responseFirst.Data[0].ID).Return(responseSecond, nil)
 
-		iter := NewCDC(m, pos)
+		expectNoExpansion(m)
+		expectEventsRetained(m)
+
+		iter := NewCDC(m, pos, Options{ResourceNames: []string{resources.PlanResource}})
 
 		for i := range result.Data {
 			record, err := iter.Next()
@@ -240,6 +255,90 @@ func TestCDCIterator_Next(t *testing.T) {
 	})
 }
 
+func TestCDCIterator_NextSkipsUnconfiguredResources(t *testing.T) {
+	var (
+		ctrl = gomock.NewController(t)
+		m    = mock.NewMockStripe(ctrl)
+	)
+
+	response := models.EventResponse{
+		Data: []models.EventData{
+			{
+				ID:      "evt_1652447199",
+				Created: 1652447199,
+				Data: models.EventDataObject{Object: map[string]interface{}{
+					models.KeyID:     "in_1651153850",
+					models.KeyObject: "invoice",
+				}},
+				Type: resources.InvoiceCreatedEvent,
+			},
+			{
+				ID:      "evt_1652447186",
+				Created: 1652447186,
+				Data: models.EventDataObject{Object: map[string]interface{}{
+					models.KeyID:     "price_1651153850",
+					models.KeyObject: "price",
+				}},
+				Type: resources.PriceCreatedEvent,
+			},
+			{
+				ID:      "evt_1652447179",
+				Created: 1652447179,
+				Data: models.EventDataObject{Object: map[string]interface{}{
+					models.KeyID:     "cus_1651153850",
+					models.KeyObject: "customer",
+				}},
+				Type: resources.CustomerCreatedEvent,
+			},
+		},
+	}
+
+	pos := &Position{
+		IteratorMode: modeCDC,
+		Cursor:       cursor,
+		CreatedAt:    1652790765,
+	}
+
+	m.EXPECT().GetEvent(pos.CreatedAt, "", cursor).Return(response, nil)
+	m.EXPECT().GetEvent(pos.CreatedAt, "", "evt_1652447199").Return(models.EventResponse{}, nil)
+
+	expectNoExpansion(m)
+	expectEventsRetained(m)
+
+	iter := NewCDC(m, pos, Options{ResourceNames: []string{resources.CustomerResource, resources.InvoiceResource}})
+
+	for _, want := range []struct {
+		id       string
+		resource string
+	}{
+		{id: "cus_1651153850", resource: resources.CustomerResource},
+		{id: "in_1651153850", resource: resources.InvoiceResource},
+	} {
+		record, err := iter.Next()
+		if err != nil {
+			t.Errorf("next error = \"%s\"", err.Error())
+		}
+
+		if !reflect.DeepEqual(record.Key, opencdc.StructuredData{models.KeyID: want.id}) {
+			t.Errorf("key: got = %v, want %v", string(record.Key.Bytes()), want.id)
+		}
+
+		if record.Metadata[models.MetadataResource] != want.resource {
+			t.Errorf("resource: got = %v, want %v", record.Metadata[models.MetadataResource], want.resource)
+		}
+	}
+
+	// the cursor is the latest event, even though the event is skipped
+	if pos.Cursor != "evt_1652447199" {
+		t.Errorf("cursor: got = %v, want %v", pos.Cursor, "evt_1652447199")
+	}
+
+	_, err := iter.Next()
+	if !errors.Is(err, sdk.ErrBackoffRetry) {
+		t.Errorf("expected error \"%s\", got \"%v\"", sdk.ErrBackoffRetry, err)
+	}
+}
+
 func compareResult(record opencdc.Record, position opencdc.Position, data models.EventData) error {
 	if !reflect.DeepEqual(record.Key, opencdc.StructuredData{models.KeyID: data.Data.Object[models.KeyID]}) {
 		return fmt.Errorf("key: got = %v, want %v", string(record.Key.Bytes()), data.Data.Object[models.KeyID])
@@ -278,3 +377,454 @@ func compareResult(record opencdc.Record, position opencdc.Position, data models
 
 	return nil
 }
+
+func TestCDCIterator_NextExpandsObject(t *testing.T) {
+	var (
+		ctrl = gomock.NewController(t)
+		m    = mock.NewMockStripe(ctrl)
+	)
+
+	created := models.EventData{
+		ID:         "evt_1652447179",
+		Created:    1652447179,
+		APIVersion: "2020-08-27",
+		Data: models.EventDataObject{Object: map[string]interface{}{
+			models.KeyID:     "cus_1651153850",
+			"default_source": "card_1651153850",
+		}},
+		Type: resources.CustomerCreatedEvent,
+	}
+
+	deleted := models.EventData{
+		ID:         "evt_1652447199",
+		Created:    1652447199,
+		APIVersion: "2020-08-27",
+		Data: models.EventDataObject{Object: map[string]interface{}{
+			models.KeyID:     "cus_1651153850",
+			"default_source": "card_1651153850",
+		}},
+		Type: resources.CustomerDeletedEvent,
+	}
+
+	expanded := map[string]interface{}{
+		models.KeyID: "cus_1651153850",
+		"default_source": map[string]interface{}{
+			models.KeyID: "card_1651153850",
+		},
+	}
+
+	pos := &Position{
+		IteratorMode: modeCDC,
+		Cursor:       cursor,
+		CreatedAt:    1652790765,
+	}
+
+	m.EXPECT().GetEvent(pos.CreatedAt, "", cursor).Return(models.EventResponse{
+		Data: models.EventsData{deleted, created},
+	}, nil)
+	// the deleted object cannot be retrieved, so only the created one is expanded
+	m.EXPECT().ExpandObject(resources.CustomerResource, created.Data.Object).Return(expanded, true, nil)
+
+	expectEventsRetained(m)
+
+	iter := NewCDC(m, pos, Options{ResourceNames: []string{resources.CustomerResource}})
+
+	for _, want := range []map[string]interface{}{expanded, deleted.Data.Object} {
+		record, err := iter.Next()
+		if err != nil {
+			t.Fatalf("next error = \"%s\"", err.Error())
+		}
+
+		wantPayload, err := json.Marshal(want)
+		if err != nil {
+			t.Fatalf("marshal payload error = \"%s\"", err.Error())
+		}
+
+		payload := record.Payload.After
+		if record.Operation == opencdc.OperationDelete {
+			payload = record.Payload.Before
+		}
+
+		if !reflect.DeepEqual(payload, opencdc.RawData(wantPayload)) {
+			t.Errorf("payload: got = %v, want %v", string(payload.Bytes()), string(wantPayload))
+		}
+
+		// the retrieved object is not rendered with the API version of the event
+		apiVersion, ok := record.Metadata[models.MetadataAPIVersion]
+		if wantOK := record.Operation == opencdc.OperationDelete; ok != wantOK || (ok && apiVersion != deleted.APIVersion) {
+			t.Errorf("api version: got = %q, %t, want the version of the event only for the deleted object", apiVersion, ok)
+		}
+	}
+}
+
+// expectNoExpansion makes the mock return the objects as is, as Stripe does without configured expansions.
+func expectNoExpansion(m *mock.MockStripe) {
+	m.EXPECT().ExpandObject(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ string, object map[string]interface{}) (map[string]interface{}, bool, error) {
+			return object, false, nil
+		},
+	).AnyTimes()
+}
+
+// expectEventsRetained makes the mock report that the events still exist in Stripe.
+func expectEventsRetained(m *mock.MockStripe) {
+	m.EXPECT().EventExists(gomock.Any()).Return(true, nil).AnyTimes()
+}
+
+func TestCDCIterator_NextUpdateBefore(t *testing.T) {
+	var (
+		ctrl = gomock.NewController(t)
+		m    = mock.NewMockStripe(ctrl)
+	)
+
+	updated := models.EventData{
+		ID:      "evt_1652447199",
+		Created: 1652447199,
+		Data: models.EventDataObject{
+			Object: map[string]interface{}{
+				models.KeyID:        "cus_1651153850",
+				models.KeyName:      "Jenny Rosen",
+				"metadata":          map[string]interface{}{"tenant": "acme", "tier": "gold"},
+				"preferred_locales": []interface{}{"en", "de"},
+			},
+			PreviousAttributes: map[string]interface{}{
+				models.KeyName:      "Jenny",
+				"metadata":          map[string]interface{}{"tier": "silver"},
+				"preferred_locales": []interface{}{"en"},
+			},
+		},
+		Type: resources.CustomerUpdatedEvent,
+	}
+
+	pos := &Position{
+		IteratorMode: modeCDC,
+		Cursor:       cursor,
+		CreatedAt:    1652790765,
+	}
+
+	m.EXPECT().GetEvent(pos.CreatedAt, "", cursor).Return(models.EventResponse{
+		Data: models.EventsData{updated},
+	}, nil)
+	expectNoExpansion(m)
+	expectEventsRetained(m)
+
+	iter := NewCDC(m, pos, Options{
+		ResourceNames:     []string{resources.CustomerResource},
+		StructuredPayload: true,
+	})
+
+	record, err := iter.Next()
+	if err != nil {
+		t.Fatalf("next error = \"%s\"", err.Error())
+	}
+
+	wantBefore := opencdc.StructuredData{
+		models.KeyID:        "cus_1651153850",
+		models.KeyName:      "Jenny",
+		"metadata":          map[string]interface{}{"tenant": "acme", "tier": "silver"},
+		"preferred_locales": []interface{}{"en"},
+	}
+
+	if !reflect.DeepEqual(record.Payload.Before, wantBefore) {
+		t.Errorf("payload before: got = %v, want %v", record.Payload.Before, wantBefore)
+	}
+
+	if !reflect.DeepEqual(record.Payload.After, opencdc.StructuredData(updated.Data.Object)) {
+		t.Errorf("payload after: got = %v, want %v", record.Payload.After, updated.Data.Object)
+	}
+
+	if updated.Data.Object["metadata"].(map[string]interface{})["tier"] != "gold" {
+		t.Errorf("the object of the event is modified: %v", updated.Data.Object)
+	}
+}
+
+func TestCDCIterator_NextMetadata(t *testing.T) {
+	var (
+		ctrl = gomock.NewController(t)
+		m    = mock.NewMockStripe(ctrl)
+	)
+
+	event := models.EventData{
+		ID:      "evt_1652447199",
+		Created: 1652447199,
+		Data: models.EventDataObject{Object: map[string]interface{}{
+			models.KeyID: "in_1LajCF",
+		}},
+		Type:       resources.InvoicePaymentFailedEvent,
+		Livemode:   true,
+		APIVersion: "2022-11-15",
+		Request:    models.EventRequest{ID: "req_sKVzcHmVp5Ybo3"},
+	}
+
+	pos := &Position{
+		IteratorMode: modeCDC,
+		Cursor:       cursor,
+		CreatedAt:    1652790765,
+	}
+
+	m.EXPECT().GetEvent(pos.CreatedAt, "", cursor).Return(models.EventResponse{
+		Data: models.EventsData{event},
+	}, nil)
+	expectNoExpansion(m)
+	expectEventsRetained(m)
+
+	iter := NewCDC(m, pos, Options{ResourceNames: []string{resources.InvoiceResource}})
+
+	record, err := iter.Next()
+	if err != nil {
+		t.Fatalf("next error = \"%s\"", err.Error())
+	}
+
+	for key, want := range map[string]string{
+		models.MetadataResource:   resources.InvoiceResource,
+		models.MetadataEventID:    event.ID,
+		models.MetadataEventType:  resources.InvoicePaymentFailedEvent,
+		models.MetadataLivemode:   "true",
+		models.MetadataAPIVersion: event.APIVersion,
+		models.MetadataRequestID:  event.Request.ID,
+	} {
+		if record.Metadata[key] != want {
+			t.Errorf("metadata %s: got = %v, want %v", key, record.Metadata[key], want)
+		}
+	}
+}
+
+func TestCDCIterator_NextRetentionGap(t *testing.T) {
+	tests := []struct {
+		name   string
+		pos    Position
+		expect func(m *mock.MockStripe, pos *Position)
+	}{
+		{
+			name: "cursor_event_deleted",
+			pos:  Position{IteratorMode: modeCDC, Cursor: cursor, CreatedAt: time.Now().Unix()},
+			expect: func(m *mock.MockStripe, _ *Position) {
+				m.EXPECT().EventExists(cursor).Return(false, nil)
+			},
+		},
+		{
+			name: "cursor_event_deleted_while_reading",
+			pos:  Position{IteratorMode: modeCDC, Cursor: cursor, CreatedAt: time.Now().Unix()},
+			expect: func(m *mock.MockStripe, pos *Position) {
+				m.EXPECT().EventExists(cursor).Return(true, nil)
+				m.EXPECT().GetEvent(pos.CreatedAt, "", cursor).Return(models.EventResponse{}, stripe.ErrEventMissing)
+			},
+		},
+		{
+			name: "position_older_than_retention",
+			pos: Position{
+				IteratorMode: modeCDC,
+				CreatedAt:    time.Now().Add(-models.EventRetention - time.Hour).Unix(),
+			},
+			expect: func(*mock.MockStripe, *Position) {},
+		},
+		{
+			name: "poll_older_than_retention",
+			pos: Position{
+				IteratorMode: modeCDC,
+				CreatedAt:    time.Now().Add(-2 * models.EventRetention).Unix(),
+				PolledAt:     time.Now().Add(-models.EventRetention - time.Hour).Unix(),
+			},
+			expect: func(*mock.MockStripe, *Position) {},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := mock.NewMockStripe(gomock.NewController(t))
+
+			pos := tt.pos
+			tt.expect(m, &pos)
+
+			iter := NewCDC(m, &pos, Options{ResourceNames: []string{resources.CustomerResource}})
+
+			_, err := iter.Next()
+			if !errors.Is(err, ErrRetentionGap) {
+				t.Errorf("expected error \"%s\", got \"%v\"", ErrRetentionGap, err)
+			}
+		})
+	}
+}
+
+func TestCDCIterator_NextPolledWithoutEvents(t *testing.T) {
+	var (
+		m   = mock.NewMockStripe(gomock.NewController(t))
+		pos = &Position{
+			IteratorMode: modeCDC,
+			CreatedAt:    time.Now().Add(-models.EventRetention - time.Hour).Unix(),
+			PolledAt:     time.Now().Add(-time.Hour).Unix(),
+		}
+	)
+
+	// the resource had no events since its position, which is older than the retention, before its latest poll
+	m.EXPECT().GetEvent(pos.CreatedAt, "", "").Return(models.EventResponse{}, nil)
+
+	iter := NewCDC(m, pos, Options{ResourceNames: []string{resources.CustomerResource}})
+
+	startedAt := time.Now().Unix()
+
+	if _, err := iter.Next(); !errors.Is(err, sdk.ErrBackoffRetry) {
+		t.Errorf("expected error \"%s\", got \"%v\"", sdk.ErrBackoffRetry, err)
+	}
+
+	if pos.PolledAt < startedAt {
+		t.Errorf("polled at = %d, want the time of the poll", pos.PolledAt)
+	}
+}
+
+func TestCDCIterator_NextBoundedCatchUp(t *testing.T) {
+	const (
+		eventsCount = 1000
+		batchSize   = 10
+	)
+
+	var (
+		ctrl = gomock.NewController(t)
+		m    = mock.NewMockStripe(ctrl)
+	)
+
+	// events are sorted by date of creation in descending order, as Stripe returns them
+	events := make(models.EventsData, eventsCount)
+	for i := range events {
+		created := int64(1652447199 - i)
+
+		events[i] = models.EventData{
+			ID:      fmt.Sprintf("evt_%d", created),
+			Created: created,
+			Data: models.EventDataObject{Object: map[string]interface{}{
+				models.KeyID: fmt.Sprintf("cus_%d", created),
+			}},
+			Type: resources.CustomerCreatedEvent,
+		}
+	}
+
+	index := func(id string) int {
+		for i := range events {
+			if events[i].ID == id {
+				return i
+			}
+		}
+
+		t.Fatalf("unknown event %s", id)
+
+		return -1
+	}
+
+	// the mock lists the events the way Stripe does with the `starting_after` and `ending_before` parameters
+	m.EXPECT().GetEvent(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ int64, startingAfter, endingBefore string) (models.EventResponse, error) {
+			from, to := 0, min(batchSize, len(events))
+
+			switch {
+			case startingAfter != "":
+				from = index(startingAfter) + 1
+				to = min(from+batchSize, len(events))
+			case endingBefore != "":
+				to = index(endingBefore)
+				from = max(to-batchSize, 0)
+			}
+
+			data := make(models.EventsData, to-from)
+			copy(data, events[from:to])
+
+			hasMore := to < len(events)
+			if endingBefore != "" {
+				hasMore = from > 0
+			}
+
+			return models.EventResponse{Data: data, HasMore: hasMore}, nil
+		},
+	).AnyTimes()
+
+	expectNoExpansion(m)
+	expectEventsRetained(m)
+
+	pos := &Position{
+		IteratorMode: modeCDC,
+		CreatedAt:    time.Now().Unix(),
+	}
+
+	iter := NewCDC(m, pos, Options{ResourceNames: []string{resources.CustomerResource}})
+
+	// the records are returned from the oldest event to the newest one
+	for i := len(events) - 1; i >= 0; i-- {
+		record, err := iter.Next()
+		if err != nil {
+			t.Fatalf("next error = \"%s\"", err.Error())
+		}
+
+		if record.Metadata[models.MetadataEventID] != events[i].ID {
+			t.Fatalf("event id: got = %v, want %v", record.Metadata[models.MetadataEventID], events[i].ID)
+		}
+
+		if len(iter.eventData) > batchSize {
+			t.Fatalf("buffered events: got = %d, want at most %d", len(iter.eventData), batchSize)
+		}
+	}
+
+	_, err := iter.Next()
+	if !errors.Is(err, sdk.ErrBackoffRetry) {
+		t.Errorf("expected error \"%s\", got \"%v\"", sdk.ErrBackoffRetry, err)
+	}
+}
+
+func TestCDCIterator_NextEvents(t *testing.T) {
+	m := mock.NewMockStripe(gomock.NewController(t))
+
+	expectEventsRetained(m)
+
+	createdAt := time.Now().Unix()
+
+	// the events are sorted by date of creation in descending order, as Stripe returns them
+	var resp models.EventResponse
+
+	err := json.Unmarshal([]byte(`{"data":[
+		{"id":"evt_3","type":"charge.failed","pending_webhooks":0,"data":{"object":{"id":"ch_1"}}},
+		{"id":"evt_2","type":"customer.updated","pending_webhooks":1,"data":{"object":{"id":"cus_1"}}},
+		{"id":"evt_1","type":"invoice.paid","pending_webhooks":2,"request":{"id":"req_1"},
+			"data":{"object":{"id":"in_1"},"previous_attributes":{"paid":false}}}
+	],"has_more":false}`), &resp)
+	if err != nil {
+		t.Fatalf("unmarshal events error = \"%s\"", err.Error())
+	}
+
+	m.EXPECT().GetEvent(createdAt, "", "").Return(resp, nil)
+
+	iter := NewCDC(m, &Position{IteratorMode: modeCDC, CreatedAt: createdAt}, Options{
+		ResourceNames: []string{resources.EventResource},
+		EventTypes:    []string{"invoice.*", "*.failed"},
+	})
+
+	for _, want := range []string{"evt_1", "evt_3"} {
+		record, err := iter.Next()
+		if err != nil {
+			t.Fatalf("next error = \"%s\"", err.Error())
+		}
+
+		if record.Operation != opencdc.OperationCreate {
+			t.Errorf("operation: got = %s, want %s", record.Operation, opencdc.OperationCreate)
+		}
+
+		if !reflect.DeepEqual(record.Key, opencdc.StructuredData{models.KeyID: want}) {
+			t.Errorf("key: got = %v, want %s", record.Key, want)
+		}
+
+		if record.Metadata[models.MetadataResource] != resources.EventResource {
+			t.Errorf("resource: got = %s, want %s", record.Metadata[models.MetadataResource], resources.EventResource)
+		}
+
+		// the event is emitted as it is received from Stripe
+		var event map[string]interface{}
+		if err := json.Unmarshal(record.Payload.After.Bytes(), &event); err != nil {
+			t.Fatalf("unmarshal payload error = \"%s\"", err.Error())
+		}
+
+		if _, ok := event["pending_webhooks"]; !ok || event[models.KeyID] != want {
+			t.Errorf("payload: got = %v, want the event %s as it is", event, want)
+		}
+
+		if want == "evt_1" && event["request"] == nil {
+			t.Errorf("payload: got = %v, want the request of the event", event)
+		}
+	}
+}
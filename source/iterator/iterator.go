@@ -15,62 +15,329 @@
 package iterator
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/conduitio-labs/conduit-connector-stripe/models"
 	"github.com/conduitio/conduit-commons/opencdc"
+	sdk "github.com/conduitio/conduit-connector-sdk"
 )
 
 //go:generate mockgen -package mock -source iterator.go -destination ./mock/iterator.go
 
 // A Stripe defines the interface of methods.
 type Stripe interface {
-	GetResource(string) (models.ResourceResponse, error)
+	ListResource(resourceName string, params models.ListParams) (models.ResourceResponse, error)
+	SearchResource(resourceName, query, page string) (models.ResourceResponse, error)
+	ListNested(path, startingAfter string) (models.ResourceResponse, error)
 	GetEvent(createdAt int64, startingAfter, endingBefore string) (models.EventResponse, error)
+	ExpandObject(resourceName string, object map[string]interface{}) (map[string]interface{}, bool, error)
+	EventExists(id string) (bool, error)
+}
+
+// Options are the options of the iterators.
+type Options struct {
+	// ResourceNames are the names of the resources to read.
+	ResourceNames []string
+	// Snapshot reports whether the iterator makes a copy of the resources before reading their events.
+	Snapshot bool
+	// SnapshotCreatedAfter is the bound of the time the objects of the snapshot are created at or after,
+	// where the duration is relative to the start of the snapshot.
+	SnapshotCreatedAfter models.TimeBound
+	// SnapshotCreatedBefore is the bound of the time the objects of the snapshot are created before,
+	// where the duration is relative to the start of the snapshot.
+	SnapshotCreatedBefore models.TimeBound
+	// ListFilters are the filters of the lists of the resources in the snapshot.
+	ListFilters map[string]string
+	// EventTypes are the patterns of the types of the events the event resource reads, such as `invoice.*`,
+	// all events are read if there are no patterns.
+	EventTypes []string
+	// SearchQuery is the query of the Stripe Search API the Snapshot iterator reads the objects with, if it is set.
+	SearchQuery string
+	// PollOverlap is the overlap of the windows of the time of creation the lists of the resources without events
+	// are polled with in the CDC mode.
+	PollOverlap time.Duration
+	// PollLookback is the window of the time of creation before the current time the lists of the resources are polled
+	// with the poll cdc strategy, the whole lists are polled if it is zero.
+	PollLookback time.Duration
+	// PollStrategy reports whether the CDC iterator polls the lists of all resources for their new and changed objects,
+	// instead of reading their events.
+	PollStrategy bool
+	// PollStateFile is the path of the local file the seen polled objects are stored in, if any.
+	PollStateFile string
+	// ReconcileInterval is the time between the reconciliations of a resource,
+	// which emit the deletes of the objects missing from its list, the reconciliation is disabled if it is zero.
+	ReconcileInterval time.Duration
+	// ReconcileStateFile is the path of the local file the identifiers of the reconciled objects are stored in.
+	ReconcileStateFile string
+	// Account is the connected account the iterator reads, if any, which is set by the ConnectedAccounts iterator.
+	Account string
+	// SnapshotWorkers is the number of partitions of a resource the Snapshot iterator reads concurrently.
+	SnapshotWorkers int
+	// InlineNestedLists reports whether the truncated lists nested in the objects, such as the lines of the invoices,
+	// are replaced with all of their objects.
+	InlineNestedLists bool
+	// NestedListRecords reports whether the objects of the lists nested in the objects are read as records of their own,
+	// which are keyed by the identifier of their parent object.
+	NestedListRecords bool
+	// StructuredPayload reports whether the payloads are opencdc.StructuredData instead of opencdc.RawData.
+	StructuredPayload bool
+	// SnapshotOnly reports whether the iterator reads only the snapshot, and no changes after it.
+	SnapshotOnly bool
+	// SnapshotOnRetentionGap reports whether the iterator makes a new copy of the resources,
+	// when the events since the position are no longer retained by Stripe, instead of failing.
+	SnapshotOnRetentionGap bool
+}
+
+// A committer represents an iterator, which stores its local state once the records it returned are acknowledged.
+type committer interface {
+	// commit stores the local state of the oldest record returned by the iterator, which is not acknowledged yet.
+	commit() error
 }
 
 // An Iterator represents a struct of iterator.
 type Iterator struct {
-	snapshot *Snapshot
-	cdc      *CDC
-	position *Position
+	stripeSvc Stripe
+	opts      Options
+
+	snapshot   *Snapshot
+	cdc        *CDC
+	poll       *Poll
+	webhook    *Webhook
+	reconciler *Reconciler
+	position   *Position
+
+	// unacked are the iterators of the records returned, which are not acknowledged yet, in the order of the records,
+	// where the iterator is nil if it has no local state.
+	unacked []committer
 }
 
-// New initializes an iterator.
-func New(stripeSvc Stripe, pos *Position, snapshot bool) *Iterator {
+// New initializes an iterator of the resources.
+func New(stripeSvc Stripe, pos *Position, opts Options) *Iterator {
 	iterator := &Iterator{
-		position: pos,
-		cdc:      NewCDC(stripeSvc, pos),
+		stripeSvc:  stripeSvc,
+		opts:       opts,
+		position:   pos,
+		cdc:        NewCDC(stripeSvc, pos, opts),
+		poll:       NewPoll(stripeSvc, pos, opts),
+		reconciler: NewReconciler(stripeSvc, pos, opts),
 	}
 
-	if !snapshot {
+	if !opts.Snapshot {
 		pos.IteratorMode = modeCDC
 	}
 
+	// the records of the nested lists after the position are read again with their parent object
+	pos.Nested = 0
+
 	if pos.IteratorMode == modeSnapshot {
-		iterator.snapshot = NewSnapshot(stripeSvc, pos)
+		iterator.snapshot = NewSnapshot(stripeSvc, pos, opts)
 	}
 
 	return iterator
 }
 
+// ListenWebhook makes the iterator read the events delivered by Stripe to the listener on the address,
+// instead of polling them in the CDC mode.
+func (iter *Iterator) ListenWebhook(ctx context.Context, addr, secret string, tolerance time.Duration) error {
+	webhook, err := NewWebhook(ctx, iter.cdc, iter.position, addr, secret, tolerance)
+	if err != nil {
+		return fmt.Errorf("initialize webhook iterator: %w", err)
+	}
+
+	iter.webhook = webhook
+
+	return nil
+}
+
 // Next returns the next record.
-func (iter *Iterator) Next() (opencdc.Record, error) {
+func (iter *Iterator) Next(ctx context.Context) (opencdc.Record, error) {
+	record, from, err := iter.next(ctx)
+	if err != nil {
+		return opencdc.Record{}, err
+	}
+
+	iter.unacked = append(iter.unacked, from)
+
+	if iter.reconciler != nil {
+		iter.reconciler.see(record)
+	}
+
+	return record, nil
+}
+
+// Ack stores the local state of the oldest record returned, which is not acknowledged yet,
+// because the positions are acknowledged in the order of the records.
+func (iter *Iterator) Ack(context.Context, opencdc.Position) error {
+	if len(iter.unacked) == 0 {
+		return nil
+	}
+
+	from := dequeue(&iter.unacked)
+	if from == nil {
+		return nil
+	}
+
+	return from.commit()
+}
+
+// next returns the next record, and the iterator it is returned by, if the iterator has a local state.
+func (iter *Iterator) next(ctx context.Context) (opencdc.Record, committer, error) {
 	switch iter.position.IteratorMode {
 	case modeSnapshot:
 		record, err := iter.snapshot.Next()
 		if err != nil {
-			return opencdc.Record{}, err
+			return opencdc.Record{}, nil, err
 		}
 
 		if record.Key != nil {
-			return record, nil
+			return iter.seed(record)
 		}
 
 		fallthrough
 	case modeCDC:
-		return iter.cdc.Next()
+		if iter.opts.SnapshotOnly {
+			return opencdc.Record{}, nil, sdk.ErrBackoffRetry
+		}
+
+		record, from, err := iter.nextCDC(ctx)
+		if errors.Is(err, ErrRetentionGap) && iter.opts.SnapshotOnRetentionGap {
+			sdk.Logger(ctx).Warn().Err(err).Msg("the events since the position are lost, taking a new snapshot")
+
+			iter.resetSnapshot()
+
+			return iter.next(ctx)
+		}
+
+		return record, from, err
+	}
+
+	return opencdc.Record{}, nil, fmt.Errorf("unexpected iterator mode: %s", iter.position.IteratorMode)
+}
+
+// seed returns the snapshot record, whose object is seen by the Poll iterator, if it is an object of a polled resource,
+// so the Poll iterator returns only the changes of the object since the snapshot.
+func (iter *Iterator) seed(record opencdc.Record) (opencdc.Record, committer, error) {
+	if iter.poll == nil {
+		return record, nil, nil
+	}
+
+	ok, err := iter.poll.seed(record)
+	if err != nil {
+		return opencdc.Record{}, nil, fmt.Errorf("seed poll: %w", err)
+	}
+
+	if !ok {
+		return record, nil, nil
+	}
+
+	return record, iter.poll, nil
+}
+
+// nextCDC returns the next record of the webhook iterator if it is listening, or of the CDC iterator otherwise,
+// followed by the records of the Poll iterator of the resources without events, once there are no new events,
+// or only the records of the Poll iterator with the poll cdc strategy.
+// The delete records of the reconciliation are returned first, when the resources are reconciled,
+// and the next page of the resource being reconciled is listed, instead of waiting, if there are no other records.
+func (iter *Iterator) nextCDC(ctx context.Context) (opencdc.Record, committer, error) {
+	for {
+		if iter.reconciler != nil {
+			record, ok, err := iter.reconciler.Next()
+			if err != nil {
+				return opencdc.Record{}, nil, fmt.Errorf("reconciliation: %w", err)
+			}
+
+			if ok {
+				return record, iter.reconciler, nil
+			}
+		}
+
+		record, from, err := iter.nextChange(ctx)
+		if errors.Is(err, sdk.ErrBackoffRetry) && iter.reconciler != nil && iter.reconciler.reconciling() {
+			continue
+		}
+
+		return record, from, err
+	}
+}
+
+// nextChange returns the next record of the webhook, CDC or Poll iterator.
+func (iter *Iterator) nextChange(ctx context.Context) (opencdc.Record, committer, error) {
+	if iter.webhook != nil {
+		record, err := iter.webhook.Next(ctx)
+
+		return record, nil, err
+	}
+
+	if !iter.opts.PollStrategy && iter.cdc.hasEvents() {
+		record, err := iter.cdc.Next()
+		if iter.poll == nil || !errors.Is(err, sdk.ErrBackoffRetry) {
+			return record, nil, err
+		}
+	}
+
+	if iter.poll == nil {
+		return opencdc.Record{}, nil, sdk.ErrBackoffRetry
+	}
+
+	record, err := iter.poll.Next()
+
+	return record, iter.poll, err
+}
+
+// resetSnapshot resets the position to a new snapshot of the resources,
+// which is followed by the events created after the reset.
+func (iter *Iterator) resetSnapshot() {
+	*iter.position = Position{
+		IteratorMode: modeSnapshot,
+		CreatedAt:    time.Now().Unix(),
+		Account:      iter.position.Account,
+	}
+
+	iter.snapshot = NewSnapshot(iter.stripeSvc, iter.position, iter.opts)
+	iter.cdc = NewCDC(iter.stripeSvc, iter.position, iter.opts)
+	iter.poll = NewPoll(iter.stripeSvc, iter.position, iter.opts)
+
+	if iter.webhook != nil {
+		iter.webhook.reset(iter.cdc)
+	}
+}
+
+// Stop stops the webhook listener, if any, and writes the local states stored since their last writes.
+func (iter *Iterator) Stop(ctx context.Context) error {
+	if iter.poll != nil {
+		if err := iter.poll.flush(); err != nil {
+			return err
+		}
+	}
+
+	if iter.reconciler != nil {
+		if err := iter.reconciler.flush(); err != nil {
+			return err
+		}
+	}
+
+	if iter.webhook == nil {
+		return nil
+	}
+
+	return iter.webhook.Stop(ctx)
+}
+
+// buildPayload returns the payload of the Stripe object,
+// which is structured data if it is configured, or the object marshaled to JSON otherwise.
+func buildPayload(object map[string]interface{}, structured bool) (opencdc.Data, error) {
+	if structured {
+		return opencdc.StructuredData(object), nil
+	}
+
+	payload, err := json.Marshal(object)
+	if err != nil {
+		return nil, fmt.Errorf("marshal payload: %w", err)
 	}
 
-	return opencdc.Record{}, fmt.Errorf("unexpected iterator mode: %s", iter.position.IteratorMode)
+	return opencdc.RawData(payload), nil
 }
@@ -0,0 +1,116 @@
+// Copyright © 2022 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/conduitio-labs/conduit-connector-stripe/models"
+	"github.com/conduitio-labs/conduit-connector-stripe/models/resources"
+	"github.com/conduitio-labs/conduit-connector-stripe/source/iterator/mock"
+	"github.com/conduitio/conduit-commons/opencdc"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"go.uber.org/mock/gomock"
+)
+
+func TestIterator_NextRetentionGap(t *testing.T) {
+	customer := map[string]interface{}{
+		models.KeyID:      "cus_LY6gsj",
+		models.KeyCreated: float64(1651153903),
+	}
+
+	t.Run("fail", func(t *testing.T) {
+		m := mock.NewMockStripe(gomock.NewController(t))
+		m.EXPECT().EventExists(cursor).Return(false, nil)
+
+		pos := &Position{IteratorMode: modeCDC, Cursor: cursor, CreatedAt: time.Now().Unix()}
+
+		iter := New(m, pos, Options{ResourceNames: []string{resources.CustomerResource}})
+
+		_, err := iter.Next(context.Background())
+		if !errors.Is(err, ErrRetentionGap) {
+			t.Errorf("expected error \"%s\", got \"%v\"", ErrRetentionGap, err)
+		}
+	})
+
+	t.Run("snapshot", func(t *testing.T) {
+		m := mock.NewMockStripe(gomock.NewController(t))
+		m.EXPECT().EventExists(cursor).Return(false, nil)
+		m.EXPECT().ListResource(resources.CustomerResource, models.ListParams{}).Return(models.ResourceResponse{
+			Data: []map[string]interface{}{customer},
+		}, nil)
+
+		pos := &Position{IteratorMode: modeCDC, Cursor: cursor, CreatedAt: 1652790765}
+
+		iter := New(m, pos, Options{
+			ResourceNames:          []string{resources.CustomerResource},
+			Snapshot:               true,
+			SnapshotOnRetentionGap: true,
+		})
+
+		startedAt := time.Now().Unix()
+
+		record, err := iter.Next(context.Background())
+		if err != nil {
+			t.Fatalf("next error = \"%s\"", err.Error())
+		}
+
+		if record.Operation != opencdc.OperationSnapshot {
+			t.Errorf("operation: got = %v, want %v", record.Operation, opencdc.OperationSnapshot)
+		}
+
+		if pos.IteratorMode != modeSnapshot || pos.CreatedAt < startedAt {
+			t.Errorf("position: got = %+v, want new snapshot position", pos)
+		}
+	})
+}
+
+func TestIterator_NextSnapshotOnly(t *testing.T) {
+	m := mock.NewMockStripe(gomock.NewController(t))
+	gomock.InOrder(
+		m.EXPECT().ListResource(resources.CustomerResource, models.ListParams{WithoutExpand: true}).
+			Return(models.ResourceResponse{
+				Data: []map[string]interface{}{{models.KeyID: "cus_LY6gsj"}},
+			}, nil),
+		m.EXPECT().ListResource(resources.CustomerBalanceTransactionResource, models.ListParams{ParentID: "cus_LY6gsj"}).
+			Return(models.ResourceResponse{
+				Data: []map[string]interface{}{{models.KeyID: "cbtxn_1", "customer": "cus_LY6gsj"}},
+			}, nil),
+		m.EXPECT().ListResource(gomock.Any(), gomock.Any()).Return(models.ResourceResponse{}, nil).AnyTimes(),
+	)
+
+	iter := New(m, &Position{IteratorMode: modeSnapshot}, Options{
+		ResourceNames: []string{resources.CustomerBalanceTransactionResource},
+		Snapshot:      true,
+		SnapshotOnly:  true,
+	})
+
+	record, err := iter.Next(context.Background())
+	if err != nil {
+		t.Fatalf("next error = \"%s\"", err.Error())
+	}
+
+	if record.Operation != opencdc.OperationSnapshot {
+		t.Errorf("operation: got = %v, want %v", record.Operation, opencdc.OperationSnapshot)
+	}
+
+	// no events are read once the snapshot is complete
+	if _, err = iter.Next(context.Background()); !errors.Is(err, sdk.ErrBackoffRetry) {
+		t.Errorf("expected error \"%s\", got \"%v\"", sdk.ErrBackoffRetry, err)
+	}
+}
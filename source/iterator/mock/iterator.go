@@ -40,6 +40,37 @@ func (m *MockStripe) EXPECT() *MockStripeMockRecorder {
 	return m.recorder
 }
 
+// EventExists mocks base method.
+func (m *MockStripe) EventExists(id string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EventExists", id)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EventExists indicates an expected call of EventExists.
+func (mr *MockStripeMockRecorder) EventExists(id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EventExists", reflect.TypeOf((*MockStripe)(nil).EventExists), id)
+}
+
+// ExpandObject mocks base method.
+func (m *MockStripe) ExpandObject(resourceName string, object map[string]any) (map[string]any, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpandObject", resourceName, object)
+	ret0, _ := ret[0].(map[string]any)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ExpandObject indicates an expected call of ExpandObject.
+func (mr *MockStripeMockRecorder) ExpandObject(resourceName, object any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpandObject", reflect.TypeOf((*MockStripe)(nil).ExpandObject), resourceName, object)
+}
+
 // GetEvent mocks base method.
 func (m *MockStripe) GetEvent(createdAt int64, startingAfter, endingBefore string) (models.EventResponse, error) {
 	m.ctrl.T.Helper()
@@ -55,17 +86,85 @@ func (mr *MockStripeMockRecorder) GetEvent(createdAt, startingAfter, endingBefor
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEvent", reflect.TypeOf((*MockStripe)(nil).GetEvent), createdAt, startingAfter, endingBefore)
 }
 
-// GetResource mocks base method.
-func (m *MockStripe) GetResource(arg0 string) (models.ResourceResponse, error) {
+// ListNested mocks base method.
+func (m *MockStripe) ListNested(path, startingAfter string) (models.ResourceResponse, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetResource", arg0)
+	ret := m.ctrl.Call(m, "ListNested", path, startingAfter)
 	ret0, _ := ret[0].(models.ResourceResponse)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetResource indicates an expected call of GetResource.
-func (mr *MockStripeMockRecorder) GetResource(arg0 any) *gomock.Call {
+// ListNested indicates an expected call of ListNested.
+func (mr *MockStripeMockRecorder) ListNested(path, startingAfter any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListNested", reflect.TypeOf((*MockStripe)(nil).ListNested), path, startingAfter)
+}
+
+// ListResource mocks base method.
+func (m *MockStripe) ListResource(resourceName string, params models.ListParams) (models.ResourceResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListResource", resourceName, params)
+	ret0, _ := ret[0].(models.ResourceResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListResource indicates an expected call of ListResource.
+func (mr *MockStripeMockRecorder) ListResource(resourceName, params any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListResource", reflect.TypeOf((*MockStripe)(nil).ListResource), resourceName, params)
+}
+
+// SearchResource mocks base method.
+func (m *MockStripe) SearchResource(resourceName, query, page string) (models.ResourceResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SearchResource", resourceName, query, page)
+	ret0, _ := ret[0].(models.ResourceResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SearchResource indicates an expected call of SearchResource.
+func (mr *MockStripeMockRecorder) SearchResource(resourceName, query, page any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchResource", reflect.TypeOf((*MockStripe)(nil).SearchResource), resourceName, query, page)
+}
+
+// Mockcommitter is a mock of committer interface.
+type Mockcommitter struct {
+	ctrl     *gomock.Controller
+	recorder *MockcommitterMockRecorder
+	isgomock struct{}
+}
+
+// MockcommitterMockRecorder is the mock recorder for Mockcommitter.
+type MockcommitterMockRecorder struct {
+	mock *Mockcommitter
+}
+
+// NewMockcommitter creates a new mock instance.
+func NewMockcommitter(ctrl *gomock.Controller) *Mockcommitter {
+	mock := &Mockcommitter{ctrl: ctrl}
+	mock.recorder = &MockcommitterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *Mockcommitter) EXPECT() *MockcommitterMockRecorder {
+	return m.recorder
+}
+
+// commit mocks base method.
+func (m *Mockcommitter) commit() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "commit")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// commit indicates an expected call of commit.
+func (mr *MockcommitterMockRecorder) commit() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetResource", reflect.TypeOf((*MockStripe)(nil).GetResource), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "commit", reflect.TypeOf((*Mockcommitter)(nil).commit))
 }
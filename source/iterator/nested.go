@@ -0,0 +1,215 @@
+// Copyright © 2022 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/conduitio-labs/conduit-connector-stripe/models"
+	"github.com/conduitio/conduit-commons/opencdc"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+)
+
+// A nestedLists represents the reader of the lists nested in the objects, which Stripe truncates to their first page,
+// such as the lines of the invoices, or the items of the subscriptions.
+type nestedLists struct {
+	stripeSvc Stripe
+
+	// inline reports whether the truncated lists are replaced with all of their objects.
+	inline bool
+	// records reports whether the objects of the lists are read as records of their own.
+	records bool
+	// structuredPayload reports whether the payloads of the records are structured data.
+	structuredPayload bool
+}
+
+// newNestedLists initializes the reader of the nested lists.
+func newNestedLists(stripeSvc Stripe, opts Options) nestedLists {
+	return nestedLists{
+		stripeSvc:         stripeSvc,
+		inline:            opts.InlineNestedLists,
+		records:           opts.NestedListRecords,
+		structuredPayload: opts.StructuredPayload,
+	}
+}
+
+// inlineLists replaces the nested lists of the object of the resource with all of their objects, if it is configured.
+func (n nestedLists) inlineLists(resourceName string, object map[string]interface{}) error {
+	if !n.inline {
+		return nil
+	}
+
+	for _, list := range models.NestedListsMap[resourceName] {
+		objects, err := n.list(object, list)
+		if err != nil {
+			return err
+		}
+
+		embedded, ok := object[list.Field].(map[string]interface{})
+		if !ok {
+			// the list is not included in the object by default, such as the line items of the checkout sessions
+			embedded = map[string]interface{}{
+				models.KeyObject: "list",
+				models.KeyURL:    models.APIPath + n.path(object, list),
+			}
+
+			object[list.Field] = embedded
+		}
+
+		embedded[models.KeyData] = objects
+		embedded[models.KeyHasMore] = false
+	}
+
+	return nil
+}
+
+// buildRecords returns the records of the objects of the nested lists of the object of the resource,
+// if it is configured, where the records have the operation and the metadata of the object,
+// with the name of the nested list as the resource, such as `invoice.lines`.
+// The positions are the one before the object with the number of the record, so the object and its nested lists
+// are read again after a restart, if their records are not acknowledged.
+func (n nestedLists) buildRecords(
+	resourceName string, object map[string]interface{},
+	operation opencdc.Operation, position opencdc.Position, metadata opencdc.Metadata,
+) ([]opencdc.Record, error) {
+	if !n.records {
+		return nil, nil
+	}
+
+	parentID, _ := object[models.KeyID].(string)
+
+	var records []opencdc.Record
+
+	for _, list := range models.NestedListsMap[resourceName] {
+		objects, err := n.list(object, list)
+		if err != nil {
+			return nil, err
+		}
+
+		for j := range objects {
+			child, ok := objects[j].(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			nested, err := nestedPosition(position, len(records)+1)
+			if err != nil {
+				return nil, fmt.Errorf("build record position: %w", err)
+			}
+
+			record, err := n.buildRecord(resourceName+"."+list.Field, parentID, child, operation, nested, metadata)
+			if err != nil {
+				return nil, fmt.Errorf("build record of %s of %s: %w", list.Field, parentID, err)
+			}
+
+			records = append(records, record)
+		}
+	}
+
+	return records, nil
+}
+
+// buildRecord returns the record of the object of the nested list of the parent object.
+func (n nestedLists) buildRecord(
+	resourceName, parentID string, object map[string]interface{},
+	operation opencdc.Operation, position opencdc.Position, parentMetadata opencdc.Metadata,
+) (opencdc.Record, error) {
+	metadata := make(opencdc.Metadata, len(parentMetadata)+1)
+	for k, v := range parentMetadata {
+		metadata[k] = v
+	}
+
+	metadata[models.MetadataResource] = resourceName
+	metadata[models.MetadataParentID] = parentID
+
+	key := opencdc.StructuredData{
+		models.KeyID:       object[models.KeyID],
+		models.KeyParentID: parentID,
+	}
+
+	payload, err := buildPayload(object, n.structuredPayload)
+	if err != nil {
+		return opencdc.Record{}, fmt.Errorf("build record payload: %w", err)
+	}
+
+	// the objects of the nested lists have no events, so they are created with their parent, or updated otherwise
+	switch operation {
+	case opencdc.OperationSnapshot:
+		return sdk.Util.Source.NewRecordSnapshot(position, metadata, key, payload), nil
+	case opencdc.OperationCreate:
+		return sdk.Util.Source.NewRecordCreate(position, metadata, key, payload), nil
+	default:
+		return sdk.Util.Source.NewRecordUpdate(position, metadata, key, nil, payload), nil
+	}
+}
+
+// list returns all objects of the nested list of the object,
+// which are the objects included in the object followed by the objects of the next pages of the list.
+func (n nestedLists) list(object map[string]interface{}, list models.NestedList) ([]interface{}, error) {
+	var (
+		objects       []interface{}
+		startingAfter string
+	)
+
+	if embedded, ok := object[list.Field].(map[string]interface{}); ok {
+		objects, _ = embedded[models.KeyData].([]interface{})
+
+		if hasMore, _ := embedded[models.KeyHasMore].(bool); !hasMore {
+			return objects, nil
+		}
+
+		if len(objects) > 0 {
+			if last, ok := objects[len(objects)-1].(map[string]interface{}); ok {
+				startingAfter, _ = last[models.KeyID].(string)
+			}
+		}
+	}
+
+	path := n.path(object, list)
+
+	for {
+		resp, err := n.stripeSvc.ListNested(path, startingAfter)
+		if err != nil {
+			return nil, fmt.Errorf("list %s: %w", path, err)
+		}
+
+		for j := range resp.Data {
+			objects = append(objects, resp.Data[j])
+		}
+
+		if !resp.HasMore || len(resp.Data) == 0 {
+			return objects, nil
+		}
+
+		startingAfter, _ = resp.Data[len(resp.Data)-1][models.KeyID].(string)
+	}
+}
+
+// path returns the path of the nested list of the object relative to the API path.
+func (n nestedLists) path(object map[string]interface{}, list models.NestedList) string {
+	id, _ := object[models.KeyID].(string)
+
+	return fmt.Sprintf(list.PathFmt, url.PathEscape(id))
+}
+
+// dequeue returns the first of the pending values, such as the records, and removes it from them.
+func dequeue[T any](pending *[]T) T {
+	value := (*pending)[0]
+
+	*pending = (*pending)[1:]
+
+	return value
+}
@@ -0,0 +1,192 @@
+// Copyright © 2022 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/conduitio-labs/conduit-connector-stripe/models"
+	"github.com/conduitio-labs/conduit-connector-stripe/models/resources"
+	"github.com/conduitio-labs/conduit-connector-stripe/source/iterator/mock"
+	"github.com/conduitio/conduit-commons/opencdc"
+	"go.uber.org/mock/gomock"
+)
+
+// invoiceWithLines returns an invoice, whose lines are truncated to the first page.
+func invoiceWithLines() map[string]interface{} {
+	return map[string]interface{}{
+		models.KeyID: "in_1MtHbELkdIwHu7ix",
+		"lines": map[string]interface{}{
+			models.KeyObject: "list",
+			models.KeyData: []interface{}{
+				map[string]interface{}{models.KeyID: "il_1"},
+				map[string]interface{}{models.KeyID: "il_2"},
+			},
+			models.KeyHasMore: true,
+			models.KeyURL:     "/v1/invoices/in_1MtHbELkdIwHu7ix/lines",
+		},
+	}
+}
+
+// expectInvoiceLines expects the next page of the lines of the invoice.
+func expectInvoiceLines(m *mock.MockStripe) {
+	m.EXPECT().ListNested("/invoices/in_1MtHbELkdIwHu7ix/lines", "il_2").Return(models.ResourceResponse{
+		Data: []map[string]interface{}{{models.KeyID: "il_3"}},
+	}, nil)
+}
+
+func TestSnapshotIterator_NextInlineNestedLists(t *testing.T) {
+	m := mock.NewMockStripe(gomock.NewController(t))
+
+	m.EXPECT().ListResource(resources.InvoiceResource, models.ListParams{}).Return(models.ResourceResponse{
+		Data: []map[string]interface{}{invoiceWithLines()},
+	}, nil)
+	expectInvoiceLines(m)
+
+	iter := NewSnapshot(m, &Position{IteratorMode: modeSnapshot}, Options{
+		ResourceNames:     []string{resources.InvoiceResource},
+		InlineNestedLists: true,
+		StructuredPayload: true,
+	})
+
+	record, err := iter.Next()
+	if err != nil {
+		t.Fatalf("next error = \"%s\"", err.Error())
+	}
+
+	lines := record.Payload.After.(opencdc.StructuredData)["lines"].(map[string]interface{})
+
+	want := []interface{}{
+		map[string]interface{}{models.KeyID: "il_1"},
+		map[string]interface{}{models.KeyID: "il_2"},
+		map[string]interface{}{models.KeyID: "il_3"},
+	}
+
+	if !reflect.DeepEqual(lines[models.KeyData], want) {
+		t.Errorf("lines: got = %v, want %v", lines[models.KeyData], want)
+	}
+
+	if lines[models.KeyHasMore] != false {
+		t.Errorf("has_more: got = %v, want false", lines[models.KeyHasMore])
+	}
+}
+
+func TestSnapshotIterator_NextNestedListRecords(t *testing.T) {
+	m := mock.NewMockStripe(gomock.NewController(t))
+
+	m.EXPECT().ListResource(resources.InvoiceResource, models.ListParams{}).Return(models.ResourceResponse{
+		Data: []map[string]interface{}{invoiceWithLines()},
+	}, nil)
+	expectInvoiceLines(m)
+
+	iter := NewSnapshot(m, &Position{IteratorMode: modeSnapshot, CreatedAt: 1652790765}, Options{
+		ResourceNames:     []string{resources.InvoiceResource},
+		NestedListRecords: true,
+	})
+
+	var records []opencdc.Record
+
+	for range 4 {
+		record, err := iter.Next()
+		if err != nil {
+			t.Fatalf("next error = \"%s\"", err.Error())
+		}
+
+		records = append(records, record)
+	}
+
+	for j, id := range []string{"il_1", "il_2", "il_3"} {
+		want := opencdc.StructuredData{models.KeyID: id, models.KeyParentID: "in_1MtHbELkdIwHu7ix"}
+		if !reflect.DeepEqual(records[j].Key, want) {
+			t.Errorf("key: got = %v, want %v", records[j].Key, want)
+		}
+
+		if resource := records[j].Metadata[models.MetadataResource]; resource != "invoice.lines" {
+			t.Errorf("resource: got = %s, want invoice.lines", resource)
+		}
+
+		// the lines have the position before the invoice, so the invoice is read again after a restart,
+		// with their own numbers, so every record has its own position
+		var pos Position
+		if err := json.Unmarshal(records[j].Position, &pos); err != nil {
+			t.Fatalf("unmarshal position error = \"%s\"", err.Error())
+		}
+
+		if pos.Cursor != "" || pos.Nested != j+1 {
+			t.Errorf("position: got = %+v, want empty cursor and nested %d", pos, j+1)
+		}
+	}
+
+	invoice := records[3]
+
+	if !reflect.DeepEqual(invoice.Key, opencdc.StructuredData{models.KeyID: "in_1MtHbELkdIwHu7ix"}) {
+		t.Errorf("key: got = %v, want the invoice", invoice.Key)
+	}
+
+	if resource := invoice.Metadata[models.MetadataResource]; resource != resources.InvoiceResource {
+		t.Errorf("resource: got = %s, want %s", resource, resources.InvoiceResource)
+	}
+}
+
+func TestCDCIterator_NextNestedListRecords(t *testing.T) {
+	m := mock.NewMockStripe(gomock.NewController(t))
+
+	expectEventsRetained(m)
+	expectNoExpansion(m)
+
+	createdAt := time.Now().Unix()
+
+	m.EXPECT().GetEvent(createdAt, "", "").Return(models.EventResponse{
+		Data: []models.EventData{{
+			ID:      "evt_1",
+			Created: createdAt,
+			Data: models.EventDataObject{Object: map[string]interface{}{
+				models.KeyID: "cs_1",
+			}},
+			Type: resources.CheckoutSessionCompletedEvent,
+		}},
+	}, nil)
+
+	// the line items are not included in the checkout sessions, so all of them are listed
+	m.EXPECT().ListNested("/checkout/sessions/cs_1/line_items", "").Return(models.ResourceResponse{
+		Data: []map[string]interface{}{{models.KeyID: "li_1"}},
+	}, nil)
+
+	iter := NewCDC(m, &Position{IteratorMode: modeCDC, CreatedAt: createdAt}, Options{
+		ResourceNames:     []string{resources.CheckoutSessionResource},
+		NestedListRecords: true,
+	})
+
+	item, err := iter.Next()
+	if err != nil {
+		t.Fatalf("next error = \"%s\"", err.Error())
+	}
+
+	if item.Operation != opencdc.OperationUpdate || item.Metadata[models.MetadataParentID] != "cs_1" {
+		t.Errorf("line item: got = %v, want the update of the line item of cs_1", item)
+	}
+
+	session, err := iter.Next()
+	if err != nil {
+		t.Fatalf("next error = \"%s\"", err.Error())
+	}
+
+	if !reflect.DeepEqual(session.Key, opencdc.StructuredData{models.KeyID: "cs_1"}) {
+		t.Errorf("key: got = %v, want the checkout session", session.Key)
+	}
+}
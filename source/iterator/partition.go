@@ -0,0 +1,185 @@
+// Copyright © 2022 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/conduitio-labs/conduit-connector-stripe/models"
+)
+
+const (
+	// partitionsPerWorker is the number of partitions per worker,
+	// so the workers which finish the small partitions move on to the remaining ones.
+	partitionsPerWorker = 4
+	// partitionsFrom is the Unix time the partitions start from, which is before the launch of Stripe.
+	partitionsFrom int64 = 1262304000 // 2010-01-01
+)
+
+// A Partition represents a window of the time of creation of the objects of the resource,
+// which is read independently of the other windows.
+type Partition struct {
+	// CreatedGTE is the Unix time the objects of the partition are created at or after, zero for no lower bound.
+	CreatedGTE int64 `json:"created_gte,omitempty"`
+	// CreatedLT is the Unix time the objects of the partition are created before, zero for no upper bound.
+	CreatedLT int64 `json:"created_lt,omitempty"`
+	// Cursor is the identifier of the last returned object of the partition.
+	Cursor string `json:"cursor,omitempty"`
+	// Done reports whether all objects of the partition are returned.
+	Done bool `json:"done,omitempty"`
+}
+
+// A partitionObject represents an object of the partition received from Stripe.
+type partitionObject struct {
+	partition *Partition
+	object    map[string]interface{}
+	// last reports whether it is the last object of the partition.
+	last bool
+}
+
+// newPartitions splits the time from the time from to the time until into count equal windows.
+// The first window has no lower bound and the last window has no upper bound, so no object is left out.
+func newPartitions(from, until int64, count int) []*Partition {
+	step := (until - from) / int64(count)
+	if step <= 0 {
+		return []*Partition{{}}
+	}
+
+	partitions := make([]*Partition, count)
+
+	for i := range partitions {
+		partitions[i] = &Partition{
+			CreatedGTE: from + int64(i)*step,
+			CreatedLT:  from + int64(i+1)*step,
+		}
+	}
+
+	partitions[0].CreatedGTE = 0
+	partitions[count-1].CreatedLT = 0
+
+	return partitions
+}
+
+// partitioned reports whether the resource is read in partitions.
+// The partitions of the position are read even if the number of workers is changed, to resume them.
+func (i *Snapshot) partitioned() bool {
+	if len(i.position.Partitions) > 0 {
+		return true
+	}
+
+	_, ok := models.ResourcesWithoutCreatedFilter[i.position.Resource]
+
+	return i.workers > 1 && !ok
+}
+
+// nextPartitionedObject returns the next object of the partitions of the resource,
+// or false if all partitions are read.
+func (i *Snapshot) nextPartitionedObject() (map[string]interface{}, bool, error) {
+	if len(i.position.Partitions) == 0 {
+		// the partitions split the configured creation time range of the snapshot, if any,
+		// or the time from the launch of Stripe to the start of the snapshot
+		params := i.listParams("", partitionsFrom, i.position.CreatedAt)
+
+		i.position.Partitions = newPartitions(params.CreatedGTE, params.CreatedLT, i.workers*partitionsPerWorker)
+	}
+
+	for len(i.objects) == 0 {
+		partitions := i.activePartitions()
+		if len(partitions) == 0 {
+			i.position.Partitions = nil
+
+			return nil, false, nil
+		}
+
+		if err := i.refreshPartitions(partitions); err != nil {
+			return nil, false, fmt.Errorf("populate with the partitions of the resource: %w", err)
+		}
+	}
+
+	next := i.objects[0]
+	i.objects = i.objects[1:]
+
+	next.partition.Cursor = next.object[models.KeyID].(string)
+	next.partition.Done = next.last
+
+	return next.object, true, nil
+}
+
+// activePartitions returns the partitions which are not read yet, up to the number of workers.
+func (i *Snapshot) activePartitions() []*Partition {
+	var partitions []*Partition
+
+	for _, partition := range i.position.Partitions {
+		if len(partitions) == max(i.workers, 1) {
+			break
+		}
+
+		if !partition.Done {
+			partitions = append(partitions, partition)
+		}
+	}
+
+	return partitions
+}
+
+// refreshPartitions receives the next page of each of the partitions from Stripe concurrently,
+// and assigns their objects to the iterator in the order of the partitions.
+func (i *Snapshot) refreshPartitions(partitions []*Partition) error {
+	var (
+		responses = make([]models.ResourceResponse, len(partitions))
+		errs      = make([]error, len(partitions))
+
+		wg sync.WaitGroup
+	)
+
+	for j, partition := range partitions {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			responses[j], errs[j] = i.stripeSvc.ListResource(
+				i.position.Resource, i.listParams(partition.Cursor, partition.CreatedGTE, partition.CreatedLT),
+			)
+		}()
+	}
+
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("get list of resource objects: %w", err)
+	}
+
+	for j, resp := range responses {
+		// there is no data after the cursor, so the partition is read
+		if len(resp.Data) == 0 {
+			partitions[j].Done = true
+
+			continue
+		}
+
+		for k := range resp.Data {
+			i.objects = append(i.objects, partitionObject{
+				partition: partitions[j],
+				object:    resp.Data[k],
+				last:      !resp.HasMore && k == len(resp.Data)-1,
+			})
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,156 @@
+// Copyright © 2022 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/conduitio-labs/conduit-connector-stripe/models"
+	"github.com/conduitio-labs/conduit-connector-stripe/models/resources"
+	"github.com/conduitio-labs/conduit-connector-stripe/source/iterator/mock"
+	"github.com/conduitio/conduit-commons/opencdc"
+	"go.uber.org/mock/gomock"
+)
+
+func TestNewPartitions(t *testing.T) {
+	until := partitionsFrom + 400
+
+	want := []*Partition{
+		{CreatedLT: partitionsFrom + 100},
+		{CreatedGTE: partitionsFrom + 100, CreatedLT: partitionsFrom + 200},
+		{CreatedGTE: partitionsFrom + 200, CreatedLT: partitionsFrom + 300},
+		{CreatedGTE: partitionsFrom + 300},
+	}
+
+	if got := newPartitions(partitionsFrom, until, 4); !reflect.DeepEqual(got, want) {
+		t.Errorf("partitions: got = %v, want %v", got, want)
+	}
+
+	// the time is too short to split, so there is one partition without bounds
+	if got := newPartitions(partitionsFrom, partitionsFrom+2, 4); !reflect.DeepEqual(got, []*Partition{{}}) {
+		t.Errorf("partitions: got = %v, want one partition without bounds", got)
+	}
+}
+
+func TestSnapshotIterator_NextPartitioned(t *testing.T) {
+	const (
+		objectsCount = 200
+		batchSize    = 7
+		workers      = 3
+	)
+
+	createdAt := partitionsFrom + 10000
+
+	// the objects are sorted by date of creation in descending order, as Stripe returns them
+	objects := make([]map[string]interface{}, objectsCount)
+	for i := range objects {
+		created := createdAt - int64(i)*50
+
+		objects[i] = map[string]interface{}{
+			models.KeyID:      fmt.Sprintf("cus_%d", created),
+			models.KeyCreated: float64(created),
+		}
+	}
+
+	// the mock lists the objects the way Stripe does with the `starting_after` and `created` parameters
+	listResource := func(_ string, params models.ListParams) (models.ResourceResponse, error) {
+		var (
+			resp    models.ResourceResponse
+			started = params.StartingAfter == ""
+		)
+
+		for _, object := range objects {
+			created := int64(object[models.KeyCreated].(float64))
+
+			switch {
+			case !started:
+				started = object[models.KeyID] == params.StartingAfter
+			case params.CreatedGTE != 0 && created < params.CreatedGTE,
+				params.CreatedLT != 0 && created >= params.CreatedLT:
+			case len(resp.Data) == batchSize:
+				resp.HasMore = true
+
+				return resp, nil
+			default:
+				resp.Data = append(resp.Data, object)
+			}
+		}
+
+		return resp, nil
+	}
+
+	read := func(pos *Position, limit int) []string {
+		m := mock.NewMockStripe(gomock.NewController(t))
+		m.EXPECT().ListResource(resources.CustomerResource, gomock.Any()).DoAndReturn(listResource).AnyTimes()
+
+		iter := NewSnapshot(m, pos, Options{
+			ResourceNames:   []string{resources.CustomerResource},
+			SnapshotWorkers: workers,
+		})
+
+		var ids []string
+
+		for len(ids) < limit {
+			record, err := iter.Next()
+			if err != nil {
+				t.Fatalf("next error = \"%s\"", err.Error())
+			}
+
+			if record.Key == nil {
+				break
+			}
+
+			ids = append(ids, record.Key.(opencdc.StructuredData)[models.KeyID].(string))
+
+			// the position of the record is used to resume the snapshot
+			if err := json.Unmarshal(record.Position, pos); err != nil {
+				t.Fatalf("unmarshal position error = \"%s\"", err.Error())
+			}
+		}
+
+		return ids
+	}
+
+	pos := &Position{IteratorMode: modeSnapshot, CreatedAt: createdAt}
+
+	// read a part of the objects, and resume reading the rest of them from the position, as after a restart
+	ids := read(pos, 50)
+
+	if len(pos.Partitions) != workers*partitionsPerWorker {
+		t.Errorf("partitions: got = %d, want %d", len(pos.Partitions), workers*partitionsPerWorker)
+	}
+
+	ids = append(ids, read(pos, objectsCount)...)
+
+	if pos.IteratorMode != modeCDC || pos.Partitions != nil {
+		t.Errorf("position: got = %+v, want cdc position without partitions", pos)
+	}
+
+	want := make([]string, len(objects))
+	for i := range objects {
+		want[i] = objects[i][models.KeyID].(string)
+	}
+
+	sort.Strings(ids)
+	sort.Strings(want)
+
+	if !reflect.DeepEqual(ids, want) {
+		t.Errorf("ids: got = %v, want %v", ids, want)
+	}
+}
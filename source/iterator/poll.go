@@ -0,0 +1,617 @@
+// Copyright © 2022 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"maps"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/conduitio-labs/conduit-connector-stripe/models"
+	"github.com/conduitio/conduit-commons/opencdc"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+)
+
+// A PollPosition represents the position of the polling of the list of a resource.
+type PollPosition struct {
+	// Watermark is the Unix time of creation of the latest object of the resource returned by the Poll iterator.
+	Watermark int64 `json:"watermark"`
+}
+
+// A PollEntry represents the objects of a resource seen by the Poll iterator, which are kept in memory,
+// and in the local state file, if any.
+type PollEntry struct {
+	// Seen are the objects seen by the Poll iterator, which are created in the overlap before the watermark,
+	// or which are in the list of the resource with the poll cdc strategy,
+	// where the key is the object identifier and the value is the Unix time of its creation.
+	Seen map[string]int64 `json:"seen,omitempty"`
+	// Fingerprints are the hashes of the seen objects, where the key is the object identifier,
+	// the changed objects are returned again as updates (only with the poll cdc strategy).
+	Fingerprints map[string]uint64 `json:"fingerprints,omitempty"`
+}
+
+// A seenObject represents an object seen by the Poll iterator.
+type seenObject struct {
+	resourceName string
+	id           string
+	created      int64
+	fingerprint  uint64
+	// before is the time of creation, the objects seen before are forgotten, because they are not listed anymore,
+	// it is zero if the objects are forgotten only once they are missing from the list.
+	before int64
+}
+
+// A polledObject is an object of a resource received by the Poll iterator, which is not returned yet.
+type polledObject struct {
+	resourceName string
+	object       map[string]interface{}
+	operation    opencdc.Operation
+}
+
+// A Poll represents the iterator of the resources, which receives their new objects
+// by listing the objects created since the watermark. It reads the resources without events,
+// such as the balance transactions, or all resources with the poll cdc strategy.
+// The window of the list starts the overlap before the watermark, because the objects may appear
+// in the list later than the objects created after them, and the objects seen in the overlap are skipped.
+// With the poll cdc strategy, the whole list, or the lookback window before the current time, is listed instead,
+// the seen objects are skipped unless their fingerprints are changed, and they are forgotten once they are missing
+// from the list. The objects of the snapshot records of the resources are seen,
+// so only their later changes are returned.
+// Only the watermark is in the position, the seen objects are kept in memory, and in the local state file, if any,
+// where they are stored once their records are acknowledged. Without the state file, the objects returned
+// in the overlap before the watermark, or in the list with the poll cdc strategy, are returned again after a restart.
+type Poll struct {
+	stripeSvc Stripe
+	position  *Position
+
+	// resourceNames are the names of the polled resources.
+	resourceNames []string
+	// overlap is the number of seconds the window of the list starts before the watermark.
+	overlap int64
+	// lookback is the number of seconds the window of the list starts before the current time
+	// with the poll cdc strategy, the whole list is polled if it is zero.
+	lookback int64
+	// filters are the filters of the lists of the resources.
+	filters map[string]string
+	// fingerprints reports whether the changes of the seen objects are detected by their fingerprints.
+	fingerprints bool
+	// file is the local state file, if any.
+	file *stateFile[PollEntry]
+	// account is the connected account the iterator is reading, if any.
+	account string
+
+	// seen are the objects seen by the iterator, and committed are the objects seen, whose records are acknowledged,
+	// where the key is the resource name, they are read from the state file once.
+	seen      map[string]*PollEntry
+	committed map[string]*PollEntry
+	// last is the object, whose record is the last of the pending records.
+	last *seenObject
+	// unacked are the objects of the records returned, which are not acknowledged yet, in the order of the records,
+	// where the object is nil for the records of the nested lists. They are kept only with the state file.
+	unacked []*seenObject
+
+	// structuredPayload reports whether the payloads are structured data.
+	structuredPayload bool
+
+	// objects are the new and changed objects received by the last polling in the order of their creation,
+	// which are not returned yet.
+	objects []polledObject
+
+	// nested is the reader of the lists nested in the objects.
+	nested nestedLists
+	// pending are the records of the nested lists and their object, which are not returned yet.
+	pending []opencdc.Record
+}
+
+// NewPoll initializes the poll iterator of the configured resources without events,
+// or of all configured resources with the poll cdc strategy, it returns nil if there are no such resources.
+func NewPoll(stripeSvc Stripe, pos *Position, opts Options) *Poll {
+	var resourceNames []string
+
+	for _, resourceName := range opts.ResourceNames {
+		if _, ok := models.PolledResources[resourceName]; ok || opts.PollStrategy {
+			resourceNames = append(resourceNames, resourceName)
+		}
+	}
+
+	if len(resourceNames) == 0 {
+		return nil
+	}
+
+	return &Poll{
+		stripeSvc:         stripeSvc,
+		position:          pos,
+		resourceNames:     resourceNames,
+		overlap:           int64(opts.PollOverlap / time.Second),
+		lookback:          int64(opts.PollLookback / time.Second),
+		filters:           opts.ListFilters,
+		fingerprints:      opts.PollStrategy,
+		file:              newStateFile[PollEntry](opts.PollStateFile),
+		account:           opts.Account,
+		structuredPayload: opts.StructuredPayload,
+		nested:            newNestedLists(stripeSvc, opts),
+	}
+}
+
+// Next returns the next record.
+func (i *Poll) Next() (opencdc.Record, error) {
+	if len(i.pending) > 0 {
+		record := dequeue(&i.pending)
+
+		if len(i.pending) == 0 {
+			i.track(i.last)
+		} else {
+			i.track(nil)
+		}
+
+		return record, nil
+	}
+
+	if len(i.objects) == 0 {
+		if err := i.poll(); err != nil {
+			return opencdc.Record{}, err
+		}
+
+		if len(i.objects) == 0 {
+			return opencdc.Record{}, sdk.ErrBackoffRetry
+		}
+	}
+
+	polled := dequeue(&i.objects)
+
+	previous, err := i.previousPosition()
+	if err != nil {
+		return opencdc.Record{}, err
+	}
+
+	seen := i.see(polled.resourceName, polled.object)
+
+	record, err := i.buildRecords(polled, previous)
+	if err != nil {
+		return opencdc.Record{}, err
+	}
+
+	if len(i.pending) == 0 {
+		i.track(seen)
+	} else {
+		i.last = seen
+		i.track(nil)
+	}
+
+	return record, nil
+}
+
+// track keeps the object of the record returned, if any, until the record is acknowledged,
+// if the objects are stored in the state file.
+func (i *Poll) track(seen *seenObject) {
+	if i.file != nil {
+		i.unacked = append(i.unacked, seen)
+	}
+}
+
+// commit stores the object of the oldest record, which is not acknowledged yet, in the state file, if any.
+func (i *Poll) commit() error {
+	if len(i.unacked) == 0 {
+		return nil
+	}
+
+	seen := dequeue(&i.unacked)
+	if seen == nil {
+		return nil
+	}
+
+	entry := pollEntry(i.committed, seen.resourceName)
+	entry.add(seen)
+
+	if err := i.file.store(stateKey(i.account, seen.resourceName, i.filters), entry); err != nil {
+		return fmt.Errorf("store poll state: %w", err)
+	}
+
+	return nil
+}
+
+// previousPosition returns the position before the next object, which the records of its nested lists have,
+// if the objects of the nested lists are read as records.
+func (i *Poll) previousPosition() (opencdc.Position, error) {
+	if !i.nested.records {
+		return nil, nil
+	}
+
+	position, err := i.position.marshalPosition()
+	if err != nil {
+		return nil, fmt.Errorf("build record position: %w", err)
+	}
+
+	return position, nil
+}
+
+// poll receives the new and changed objects of the resources.
+func (i *Poll) poll() error {
+	if err := i.readState(); err != nil {
+		return fmt.Errorf("read poll state: %w", err)
+	}
+
+	for _, resourceName := range i.resourceNames {
+		objects, err := i.pollResource(resourceName)
+		if err != nil {
+			return fmt.Errorf("poll %s objects: %w", resourceName, err)
+		}
+
+		i.objects = append(i.objects, objects...)
+	}
+
+	return nil
+}
+
+// readState reads the seen objects of the resources from the state file once, they are empty without the file.
+func (i *Poll) readState() error {
+	if i.seen != nil {
+		return nil
+	}
+
+	i.seen = make(map[string]*PollEntry)
+	i.committed = make(map[string]*PollEntry)
+
+	if i.file == nil {
+		return nil
+	}
+
+	state, err := i.file.read()
+	if err != nil {
+		return err
+	}
+
+	for _, resourceName := range i.resourceNames {
+		if entry, ok := state[stateKey(i.account, resourceName, i.filters)]; ok {
+			i.committed[resourceName] = entry
+			i.seen[resourceName] = &PollEntry{Seen: maps.Clone(entry.Seen), Fingerprints: maps.Clone(entry.Fingerprints)}
+		}
+	}
+
+	return nil
+}
+
+// pollResource returns the objects of the resource created in the window of its list,
+// which are not seen yet or are changed, in the order of their creation.
+// With the poll cdc strategy, the seen objects missing from the list are forgotten.
+func (i *Poll) pollResource(resourceName string) ([]polledObject, error) {
+	createdGTE := i.windowStart(resourceName)
+
+	var (
+		objects       []polledObject
+		listed        = make(map[string]struct{})
+		startingAfter string
+	)
+
+	for {
+		resp, err := i.stripeSvc.ListResource(resourceName, models.ListParams{
+			StartingAfter: startingAfter,
+			CreatedGTE:    createdGTE,
+			Filters:       i.filters,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("get list of resource objects: %w", err)
+		}
+
+		for _, object := range resp.Data {
+			id, _ := object[models.KeyID].(string)
+			listed[id] = struct{}{}
+
+			operation, ok := i.change(resourceName, object)
+			if !ok {
+				continue
+			}
+
+			objects = append(objects, polledObject{resourceName: resourceName, object: object, operation: operation})
+		}
+
+		if !resp.HasMore || len(resp.Data) == 0 {
+			break
+		}
+
+		startingAfter, _ = resp.Data[len(resp.Data)-1][models.KeyID].(string)
+	}
+
+	if i.fingerprints {
+		i.forget(resourceName, listed)
+	}
+
+	// the lists are sorted from the newest objects
+	slices.Reverse(objects)
+
+	return objects, nil
+}
+
+// windowStart returns the time of creation the window of the list of the resource starts at,
+// which is the overlap before the watermark, or the lookback before the current time with the poll cdc strategy,
+// it is zero if the whole list is polled, such as the lists of the resources without the created filter.
+func (i *Poll) windowStart(resourceName string) int64 {
+	if _, ok := models.ResourcesWithoutCreatedFilter[resourceName]; ok {
+		return 0
+	}
+
+	if !i.fingerprints {
+		return i.resourcePosition(resourceName).Watermark - i.overlap
+	}
+
+	if i.lookback == 0 {
+		return 0
+	}
+
+	return time.Now().Unix() - i.lookback
+}
+
+// forget forgets the seen objects of the resource, which are missing from its list,
+// because they are deleted or created before the window of the list.
+func (i *Poll) forget(resourceName string, listed map[string]struct{}) {
+	for _, entry := range []*PollEntry{pollEntry(i.seen, resourceName), pollEntry(i.committed, resourceName)} {
+		for id := range entry.Seen {
+			if _, ok := listed[id]; !ok {
+				delete(entry.Seen, id)
+				delete(entry.Fingerprints, id)
+			}
+		}
+	}
+}
+
+// seed sees the object of the snapshot record, if it is an object of a polled resource,
+// so only its changes since the snapshot are returned, and reports whether the object is seen.
+// The object is stored in the state file, if any, once the record is acknowledged.
+func (i *Poll) seed(record opencdc.Record) (bool, error) {
+	resourceName := record.Metadata[models.MetadataResource]
+	if !slices.Contains(i.resourceNames, resourceName) {
+		return false, nil
+	}
+
+	if err := i.readState(); err != nil {
+		return false, fmt.Errorf("read poll state: %w", err)
+	}
+
+	object, err := payloadObject(record.Payload.After)
+	if err != nil {
+		return false, fmt.Errorf("read snapshot payload: %w", err)
+	}
+
+	i.track(i.see(resourceName, object))
+
+	return true, nil
+}
+
+// change returns the operation of the object of the resource, which is a create if the object is not seen yet,
+// or an update if its fingerprint is changed, and reports whether the object is new or changed.
+func (i *Poll) change(resourceName string, object map[string]interface{}) (opencdc.Operation, bool) {
+	id, _ := object[models.KeyID].(string)
+	entry := pollEntry(i.seen, resourceName)
+
+	if _, ok := entry.Seen[id]; !ok {
+		return opencdc.OperationCreate, true
+	}
+
+	if i.fingerprints && entry.Fingerprints[id] != fingerprint(resourceName, object) {
+		return opencdc.OperationUpdate, true
+	}
+
+	return 0, false
+}
+
+// resourcePosition returns the position of the polling of the resource,
+// which starts at the position time if the resource is not polled yet.
+func (i *Poll) resourcePosition(resourceName string) *PollPosition {
+	if i.position.Polls == nil {
+		i.position.Polls = make(map[string]*PollPosition)
+	}
+
+	pos, ok := i.position.Polls[resourceName]
+	if !ok {
+		pos = &PollPosition{Watermark: i.position.CreatedAt}
+		i.position.Polls[resourceName] = pos
+	}
+
+	return pos
+}
+
+// see marks the object of the resource as seen, moves the watermark to its time of creation if it is later,
+// and returns the seen object.
+func (i *Poll) see(resourceName string, object map[string]interface{}) *seenObject {
+	pos := i.resourcePosition(resourceName)
+
+	created, _ := objectCreated(object)
+	if created > pos.Watermark {
+		pos.Watermark = created
+	}
+
+	seen := &seenObject{resourceName: resourceName, created: created}
+	seen.id, _ = object[models.KeyID].(string)
+
+	// with the poll cdc strategy, the objects are forgotten once they are missing from the list
+	if i.fingerprints {
+		seen.fingerprint = fingerprint(resourceName, object)
+	} else {
+		seen.before = i.windowStart(resourceName)
+	}
+
+	pollEntry(i.seen, resourceName).add(seen)
+
+	return seen
+}
+
+// pollEntry returns the entry of the resource, which is added if it is missing.
+func pollEntry(state map[string]*PollEntry, resourceName string) *PollEntry {
+	entry, ok := state[resourceName]
+	if !ok {
+		entry = &PollEntry{}
+		state[resourceName] = entry
+	}
+
+	return entry
+}
+
+// add adds the seen object to the entry, and forgets the objects created before the object's bound, if any.
+func (e *PollEntry) add(seen *seenObject) {
+	if e.Seen == nil {
+		e.Seen = make(map[string]int64)
+	}
+
+	e.Seen[seen.id] = seen.created
+
+	if seen.fingerprint != 0 {
+		if e.Fingerprints == nil {
+			e.Fingerprints = make(map[string]uint64)
+		}
+
+		e.Fingerprints[seen.id] = seen.fingerprint
+	}
+
+	if seen.before == 0 {
+		return
+	}
+
+	for id, created := range e.Seen {
+		if created < seen.before {
+			delete(e.Seen, id)
+			delete(e.Fingerprints, id)
+		}
+	}
+}
+
+// buildRecords returns the first record of the object, which is the record of its first nested object,
+// if the objects of the nested lists are read as records, or the record of the object otherwise.
+// The rest of the records are returned by the next calls, with the record of the object being the last one.
+func (i *Poll) buildRecords(polled polledObject, previous opencdc.Position) (opencdc.Record, error) {
+	record, err := i.buildRecord(polled)
+	if err != nil {
+		return opencdc.Record{}, err
+	}
+
+	nested, err := i.nested.buildRecords(
+		polled.resourceName, polled.object, record.Operation, previous, record.Metadata,
+	)
+	if err != nil {
+		return opencdc.Record{}, fmt.Errorf("build records of nested lists: %w", err)
+	}
+
+	if len(nested) == 0 {
+		return record, nil
+	}
+
+	i.pending = append(nested[1:], record)
+
+	return nested[0], nil
+}
+
+// buildRecord returns the create or update record of the object of the resource.
+func (i *Poll) buildRecord(polled polledObject) (opencdc.Record, error) {
+	position, err := i.position.marshalPosition()
+	if err != nil {
+		return opencdc.Record{}, fmt.Errorf("build record position: %w", err)
+	}
+
+	if err = i.nested.inlineLists(polled.resourceName, polled.object); err != nil {
+		return opencdc.Record{}, fmt.Errorf("inline nested lists: %w", err)
+	}
+
+	payload, err := buildPayload(polled.object, i.structuredPayload)
+	if err != nil {
+		return opencdc.Record{}, fmt.Errorf("build record payload: %w", err)
+	}
+
+	metadata := make(opencdc.Metadata, 3)
+	metadata[models.MetadataResource] = polled.resourceName
+
+	if created, ok := objectCreated(polled.object); ok {
+		metadata.SetCreatedAt(time.Unix(created, 0))
+	}
+
+	if livemode, ok := polled.object[models.KeyLivemode].(bool); ok {
+		metadata[models.MetadataLivemode] = strconv.FormatBool(livemode)
+	}
+
+	key := opencdc.StructuredData{models.KeyID: polled.object[models.KeyID]}
+
+	if polled.operation == opencdc.OperationUpdate {
+		return sdk.Util.Source.NewRecordUpdate(position, metadata, key, nil, payload), nil
+	}
+
+	return sdk.Util.Source.NewRecordCreate(position, metadata, key, payload), nil
+}
+
+// fingerprint returns the hash of the Stripe object of the resource, which is changed if any of its fields is changed,
+// except for its nested lists, which are truncated in the lists of the resource, and inlined in the records, if any.
+func fingerprint(resourceName string, object map[string]interface{}) uint64 {
+	if lists := models.NestedListsMap[resourceName]; len(lists) > 0 {
+		object = maps.Clone(object)
+
+		for _, list := range lists {
+			delete(object, list.Field)
+		}
+	}
+
+	// the keys of the maps are marshaled in the sorted order, so the hash of the same object is the same
+	data, err := json.Marshal(object)
+	if err != nil {
+		return 0
+	}
+
+	hash := fnv.New64a()
+	_, _ = hash.Write(data)
+
+	return hash.Sum64()
+}
+
+// payloadObject returns the Stripe object of the record payload, which is structured data or the object in JSON.
+func payloadObject(payload opencdc.Data) (map[string]interface{}, error) {
+	if structured, ok := payload.(opencdc.StructuredData); ok {
+		return structured, nil
+	}
+
+	var object map[string]interface{}
+
+	decoder := json.NewDecoder(bytes.NewReader(payload.Bytes()))
+	decoder.UseNumber()
+
+	if err := decoder.Decode(&object); err != nil {
+		return nil, fmt.Errorf("unmarshal payload: %w", err)
+	}
+
+	return object, nil
+}
+
+// flush writes the objects stored since the last write to the state file, if any.
+func (i *Poll) flush() error {
+	if err := i.file.flush(); err != nil {
+		return fmt.Errorf("flush poll state: %w", err)
+	}
+
+	return nil
+}
+
+// objectCreated returns the Unix time of creation of the Stripe object, and reports whether it is set.
+func objectCreated(object map[string]interface{}) (int64, bool) {
+	switch c := object[models.KeyCreated].(type) {
+	case json.Number:
+		created, err := c.Int64()
+
+		return created, err == nil
+	case float64:
+		return int64(c), true
+	}
+
+	return 0, false
+}
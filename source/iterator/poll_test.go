@@ -0,0 +1,462 @@
+// Copyright © 2022 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/conduitio-labs/conduit-connector-stripe/models"
+	"github.com/conduitio-labs/conduit-connector-stripe/models/resources"
+	"github.com/conduitio-labs/conduit-connector-stripe/source/iterator/mock"
+	"github.com/conduitio/conduit-commons/opencdc"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"go.uber.org/mock/gomock"
+)
+
+func TestPoll_Next(t *testing.T) {
+	const createdAt = 1652790000
+
+	var (
+		ctrl = gomock.NewController(t)
+		m    = mock.NewMockStripe(ctrl)
+		pos  = &Position{IteratorMode: modeCDC, CreatedAt: createdAt}
+		opts = Options{
+			ResourceNames: []string{resources.BalanceTransactionResource},
+			PollOverlap:   10 * time.Minute,
+			ListFilters:   map[string]string{"type": "charge"},
+		}
+
+		first  = map[string]interface{}{models.KeyID: "txn_1", models.KeyCreated: float64(createdAt + 100)}
+		second = map[string]interface{}{models.KeyID: "txn_2", models.KeyCreated: float64(createdAt + 200)}
+		// late is created before the second object, but it appears in the list after it was read
+		late  = map[string]interface{}{models.KeyID: "txn_3", models.KeyCreated: float64(createdAt + 150)}
+		third = map[string]interface{}{models.KeyID: "txn_4", models.KeyCreated: float64(createdAt + 1000)}
+	)
+
+	gomock.InOrder(
+		m.EXPECT().ListResource(resources.BalanceTransactionResource, models.ListParams{
+			CreatedGTE: createdAt - 600,
+			Filters:    opts.ListFilters,
+		}).Return(models.ResourceResponse{Data: []map[string]interface{}{second}, HasMore: true}, nil),
+		m.EXPECT().ListResource(resources.BalanceTransactionResource, models.ListParams{
+			StartingAfter: "txn_2",
+			CreatedGTE:    createdAt - 600,
+			Filters:       opts.ListFilters,
+		}).Return(models.ResourceResponse{Data: []map[string]interface{}{first}}, nil),
+		m.EXPECT().ListResource(resources.BalanceTransactionResource, models.ListParams{
+			CreatedGTE: createdAt - 400,
+			Filters:    opts.ListFilters,
+		}).Return(models.ResourceResponse{Data: []map[string]interface{}{second, late, first}}, nil),
+		m.EXPECT().ListResource(resources.BalanceTransactionResource, models.ListParams{
+			CreatedGTE: createdAt - 400,
+			Filters:    opts.ListFilters,
+		}).Return(models.ResourceResponse{Data: []map[string]interface{}{third, second, late, first}}, nil),
+		m.EXPECT().ListResource(resources.BalanceTransactionResource, models.ListParams{
+			CreatedGTE: createdAt + 400,
+			Filters:    opts.ListFilters,
+		}).Return(models.ResourceResponse{Data: []map[string]interface{}{third}}, nil),
+	)
+
+	iter := NewPoll(m, pos, opts)
+
+	for _, want := range []map[string]interface{}{first, second, late, third} {
+		record, err := iter.Next()
+		if err != nil {
+			t.Fatalf("next: %v", err)
+		}
+
+		if record.Operation != opencdc.OperationCreate {
+			t.Errorf("operation = %s, want %s", record.Operation, opencdc.OperationCreate)
+		}
+
+		if !reflect.DeepEqual(record.Key, opencdc.StructuredData{models.KeyID: want[models.KeyID]}) {
+			t.Errorf("key = %v, want %v", record.Key, want[models.KeyID])
+		}
+
+		if record.Metadata[models.MetadataResource] != resources.BalanceTransactionResource {
+			t.Errorf("resource metadata = %s", record.Metadata[models.MetadataResource])
+		}
+	}
+
+	// only the watermark is in the position, and the objects created before the overlap are forgotten
+	wantPos := &PollPosition{Watermark: createdAt + 1000}
+	if !reflect.DeepEqual(pos.Polls[resources.BalanceTransactionResource], wantPos) {
+		t.Errorf("position = %+v, want %+v", pos.Polls[resources.BalanceTransactionResource], wantPos)
+	}
+
+	wantSeen := &PollEntry{Seen: map[string]int64{"txn_4": createdAt + 1000}}
+	if !reflect.DeepEqual(iter.seen[resources.BalanceTransactionResource], wantSeen) {
+		t.Errorf("seen = %+v, want %+v", iter.seen[resources.BalanceTransactionResource], wantSeen)
+	}
+
+	if _, err := iter.Next(); !errors.Is(err, sdk.ErrBackoffRetry) {
+		t.Errorf("expected error \"%s\", got \"%v\"", sdk.ErrBackoffRetry, err)
+	}
+}
+
+func TestPoll_NextResume(t *testing.T) {
+	const createdAt = 1652790000
+
+	var (
+		ctrl = gomock.NewController(t)
+		m    = mock.NewMockStripe(ctrl)
+		pos  = &Position{IteratorMode: modeCDC, CreatedAt: createdAt}
+		opts = Options{
+			ResourceNames: []string{resources.BalanceTransactionResource},
+			PollOverlap:   time.Minute,
+			PollStateFile: filepath.Join(t.TempDir(), "poll.json"),
+		}
+
+		first  = map[string]interface{}{models.KeyID: "txn_1", models.KeyCreated: float64(createdAt + 100)}
+		second = map[string]interface{}{models.KeyID: "txn_2", models.KeyCreated: float64(createdAt + 120)}
+	)
+
+	m.EXPECT().ListResource(resources.BalanceTransactionResource, models.ListParams{CreatedGTE: createdAt - 60}).
+		Return(models.ResourceResponse{Data: []map[string]interface{}{second, first}}, nil)
+
+	iter := NewPoll(m, pos, opts)
+
+	record, err := iter.Next()
+	if err != nil {
+		t.Fatalf("next: %v", err)
+	}
+
+	// the first object is stored in the state file once its record is acknowledged
+	if err = iter.commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+
+	resumed, err := ParseSDKPosition(record.Position)
+	if err != nil {
+		t.Fatalf("parse position: %v", err)
+	}
+
+	// the second object was not returned before the restart, so it is returned after it
+	m.EXPECT().ListResource(resources.BalanceTransactionResource, models.ListParams{CreatedGTE: createdAt + 40}).
+		Return(models.ResourceResponse{Data: []map[string]interface{}{second, first}}, nil)
+
+	record, err = NewPoll(m, resumed, opts).Next()
+	if err != nil {
+		t.Fatalf("next: %v", err)
+	}
+
+	if !reflect.DeepEqual(record.Key, opencdc.StructuredData{models.KeyID: "txn_2"}) {
+		t.Errorf("key = %v, want txn_2", record.Key)
+	}
+}
+
+func TestIterator_NextPoll(t *testing.T) {
+	transaction := map[string]interface{}{models.KeyID: "txn_1", models.KeyCreated: json.Number("1652790100")}
+
+	t.Run("without_events", func(t *testing.T) {
+		m := mock.NewMockStripe(gomock.NewController(t))
+		m.EXPECT().ListResource(resources.BalanceTransactionResource, models.ListParams{CreatedGTE: 1652790000}).
+			Return(models.ResourceResponse{Data: []map[string]interface{}{transaction}}, nil)
+
+		pos := &Position{IteratorMode: modeCDC, CreatedAt: 1652790000}
+
+		iter := New(m, pos, Options{ResourceNames: []string{resources.BalanceTransactionResource}})
+
+		record, err := iter.Next(context.Background())
+		if err != nil {
+			t.Fatalf("next: %v", err)
+		}
+
+		if !reflect.DeepEqual(record.Key, opencdc.StructuredData{models.KeyID: "txn_1"}) {
+			t.Errorf("key = %v, want txn_1", record.Key)
+		}
+	})
+
+	t.Run("poll_strategy", func(t *testing.T) {
+		m := mock.NewMockStripe(gomock.NewController(t))
+		m.EXPECT().ListResource(resources.CustomerResource, models.ListParams{}).
+			Return(models.ResourceResponse{Data: []map[string]interface{}{transaction}}, nil)
+
+		pos := &Position{IteratorMode: modeCDC, Cursor: cursor, CreatedAt: 1652790000}
+
+		iter := New(m, pos, Options{ResourceNames: []string{resources.CustomerResource}, PollStrategy: true})
+
+		record, err := iter.Next(context.Background())
+		if err != nil {
+			t.Fatalf("next: %v", err)
+		}
+
+		if record.Metadata[models.MetadataResource] != resources.CustomerResource {
+			t.Errorf("resource metadata = %s", record.Metadata[models.MetadataResource])
+		}
+	})
+
+	t.Run("after_events", func(t *testing.T) {
+		m := mock.NewMockStripe(gomock.NewController(t))
+		m.EXPECT().EventExists(cursor).Return(true, nil)
+		m.EXPECT().GetEvent(int64(1652790000), "", cursor).Return(models.EventResponse{}, nil)
+		m.EXPECT().ListResource(resources.BalanceTransactionResource, models.ListParams{CreatedGTE: 1652790000}).
+			Return(models.ResourceResponse{Data: []map[string]interface{}{transaction}}, nil)
+
+		pos := &Position{IteratorMode: modeCDC, Cursor: cursor, CreatedAt: 1652790000}
+
+		iter := New(m, pos, Options{
+			ResourceNames: []string{resources.CustomerResource, resources.BalanceTransactionResource},
+		})
+
+		record, err := iter.Next(context.Background())
+		if err != nil {
+			t.Fatalf("next: %v", err)
+		}
+
+		if record.Metadata[models.MetadataResource] != resources.BalanceTransactionResource {
+			t.Errorf("resource metadata = %s", record.Metadata[models.MetadataResource])
+		}
+	})
+}
+
+func TestPoll_NextStrategy(t *testing.T) {
+	const createdAt = 1652790000
+
+	var (
+		ctrl = gomock.NewController(t)
+		m    = mock.NewMockStripe(ctrl)
+		pos  = &Position{IteratorMode: modeCDC, CreatedAt: createdAt}
+		opts = Options{
+			ResourceNames: []string{resources.ReviewResource},
+			PollOverlap:   time.Hour,
+			PollStrategy:  true,
+		}
+
+		review = map[string]interface{}{
+			models.KeyID:      "prv_1",
+			models.KeyCreated: float64(createdAt + 100),
+			"open":            true,
+		}
+		closed = map[string]interface{}{
+			models.KeyID:      "prv_1",
+			models.KeyCreated: float64(createdAt + 100),
+			"open":            false,
+		}
+		// old is created long before the position, but its changes are detected, because the whole list is polled
+		old = map[string]interface{}{
+			models.KeyID:      "prv_0",
+			models.KeyCreated: float64(createdAt - 86400),
+			"open":            true,
+		}
+		oldClosed = map[string]interface{}{
+			models.KeyID:      "prv_0",
+			models.KeyCreated: float64(createdAt - 86400),
+			"open":            false,
+		}
+	)
+
+	gomock.InOrder(
+		m.EXPECT().ListResource(resources.ReviewResource, models.ListParams{}).
+			Return(models.ResourceResponse{Data: []map[string]interface{}{review, old}}, nil),
+		m.EXPECT().ListResource(resources.ReviewResource, models.ListParams{}).
+			Return(models.ResourceResponse{Data: []map[string]interface{}{review, old}}, nil),
+		m.EXPECT().ListResource(resources.ReviewResource, models.ListParams{}).
+			Return(models.ResourceResponse{Data: []map[string]interface{}{closed, oldClosed}}, nil),
+		m.EXPECT().ListResource(resources.ReviewResource, models.ListParams{}).
+			Return(models.ResourceResponse{Data: []map[string]interface{}{closed}}, nil),
+	)
+
+	iter := NewPoll(m, pos, opts)
+
+	// the objects, which are not seen yet, are returned, even if they are created before the position
+	for _, want := range []string{"prv_0", "prv_1"} {
+		record, err := iter.Next()
+		if err != nil {
+			t.Fatalf("next: %v", err)
+		}
+
+		if record.Operation != opencdc.OperationCreate {
+			t.Errorf("operation = %s, want %s", record.Operation, opencdc.OperationCreate)
+		}
+
+		if !reflect.DeepEqual(record.Key, opencdc.StructuredData{models.KeyID: want}) {
+			t.Errorf("key = %v, want %s", record.Key, want)
+		}
+	}
+
+	// the unchanged objects are skipped
+	if _, err := iter.Next(); !errors.Is(err, sdk.ErrBackoffRetry) {
+		t.Errorf("expected error \"%s\", got \"%v\"", sdk.ErrBackoffRetry, err)
+	}
+
+	for _, want := range []string{"prv_0", "prv_1"} {
+		record, err := iter.Next()
+		if err != nil {
+			t.Fatalf("next: %v", err)
+		}
+
+		if record.Operation != opencdc.OperationUpdate {
+			t.Errorf("operation = %s, want %s", record.Operation, opencdc.OperationUpdate)
+		}
+
+		if !reflect.DeepEqual(record.Key, opencdc.StructuredData{models.KeyID: want}) {
+			t.Errorf("key = %v, want %s", record.Key, want)
+		}
+	}
+
+	if iter.seen[resources.ReviewResource].Fingerprints["prv_1"] != fingerprint(resources.ReviewResource, closed) {
+		t.Errorf("fingerprint of prv_1 is not updated")
+	}
+
+	// the objects missing from the list are forgotten
+	if _, err := iter.Next(); !errors.Is(err, sdk.ErrBackoffRetry) {
+		t.Errorf("expected error \"%s\", got \"%v\"", sdk.ErrBackoffRetry, err)
+	}
+
+	if _, ok := iter.seen[resources.ReviewResource].Seen["prv_0"]; ok {
+		t.Errorf("prv_0 is not forgotten")
+	}
+}
+
+func TestPoll_NextLookback(t *testing.T) {
+	m := mock.NewMockStripe(gomock.NewController(t))
+
+	var params models.ListParams
+
+	m.EXPECT().ListResource(resources.ReviewResource, gomock.Any()).
+		DoAndReturn(func(_ string, p models.ListParams) (models.ResourceResponse, error) {
+			params = p
+
+			return models.ResourceResponse{}, nil
+		})
+
+	iter := NewPoll(m, &Position{IteratorMode: modeCDC, CreatedAt: 1652790000}, Options{
+		ResourceNames: []string{resources.ReviewResource},
+		PollStrategy:  true,
+		PollLookback:  time.Hour,
+	})
+
+	before := time.Now().Unix()
+
+	if _, err := iter.Next(); !errors.Is(err, sdk.ErrBackoffRetry) {
+		t.Errorf("expected error \"%s\", got \"%v\"", sdk.ErrBackoffRetry, err)
+	}
+
+	// the window of the list starts the lookback before the current time
+	if params.CreatedGTE < before-3600 || params.CreatedGTE > time.Now().Unix()-3600 {
+		t.Errorf("created gte = %d, want an hour before %d", params.CreatedGTE, before)
+	}
+}
+
+func TestIterator_NextSeed(t *testing.T) {
+	var (
+		ctrl = gomock.NewController(t)
+		m    = mock.NewMockStripe(ctrl)
+		pos  = &Position{IteratorMode: modeSnapshot, CreatedAt: 1652790000}
+
+		review = map[string]interface{}{models.KeyID: "prv_1", models.KeyCreated: json.Number("1652789000"), "open": true}
+		closed = map[string]interface{}{models.KeyID: "prv_1", models.KeyCreated: json.Number("1652789000"), "open": false}
+		other  = map[string]interface{}{models.KeyID: "prv_2", models.KeyCreated: json.Number("1652789100"), "open": true}
+	)
+
+	gomock.InOrder(
+		m.EXPECT().ListResource(resources.ReviewResource, gomock.Any()).
+			Return(models.ResourceResponse{Data: []map[string]interface{}{review}}, nil),
+		m.EXPECT().ListResource(resources.ReviewResource, gomock.Any()).
+			Return(models.ResourceResponse{}, nil),
+		m.EXPECT().ListResource(resources.ReviewResource, models.ListParams{}).
+			Return(models.ResourceResponse{Data: []map[string]interface{}{other, closed}}, nil),
+	)
+
+	iter := New(m, pos, Options{
+		ResourceNames: []string{resources.ReviewResource},
+		Snapshot:      true,
+		PollStrategy:  true,
+		PollStateFile: filepath.Join(t.TempDir(), "poll.json"),
+	})
+
+	record, err := iter.Next(context.Background())
+	if err != nil {
+		t.Fatalf("next: %v", err)
+	}
+
+	if record.Operation != opencdc.OperationSnapshot {
+		t.Errorf("operation = %s, want %s", record.Operation, opencdc.OperationSnapshot)
+	}
+
+	// the object of the snapshot is stored in the state file once its record is acknowledged
+	if err = iter.Ack(context.Background(), record.Position); err != nil {
+		t.Fatalf("ack: %v", err)
+	}
+
+	if _, ok := iter.poll.committed[resources.ReviewResource].Seen["prv_1"]; !ok {
+		t.Errorf("prv_1 is not committed")
+	}
+
+	// the object of the snapshot is returned again only as its change, in the order of the time of creation
+	for _, want := range []struct {
+		id        string
+		operation opencdc.Operation
+	}{{"prv_1", opencdc.OperationUpdate}, {"prv_2", opencdc.OperationCreate}} {
+		record, err = iter.Next(context.Background())
+		if err != nil {
+			t.Fatalf("next: %v", err)
+		}
+
+		key := opencdc.StructuredData{models.KeyID: want.id}
+		if record.Operation != want.operation || !reflect.DeepEqual(record.Key, key) {
+			t.Errorf("record = %s %v, want %s %s", record.Operation, record.Key, want.operation, want.id)
+		}
+	}
+}
+
+func TestPoll_NextWithoutCreatedFilter(t *testing.T) {
+	var (
+		ctrl = gomock.NewController(t)
+		m    = mock.NewMockStripe(ctrl)
+		pos  = &Position{IteratorMode: modeCDC, CreatedAt: 1652790000}
+		opts = Options{
+			ResourceNames: []string{resources.ReportingReportTypeResource},
+			PollStrategy:  true,
+		}
+
+		reportType = map[string]interface{}{models.KeyID: "balance.summary.1", "version": float64(1)}
+		updated    = map[string]interface{}{models.KeyID: "balance.summary.1", "version": float64(2)}
+	)
+
+	gomock.InOrder(
+		m.EXPECT().ListResource(resources.ReportingReportTypeResource, models.ListParams{}).
+			Return(models.ResourceResponse{Data: []map[string]interface{}{reportType}}, nil),
+		m.EXPECT().ListResource(resources.ReportingReportTypeResource, models.ListParams{}).
+			Return(models.ResourceResponse{Data: []map[string]interface{}{updated}}, nil),
+	)
+
+	iter := NewPoll(m, pos, opts)
+
+	record, err := iter.Next()
+	if err != nil {
+		t.Fatalf("next: %v", err)
+	}
+
+	if record.Operation != opencdc.OperationCreate {
+		t.Errorf("operation = %s, want %s", record.Operation, opencdc.OperationCreate)
+	}
+
+	record, err = iter.Next()
+	if err != nil {
+		t.Fatalf("next: %v", err)
+	}
+
+	if record.Operation != opencdc.OperationUpdate {
+		t.Errorf("operation = %s, want %s", record.Operation, opencdc.OperationUpdate)
+	}
+}
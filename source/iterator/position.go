@@ -41,17 +41,48 @@ type Position struct {
 	// Cursor is the resource or event identifier for receiving shifted data in the following requests.
 	Cursor string `json:"cursor"`
 
+	// PolledAt is the Unix time of the latest successful poll of the events by the CDC iterator without the Cursor,
+	// which found no events older than the ones returned since, so no events are lost before this time.
+	PolledAt int64 `json:"polled_at,omitempty"`
+
 	// Index is the current index of the returning record from the batch of previously received resources.
 	Index int `json:"index"`
+
+	// Resource is the name of the resource the Snapshot iterator is reading, the Cursor belongs to this resource.
+	Resource string `json:"resource,omitempty"`
+
+	// Parent is the identifier of the parent object the Snapshot iterator is reading the child resource of,
+	// the Cursor belongs to the list of the child resource of this parent object.
+	Parent string `json:"parent,omitempty"`
+
+	// Page is the token of the page of the search results the Snapshot iterator is reading,
+	// where the Index is the number of the returned objects of the page.
+	Page string `json:"page,omitempty"`
+
+	// Partitions are the partitions of the resource the Snapshot iterator is reading concurrently,
+	// if there are no partitions, the Snapshot iterator reads the resource sequentially.
+	Partitions []*Partition `json:"partitions,omitempty"`
+
+	// Nested is the number of the record of the nested list object, counting from one, after the position
+	// before its parent object, so the records of the nested lists of an object have distinct positions,
+	// which follow each other, it is zero in the positions of the other records.
+	Nested int `json:"nested,omitempty"`
+
+	// Polls are the positions of the polling of the resources without events in the CDC mode,
+	// where the key is the resource name.
+	Polls map[string]*PollPosition `json:"polls,omitempty"`
+
+	// Account is the connected account the iterator is reading.
+	Account string `json:"account,omitempty"`
+
+	// Accounts are the positions of the connected accounts, where the key is the account identifier.
+	Accounts map[string]*Position `json:"accounts,omitempty"`
 }
 
 // ParseSDKPosition parses opencdc.Position and returns Position.
 func ParseSDKPosition(position opencdc.Position) (*Position, error) {
 	if position == nil {
-		return &Position{
-			IteratorMode: modeSnapshot,
-			CreatedAt:    time.Now().Unix(),
-		}, nil
+		return newPosition(), nil
 	}
 
 	pos := Position{}
@@ -64,6 +95,27 @@ func ParseSDKPosition(position opencdc.Position) (*Position, error) {
 	return &pos, nil
 }
 
+// nestedPosition returns the position of the n-th record of the nested lists of an object,
+// which is the position before the object with the number of the record.
+func nestedPosition(previous opencdc.Position, n int) (opencdc.Position, error) {
+	pos, err := ParseSDKPosition(previous)
+	if err != nil {
+		return nil, err
+	}
+
+	pos.Nested = n
+
+	return pos.marshalPosition()
+}
+
+// newPosition returns the initial Position.
+func newPosition() *Position {
+	return &Position{
+		IteratorMode: modeSnapshot,
+		CreatedAt:    time.Now().Unix(),
+	}
+}
+
 // marshalPosition marshals Position and returns opencdc.Position or an error.
 func (p Position) marshalPosition() (opencdc.Position, error) {
 	positionBytes, err := json.Marshal(p)
@@ -0,0 +1,364 @@
+// Copyright © 2022 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/conduitio-labs/conduit-connector-stripe/models"
+	"github.com/conduitio/conduit-commons/opencdc"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+)
+
+// A ReconcileEntry represents the state of the reconciliation of a resource in the state file.
+type ReconcileEntry struct {
+	// ReconciledAt is the Unix time of the latest reconciliation of the resource.
+	ReconciledAt int64 `json:"reconciled_at"`
+	// IDs are the identifiers of the objects of the resource listed by the latest reconciliation, in the sorted order.
+	IDs []string `json:"ids"`
+}
+
+// A reconciled represents the new entry of a reconciled resource, which is stored in the state file
+// once the delete records of the resource are acknowledged.
+type reconciled struct {
+	key   string
+	entry *ReconcileEntry
+}
+
+// A reconcilePass represents the reconciliation of a resource in progress, which lists a page of its objects at a time.
+type reconcilePass struct {
+	key   string
+	entry *ReconcileEntry
+	// compared are the identifiers of the objects listed by the previous reconciliation, and of the objects returned
+	// by the other iterators before the reconciliation started, in the sorted order,
+	// and listed reports whether they are listed by the reconciliation.
+	compared []string
+	listed   []bool
+	// startingAfter is the identifier of the last object of the last page listed.
+	startingAfter string
+}
+
+// A Reconciler represents the iterator of the reconciliation of the resources, which lists all objects
+// of every resource once per interval, a page at a time, and returns the delete records of the objects
+// listed by the previous reconciliation, or returned by the other iterators since it started, which are missing
+// from the list, because Stripe has no deleted events for most of the resources.
+// The identifiers of the listed objects are stored in the local state file, where the key is the resource name,
+// prefixed with the connected account, if any, and suffixed with the hash of the list filters, if any.
+// The entry of a resource is stored only once its last delete record is acknowledged,
+// so the deletes which are not acknowledged are returned again after a restart.
+// The objects returned by the other iterators are kept only in memory, so the objects returned before a restart,
+// which are deleted before the next reconciliation, are not deleted.
+type Reconciler struct {
+	stripeSvc Stripe
+	position  *Position
+
+	// resourceNames are the names of the reconciled resources.
+	resourceNames []string
+	// interval is the time between the reconciliations of a resource.
+	interval time.Duration
+	// file is the local state file.
+	file *stateFile[ReconcileEntry]
+	// account is the connected account the iterator is reading, if any.
+	account string
+	// filters are the filters of the lists of the resources.
+	filters map[string]string
+
+	// state are the entries of the resources of the state file, which is read once,
+	// with the new entries of the reconciled resources, which are not stored yet.
+	state map[string]*ReconcileEntry
+	// returned are the identifiers of the objects returned by the other iterators since the latest reconciliation
+	// of their resource started, where the key is the resource name.
+	returned map[string]map[string]struct{}
+
+	// resourceName is the name of the resource being reconciled, pass is its reconciliation in progress, if any,
+	// and deletes are the identifiers of its missing objects, which are not returned yet.
+	resourceName string
+	pass         *reconcilePass
+	deletes      []string
+
+	// unacked are the entries of the delete records returned, which are not acknowledged yet,
+	// in the order of the records, where the entry is nil for all but the last delete record of a resource.
+	unacked []*reconciled
+}
+
+// NewReconciler initializes the reconciliation iterator of the configured resources,
+// it returns nil if the reconciliation is not configured.
+func NewReconciler(stripeSvc Stripe, pos *Position, opts Options) *Reconciler {
+	if opts.ReconcileInterval <= 0 || opts.ReconcileStateFile == "" {
+		return nil
+	}
+
+	return &Reconciler{
+		stripeSvc:     stripeSvc,
+		position:      pos,
+		resourceNames: opts.ResourceNames,
+		interval:      opts.ReconcileInterval,
+		file:          newStateFile[ReconcileEntry](opts.ReconcileStateFile),
+		account:       opts.Account,
+		filters:       opts.ListFilters,
+		returned:      make(map[string]map[string]struct{}),
+	}
+}
+
+// Next returns the next delete record of the reconciliation, and reports whether there is such a record,
+// the resources are reconciled only when their interval has passed since their latest reconciliation.
+// It lists a page of the resource being reconciled at a time, and returns no record until the list is complete,
+// so the other iterators are read between the pages.
+func (i *Reconciler) Next() (opencdc.Record, bool, error) {
+	if len(i.deletes) == 0 {
+		if i.pass == nil {
+			resourceName, err := i.dueResource()
+			if err != nil || resourceName == "" {
+				return opencdc.Record{}, false, err
+			}
+
+			i.start(resourceName)
+		}
+
+		done, err := i.listPage()
+		if err != nil {
+			return opencdc.Record{}, false, fmt.Errorf("reconcile %s: %w", i.resourceName, err)
+		}
+
+		if !done {
+			return opencdc.Record{}, false, nil
+		}
+
+		if err = i.finish(); err != nil {
+			return opencdc.Record{}, false, fmt.Errorf("store reconcile state: %w", err)
+		}
+
+		if len(i.deletes) == 0 {
+			return opencdc.Record{}, false, nil
+		}
+	}
+
+	record, err := i.buildRecord(dequeue(&i.deletes))
+	if err != nil {
+		return opencdc.Record{}, false, err
+	}
+
+	var last *reconciled
+	if len(i.deletes) == 0 {
+		key := i.stateKey(i.resourceName)
+		last = &reconciled{key: key, entry: i.state[key]}
+	}
+
+	i.unacked = append(i.unacked, last)
+
+	return record, true, nil
+}
+
+// reconciling reports whether a resource is being reconciled, so its next page is listed by the next call.
+func (i *Reconciler) reconciling() bool {
+	return i.pass != nil
+}
+
+// see keeps the identifier of the object of the record returned by the other iterators, if it is an object
+// of a reconciled resource, so the object is deleted by the next reconciliation, if it is missing from the list.
+func (i *Reconciler) see(record opencdc.Record) {
+	resourceName := record.Metadata[models.MetadataResource]
+	if !slices.Contains(i.resourceNames, resourceName) {
+		return
+	}
+
+	key, ok := record.Key.(opencdc.StructuredData)
+	if !ok {
+		return
+	}
+
+	id, _ := key[models.KeyID].(string)
+
+	if record.Operation == opencdc.OperationDelete {
+		delete(i.returned[resourceName], id)
+
+		return
+	}
+
+	if i.returned[resourceName] == nil {
+		i.returned[resourceName] = make(map[string]struct{})
+	}
+
+	i.returned[resourceName][id] = struct{}{}
+}
+
+// commit stores the entry of the resource of the oldest delete record, which is not acknowledged yet,
+// if it is the last delete record of the resource.
+func (i *Reconciler) commit() error {
+	if len(i.unacked) == 0 {
+		return nil
+	}
+
+	last := dequeue(&i.unacked)
+	if last == nil {
+		return nil
+	}
+
+	if err := i.store(last); err != nil {
+		return fmt.Errorf("store reconcile state: %w", err)
+	}
+
+	return nil
+}
+
+// dueResource returns the name of the first resource, whose interval has passed since its latest reconciliation,
+// or an empty string if there is no such resource.
+func (i *Reconciler) dueResource() (string, error) {
+	if i.state == nil {
+		state, err := i.file.read()
+		if err != nil {
+			return "", fmt.Errorf("read reconcile state: %w", err)
+		}
+
+		i.state = state
+	}
+
+	now := time.Now()
+
+	for _, resourceName := range i.resourceNames {
+		entry, ok := i.state[i.stateKey(resourceName)]
+		if !ok || now.Sub(time.Unix(entry.ReconciledAt, 0)) >= i.interval {
+			return resourceName, nil
+		}
+	}
+
+	return "", nil
+}
+
+// start starts the reconciliation of the resource, which compares its list with the objects listed
+// by the previous reconciliation, and with the objects returned by the other iterators since then,
+// which may be created and deleted in the meantime. The objects returned from now on are compared
+// by the next reconciliation, because they may be created after the start of the list.
+func (i *Reconciler) start(resourceName string) {
+	key := i.stateKey(resourceName)
+
+	compared := make(map[string]struct{}, len(i.returned[resourceName]))
+	for id := range i.returned[resourceName] {
+		compared[id] = struct{}{}
+	}
+
+	if previous, ok := i.state[key]; ok {
+		for _, id := range previous.IDs {
+			compared[id] = struct{}{}
+		}
+	}
+
+	pass := &reconcilePass{
+		key:      key,
+		entry:    &ReconcileEntry{ReconciledAt: time.Now().Unix()},
+		compared: slices.Sorted(maps.Keys(compared)),
+	}
+	pass.listed = make([]bool, len(pass.compared))
+
+	i.resourceName = resourceName
+	i.pass = pass
+	i.deletes = nil
+	delete(i.returned, resourceName)
+}
+
+// listPage lists the next page of the resource being reconciled, marks its objects as listed,
+// and reports whether the list is complete.
+func (i *Reconciler) listPage() (bool, error) {
+	resp, err := i.stripeSvc.ListResource(i.resourceName, models.ListParams{
+		StartingAfter: i.pass.startingAfter,
+		Filters:       i.filters,
+	})
+	if err != nil {
+		return false, fmt.Errorf("get list of resource objects: %w", err)
+	}
+
+	for _, object := range resp.Data {
+		id, ok := object[models.KeyID].(string)
+		if !ok {
+			continue
+		}
+
+		i.pass.entry.IDs = append(i.pass.entry.IDs, id)
+
+		if j, found := slices.BinarySearch(i.pass.compared, id); found {
+			i.pass.listed[j] = true
+		}
+	}
+
+	if !resp.HasMore || len(resp.Data) == 0 {
+		return true, nil
+	}
+
+	i.pass.startingAfter, _ = resp.Data[len(resp.Data)-1][models.KeyID].(string)
+
+	return false, nil
+}
+
+// finish finishes the reconciliation of the resource, and keeps the identifiers of the compared objects,
+// which are missing from the list, as the deletes. The entry of the resource without deletes is stored at once,
+// because there is nothing to acknowledge.
+func (i *Reconciler) finish() error {
+	pass := i.pass
+	i.pass = nil
+
+	slices.Sort(pass.entry.IDs)
+
+	for j, id := range pass.compared {
+		if !pass.listed[j] {
+			i.deletes = append(i.deletes, id)
+		}
+	}
+
+	// the resource is not reconciled again until the next interval, even though its entry is not stored yet
+	i.state[pass.key] = pass.entry
+
+	if len(i.deletes) > 0 {
+		return nil
+	}
+
+	return i.store(&reconciled{key: pass.key, entry: pass.entry})
+}
+
+// store stores the entry of the reconciled resource in the state file.
+func (i *Reconciler) store(r *reconciled) error {
+	return i.file.store(r.key, r.entry)
+}
+
+// flush writes the entries stored since the last write to the state file.
+func (i *Reconciler) flush() error {
+	if err := i.file.flush(); err != nil {
+		return fmt.Errorf("flush reconcile state: %w", err)
+	}
+
+	return nil
+}
+
+// stateKey returns the key of the entry of the resource in the state file,
+// which depends on the list filters, because the objects missing from a list with other filters are not deleted.
+func (i *Reconciler) stateKey(resourceName string) string {
+	return stateKey(i.account, resourceName, i.filters)
+}
+
+// buildRecord returns the delete record of the missing object of the reconciled resource.
+func (i *Reconciler) buildRecord(id string) (opencdc.Record, error) {
+	position, err := i.position.marshalPosition()
+	if err != nil {
+		return opencdc.Record{}, fmt.Errorf("build record position: %w", err)
+	}
+
+	metadata := make(opencdc.Metadata, 2)
+	metadata.SetCreatedAt(time.Now())
+	metadata[models.MetadataResource] = i.resourceName
+
+	return sdk.Util.Source.NewRecordDelete(position, metadata, opencdc.StructuredData{models.KeyID: id}, nil), nil
+}
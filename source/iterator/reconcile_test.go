@@ -0,0 +1,179 @@
+// Copyright © 2022 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/conduitio-labs/conduit-connector-stripe/models"
+	"github.com/conduitio-labs/conduit-connector-stripe/models/resources"
+	"github.com/conduitio-labs/conduit-connector-stripe/source/iterator/mock"
+	"github.com/conduitio/conduit-commons/opencdc"
+	"go.uber.org/mock/gomock"
+)
+
+func TestReconciler_Next(t *testing.T) {
+	var (
+		ctrl      = gomock.NewController(t)
+		m         = mock.NewMockStripe(ctrl)
+		stateFile = filepath.Join(t.TempDir(), "reconcile.json")
+		opts      = Options{
+			ResourceNames:      []string{resources.PaymentMethodResource},
+			ReconcileInterval:  time.Hour,
+			ReconcileStateFile: stateFile,
+			Account:            "acct_1032D82eZvKYlo2C",
+		}
+		key = "acct_1032D82eZvKYlo2C/" + resources.PaymentMethodResource
+	)
+
+	gomock.InOrder(
+		m.EXPECT().ListResource(resources.PaymentMethodResource, models.ListParams{}).
+			Return(models.ResourceResponse{
+				Data: []map[string]interface{}{{models.KeyID: "pm_3"}, {models.KeyID: "pm_2"}}, HasMore: true,
+			}, nil),
+		m.EXPECT().ListResource(resources.PaymentMethodResource, models.ListParams{StartingAfter: "pm_2"}).
+			Return(models.ResourceResponse{Data: []map[string]interface{}{{models.KeyID: "pm_1"}}}, nil),
+		m.EXPECT().ListResource(resources.PaymentMethodResource, models.ListParams{}).
+			Return(models.ResourceResponse{Data: []map[string]interface{}{{models.KeyID: "pm_2"}}}, nil),
+	)
+
+	// the list is read a page at a time, there are no deletes on the first reconciliation,
+	// and the resource is not listed again within the interval
+	iter := NewReconciler(m, &Position{}, opts)
+	for range 3 {
+		if _, ok, err := iter.Next(); err != nil || ok {
+			t.Fatalf("next = %t, %v, want no record", ok, err)
+		}
+	}
+
+	state, err := readState[ReconcileEntry](stateFile)
+	if err != nil {
+		t.Fatalf("read reconcile state: %v", err)
+	}
+
+	if !reflect.DeepEqual(state[key].IDs, []string{"pm_1", "pm_2", "pm_3"}) {
+		t.Fatalf("ids = %v, want [pm_1 pm_2 pm_3]", state[key].IDs)
+	}
+
+	// the interval has passed since the first reconciliation
+	state[key].ReconciledAt = time.Now().Add(-2 * time.Hour).Unix()
+	if err = writeState(stateFile, state); err != nil {
+		t.Fatalf("write reconcile state: %v", err)
+	}
+
+	iter = NewReconciler(m, &Position{}, opts)
+
+	for _, want := range []string{"pm_1", "pm_3"} {
+		record, ok, err := iter.Next()
+		if err != nil || !ok {
+			t.Fatalf("next = %t, %v, want record", ok, err)
+		}
+
+		if record.Operation != opencdc.OperationDelete {
+			t.Errorf("operation = %s, want %s", record.Operation, opencdc.OperationDelete)
+		}
+
+		if !reflect.DeepEqual(record.Key, opencdc.StructuredData{models.KeyID: want}) {
+			t.Errorf("key = %v, want %s", record.Key, want)
+		}
+
+		if record.Metadata[models.MetadataResource] != resources.PaymentMethodResource {
+			t.Errorf("resource metadata = %s", record.Metadata[models.MetadataResource])
+		}
+	}
+
+	if _, ok, err := iter.Next(); err != nil || ok {
+		t.Fatalf("next = %t, %v, want no record", ok, err)
+	}
+
+	// the entry is stored only once the last delete record is acknowledged
+	for _, want := range [][]string{{"pm_1", "pm_2", "pm_3"}, {"pm_1", "pm_2", "pm_3"}, {"pm_2"}} {
+		state, err = readState[ReconcileEntry](stateFile)
+		if err != nil {
+			t.Fatalf("read reconcile state: %v", err)
+		}
+
+		if !reflect.DeepEqual(state[key].IDs, want) {
+			t.Errorf("ids = %v, want %v", state[key].IDs, want)
+		}
+
+		if err = iter.commit(); err != nil {
+			t.Fatalf("commit: %v", err)
+		}
+	}
+}
+
+func TestReconciler_NextReturned(t *testing.T) {
+	var (
+		ctrl = gomock.NewController(t)
+		m    = mock.NewMockStripe(ctrl)
+		opts = Options{
+			ResourceNames:      []string{resources.PaymentMethodResource},
+			ReconcileInterval:  time.Hour,
+			ReconcileStateFile: filepath.Join(t.TempDir(), "reconcile.json"),
+		}
+	)
+
+	m.EXPECT().ListResource(resources.PaymentMethodResource, models.ListParams{}).
+		Return(models.ResourceResponse{Data: []map[string]interface{}{{models.KeyID: "pm_1"}}}, nil)
+
+	iter := NewReconciler(m, &Position{}, opts)
+
+	// pm_2 is created and deleted before the first reconciliation, and pm_3 is created and then deleted by an event
+	for _, record := range []opencdc.Record{
+		{
+			Operation: opencdc.OperationCreate,
+			Metadata:  opencdc.Metadata{models.MetadataResource: resources.PaymentMethodResource},
+			Key:       opencdc.StructuredData{models.KeyID: "pm_2"},
+		},
+		{
+			Operation: opencdc.OperationSnapshot,
+			Metadata:  opencdc.Metadata{models.MetadataResource: resources.PaymentMethodResource},
+			Key:       opencdc.StructuredData{models.KeyID: "pm_3"},
+		},
+		{
+			Operation: opencdc.OperationDelete,
+			Metadata:  opencdc.Metadata{models.MetadataResource: resources.PaymentMethodResource},
+			Key:       opencdc.StructuredData{models.KeyID: "pm_3"},
+		},
+		{
+			Operation: opencdc.OperationCreate,
+			Metadata:  opencdc.Metadata{models.MetadataResource: resources.CustomerResource},
+			Key:       opencdc.StructuredData{models.KeyID: "cus_1"},
+		},
+	} {
+		iter.see(record)
+	}
+
+	record, ok, err := iter.Next()
+	if err != nil || !ok {
+		t.Fatalf("next = %t, %v, want record", ok, err)
+	}
+
+	if !reflect.DeepEqual(record.Key, opencdc.StructuredData{models.KeyID: "pm_2"}) {
+		t.Errorf("key = %v, want pm_2", record.Key)
+	}
+
+	if _, ok, err = iter.Next(); err != nil || ok {
+		t.Fatalf("next = %t, %v, want no record", ok, err)
+	}
+
+	if len(iter.returned) != 0 {
+		t.Errorf("returned = %v, want none", iter.returned)
+	}
+}
@@ -15,75 +15,389 @@
 package iterator
 
 import (
-	"encoding/json"
 	"fmt"
+	"slices"
+	"strconv"
 	"time"
 
 	"github.com/conduitio-labs/conduit-connector-stripe/models"
+	"github.com/conduitio-labs/conduit-connector-stripe/models/resources"
 	"github.com/conduitio/conduit-commons/opencdc"
 	sdk "github.com/conduitio/conduit-connector-sdk"
 )
 
 // A Snapshot represents a struct of snapshot iterator.
 type Snapshot struct {
-	stripeSvc Stripe
-	position  *Position
-	response  *models.ResourceResponse
-	index     int
+	stripeSvc     Stripe
+	position      *Position
+	resourceNames []string
+	response      *models.ResourceResponse
+	index         int
+
+	// structuredPayload reports whether the payloads are structured data.
+	structuredPayload bool
+
+	// createdAfter and createdBefore are the bounds of the time of creation of the objects.
+	createdAfter  models.TimeBound
+	createdBefore models.TimeBound
+	// filters are the filters of the lists of the resources.
+	filters map[string]string
+	// searchQuery is the query of the Stripe Search API, the resources are listed if it is empty.
+	searchQuery string
+	// eventTypes are the patterns of the types of the objects of the event resource.
+	eventTypes []string
+
+	// workers is the number of partitions of the resource read concurrently.
+	workers int
+	// objects are the objects of the partitions received from Stripe, which are not returned yet.
+	objects []partitionObject
+
+	// parents are the parent objects of the child resource received from Stripe,
+	// and parentIndex is the index of the next of them.
+	parents     *models.ResourceResponse
+	parentIndex int
+
+	// nested is the reader of the lists nested in the objects.
+	nested nestedLists
+	// pending are the records of the nested lists and their object, which are not returned yet.
+	pending []opencdc.Record
 }
 
-// NewSnapshot initializes snapshot iterator.
-func NewSnapshot(stripeSvc Stripe, pos *Position) *Snapshot {
+// NewSnapshot initializes snapshot iterator, which reads the resources one after another.
+func NewSnapshot(stripeSvc Stripe, pos *Position, opts Options) *Snapshot {
+	// start from the first resource if the position has no resource, or the resource is no longer configured
+	if !slices.Contains(opts.ResourceNames, pos.Resource) && len(opts.ResourceNames) > 0 {
+		pos.Resource = opts.ResourceNames[0]
+		pos.Cursor = ""
+		pos.Parent = ""
+		pos.Page = ""
+		pos.Index = 0
+		pos.Partitions = nil
+	}
+
 	return &Snapshot{
-		stripeSvc: stripeSvc,
-		position:  pos,
+		stripeSvc:         stripeSvc,
+		position:          pos,
+		resourceNames:     opts.ResourceNames,
+		structuredPayload: opts.StructuredPayload,
+		createdAfter:      opts.SnapshotCreatedAfter,
+		createdBefore:     opts.SnapshotCreatedBefore,
+		filters:           opts.ListFilters,
+		searchQuery:       opts.SearchQuery,
+		eventTypes:        opts.EventTypes,
+		workers:           opts.SnapshotWorkers,
+		nested:            newNestedLists(stripeSvc, opts),
 	}
 }
 
 // Next returns the next record.
 // Note: The `Snapshot` iterator creates a copy of the data, which is sorted by date of creation in descending order.
 func (i *Snapshot) Next() (opencdc.Record, error) {
-	if i.response == nil || len(i.response.Data) == i.index {
-		if err := i.refreshData(); err != nil {
-			return opencdc.Record{}, fmt.Errorf("populate with the resource: %w", err)
+	if len(i.pending) > 0 {
+		return dequeue(&i.pending), nil
+	}
+
+	for {
+		// the records of the nested lists of the object have the position before the object
+		var previous opencdc.Position
+
+		if i.nested.records {
+			var err error
+
+			previous, err = i.position.marshalPosition()
+			if err != nil {
+				return opencdc.Record{}, fmt.Errorf("build record position: %w", err)
+			}
 		}
 
-		// if there is no data - go to `CDC` iterator
-		if len(i.response.Data) == 0 {
+		object, ok, err := i.nextObject()
+		if err != nil {
+			return opencdc.Record{}, err
+		}
+
+		if ok && i.skip(object) {
+			continue
+		}
+
+		if ok {
+			return i.buildRecords(object, previous)
+		}
+
+		// if there is no data and no more resources - go to `CDC` iterator
+		if !i.nextResource() {
 			i.position.IteratorMode = modeCDC
+			i.position.Resource = ""
 			i.position.Cursor = ""
+			i.position.Parent = ""
 
 			return opencdc.Record{}, nil
 		}
 	}
+}
+
+// skip reports whether the object is skipped, which are the events, whose types do not match the patterns.
+func (i *Snapshot) skip(object map[string]interface{}) bool {
+	if i.position.Resource != resources.EventResource {
+		return false
+	}
+
+	eventType, _ := object[models.KeyType].(string)
+
+	return !models.MatchEventType(i.eventTypes, eventType)
+}
+
+// nextObject returns the next object of the resource, or false if all objects of the resource are returned.
+func (i *Snapshot) nextObject() (map[string]interface{}, bool, error) {
+	if i.searchQuery != "" {
+		return i.nextSearchObject()
+	}
+
+	if _, ok := models.ChildResourcesMap[i.position.Resource]; ok {
+		return i.nextChildObject()
+	}
+
+	if i.partitioned() {
+		return i.nextPartitionedObject()
+	}
+
+	if i.response == nil || len(i.response.Data) == i.index {
+		if err := i.refreshData(); err != nil {
+			return nil, false, fmt.Errorf("populate with the resource: %w", err)
+		}
+
+		if len(i.response.Data) == 0 {
+			return nil, false, nil
+		}
+	}
+
+	object := i.response.Data[i.index]
+
+	i.position.Cursor = object[models.KeyID].(string)
+	i.index++
+
+	return object, true, nil
+}
+
+// nextChildObject returns the next object of the child resource, which are listed under every parent object,
+// or false if all objects of the child resource are returned.
+func (i *Snapshot) nextChildObject() (map[string]interface{}, bool, error) {
+	for {
+		if i.position.Parent != "" {
+			if i.response == nil || (len(i.response.Data) == i.index && i.response.HasMore) {
+				if err := i.refreshChildData(); err != nil {
+					return nil, false, err
+				}
+			}
+
+			if i.index < len(i.response.Data) {
+				object := i.response.Data[i.index]
 
-	i.position.Cursor = i.response.Data[i.index][models.KeyID].(string)
+				i.position.Cursor = object[models.KeyID].(string)
+				i.index++
 
+				return object, true, nil
+			}
+		}
+
+		// all objects of the parent object are returned, so the iterator moves to the next parent object
+		parentID, err := i.nextParent()
+		if err != nil {
+			return nil, false, err
+		}
+
+		i.response = nil
+		i.position.Cursor = ""
+		i.position.Parent = parentID
+
+		if parentID == "" {
+			i.parents = nil
+
+			return nil, false, nil
+		}
+	}
+}
+
+// nextParent returns the identifier of the parent object after the one of the position,
+// or an empty string if there are no more parent objects.
+func (i *Snapshot) nextParent() (string, error) {
+	if i.parents == nil || len(i.parents.Data) == i.parentIndex {
+		if i.parents != nil && !i.parents.HasMore {
+			return "", nil
+		}
+
+		parent := models.ChildResourcesMap[i.position.Resource].Parent
+
+		// only the identifiers of the parent objects are read, so they are not expanded
+		resp, err := i.stripeSvc.ListResource(parent, models.ListParams{
+			StartingAfter: i.position.Parent,
+			WithoutExpand: true,
+		})
+		if err != nil {
+			return "", fmt.Errorf("get list of parent objects: %w", err)
+		}
+
+		i.parents = &resp
+		i.parentIndex = 0
+
+		if len(resp.Data) == 0 {
+			return "", nil
+		}
+	}
+
+	parentID, _ := i.parents.Data[i.parentIndex][models.KeyID].(string)
+
+	i.parentIndex++
+
+	return parentID, nil
+}
+
+// refreshChildData receives the objects of the child resource of the parent object of the position from Stripe,
+// and assigns them to the iterator.
+func (i *Snapshot) refreshChildData() error {
+	params := models.ListParams{
+		ParentID:      i.position.Parent,
+		StartingAfter: i.position.Cursor,
+		Filters:       i.filters,
+	}
+
+	resp, err := i.stripeSvc.ListResource(i.position.Resource, params)
+	if err != nil {
+		return fmt.Errorf("get list of resource objects of %s: %w", i.position.Parent, err)
+	}
+
+	i.response = &resp
+	i.index = 0
+
+	return nil
+}
+
+// nextSearchObject returns the next object of the resource matching the search query,
+// or false if all matching objects of the resource are returned.
+func (i *Snapshot) nextSearchObject() (map[string]interface{}, bool, error) {
+	if i.response == nil {
+		// the page of the position is received again, and its returned objects are skipped
+		if err := i.refreshSearchData(); err != nil {
+			return nil, false, err
+		}
+
+		i.index = min(i.position.Index, len(i.response.Data))
+	}
+
+	for len(i.response.Data) == i.index {
+		if !i.response.HasMore || i.response.NextPage == "" {
+			i.response = nil
+			i.position.Page = ""
+			i.position.Index = 0
+
+			return nil, false, nil
+		}
+
+		i.position.Page = i.response.NextPage
+		i.position.Index = 0
+
+		if err := i.refreshSearchData(); err != nil {
+			return nil, false, err
+		}
+	}
+
+	object := i.response.Data[i.index]
+
+	i.index++
+	i.position.Index = i.index
+	i.position.Cursor = object[models.KeyID].(string)
+
+	return object, true, nil
+}
+
+// refreshSearchData receives the page of the position of the search results from Stripe,
+// and assigns them to the iterator.
+func (i *Snapshot) refreshSearchData() error {
+	resp, err := i.stripeSvc.SearchResource(i.position.Resource, i.searchQuery, i.position.Page)
+	if err != nil {
+		return fmt.Errorf("search resource objects: %w", err)
+	}
+
+	i.response = &resp
+	i.index = 0
+
+	return nil
+}
+
+// buildRecords returns the first record of the object, which is the record of its first nested object,
+// if the objects of the nested lists are read as records, or the record of the object otherwise.
+// The rest of the records are returned by the next calls, with the record of the object being the last one.
+func (i *Snapshot) buildRecords(object map[string]interface{}, previous opencdc.Position) (opencdc.Record, error) {
+	record, err := i.buildRecord(object)
+	if err != nil {
+		return opencdc.Record{}, err
+	}
+
+	nested, err := i.nested.buildRecords(i.position.Resource, object, record.Operation, previous, record.Metadata)
+	if err != nil {
+		return opencdc.Record{}, fmt.Errorf("build records of nested lists: %w", err)
+	}
+
+	if len(nested) == 0 {
+		return record, nil
+	}
+
+	i.pending = append(nested[1:], record)
+
+	return nested[0], nil
+}
+
+// buildRecord returns the record of the object.
+func (i *Snapshot) buildRecord(object map[string]interface{}) (opencdc.Record, error) {
 	position, err := i.position.marshalPosition()
 	if err != nil {
 		return opencdc.Record{}, fmt.Errorf("build record position: %w", err)
 	}
 
-	payload, err := i.buildRecordPayload()
+	if err = i.nested.inlineLists(i.position.Resource, object); err != nil {
+		return opencdc.Record{}, fmt.Errorf("inline nested lists: %w", err)
+	}
+
+	payload, err := i.buildRecordPayload(object)
 	if err != nil {
 		return opencdc.Record{}, fmt.Errorf("build record payload: %w", err)
 	}
 
-	record := sdk.Util.Source.NewRecordSnapshot(
+	return sdk.Util.Source.NewRecordSnapshot(
 		position,
-		i.buildRecordMetadata(),
-		i.buildRecordKey(),
+		i.buildRecordMetadata(object),
+		i.buildRecordKey(object),
 		payload,
-	)
+	), nil
+}
 
-	i.index++
+// nextResource moves the position to the beginning of the next resource,
+// and reports whether there is such a resource.
+func (i *Snapshot) nextResource() bool {
+	for j := range i.resourceNames {
+		if i.resourceNames[j] != i.position.Resource {
+			continue
+		}
+
+		if j+1 == len(i.resourceNames) {
+			return false
+		}
+
+		i.position.Resource = i.resourceNames[j+1]
+		i.position.Cursor = ""
+		i.position.Parent = ""
+		i.position.Page = ""
+		i.position.Index = 0
+		i.position.Partitions = nil
 
-	return record, nil
+		return true
+	}
+
+	return false
 }
 
 // refreshData receives the resource data from Stripe, and assigns them to the iterator.
 func (i *Snapshot) refreshData() error {
-	resp, err := i.stripeSvc.GetResource(i.position.Cursor)
+	resp, err := i.stripeSvc.ListResource(i.position.Resource, i.listParams(i.position.Cursor, 0, 0))
 	if err != nil {
 		return fmt.Errorf("get list of resource objects: %w", err)
 	}
@@ -94,32 +408,70 @@ func (i *Snapshot) refreshData() error {
 	return nil
 }
 
+// listParams returns the parameters of the list request of the objects after the cursor,
+// which are created in the window from createdGTE to createdLT, where zero means no bound,
+// narrowed to the configured creation time range of the snapshot.
+func (i *Snapshot) listParams(cursor string, createdGTE, createdLT int64) models.ListParams {
+	// the durations are relative to the start of the snapshot, so the range is the same after a restart
+	if after := i.createdAfter.Unix(i.position.CreatedAt); after > createdGTE {
+		createdGTE = after
+	}
+
+	if before := i.createdBefore.Unix(i.position.CreatedAt); before != 0 && (createdLT == 0 || before < createdLT) {
+		createdLT = before
+	}
+
+	return models.ListParams{
+		StartingAfter: cursor,
+		CreatedGTE:    createdGTE,
+		CreatedLT:     createdLT,
+		Filters:       i.filters,
+	}
+}
+
 // buildRecordMetadata returns the metadata for the record.
-func (i *Snapshot) buildRecordMetadata() map[string]string {
-	metadata := make(opencdc.Metadata, 1)
+func (i *Snapshot) buildRecordMetadata(object map[string]interface{}) map[string]string {
+	metadata := make(opencdc.Metadata, 3)
 
 	createdAt := time.Now()
-	if c, ok := i.response.Data[i.index][models.KeyCreated].(float64); ok {
-		createdAt = time.Unix(int64(c), 0)
+	if created, ok := objectCreated(object); ok {
+		createdAt = time.Unix(created, 0)
 	}
+
 	metadata.SetCreatedAt(createdAt)
+	metadata[models.MetadataResource] = i.position.Resource
+
+	if i.position.Parent != "" {
+		metadata[models.MetadataParentID] = i.position.Parent
+	}
+
+	if i.position.Resource == resources.EventResource {
+		metadata[models.MetadataEventID], _ = object[models.KeyID].(string)
+		metadata[models.MetadataEventType], _ = object[models.KeyType].(string)
+	}
+
+	if livemode, ok := object[models.KeyLivemode].(bool); ok {
+		metadata[models.MetadataLivemode] = strconv.FormatBool(livemode)
+	}
 
 	return metadata
 }
 
 // buildRecordKey returns the key for the record.
-func (i *Snapshot) buildRecordKey() opencdc.Data {
-	return opencdc.StructuredData{
-		models.KeyID: i.response.Data[i.index][models.KeyID].(string),
+func (i *Snapshot) buildRecordKey(object map[string]interface{}) opencdc.Data {
+	key := opencdc.StructuredData{
+		models.KeyID: object[models.KeyID].(string),
 	}
-}
 
-// buildRecordPayload returns the payload for the record.
-func (i *Snapshot) buildRecordPayload() (opencdc.Data, error) {
-	payload, err := json.Marshal(i.response.Data[i.index])
-	if err != nil {
-		return nil, fmt.Errorf("marshal payload: %w", err)
+	// the objects of the child resources are keyed by their parent object
+	if i.position.Parent != "" {
+		key[models.KeyParentID] = i.position.Parent
 	}
 
-	return opencdc.RawData(payload), nil
+	return key
+}
+
+// buildRecordPayload returns the payload for the record.
+func (i *Snapshot) buildRecordPayload(object map[string]interface{}) (opencdc.Data, error) {
+	return buildPayload(object, i.structuredPayload)
 }
@@ -16,11 +16,13 @@ package iterator
 
 import (
 	"encoding/json"
+	"math"
 	"reflect"
 	"testing"
 	"time"
 
 	"github.com/conduitio-labs/conduit-connector-stripe/models"
+	"github.com/conduitio-labs/conduit-connector-stripe/models/resources"
 	"github.com/conduitio-labs/conduit-connector-stripe/source/iterator/mock"
 	"github.com/conduitio/conduit-commons/opencdc"
 	"go.uber.org/mock/gomock"
@@ -51,9 +53,9 @@ func TestSnapshotIterator_Next(t *testing.T) {
 		}
 
 		m := mock.NewMockStripe(ctrl)
-		m.EXPECT().GetResource(pos.Cursor).Return(result, nil)
+		m.EXPECT().ListResource(resources.CustomerResource, models.ListParams{StartingAfter: pos.Cursor}).Return(result, nil)
 
-		iter := NewSnapshot(m, &pos)
+		iter := NewSnapshot(m, &pos, Options{ResourceNames: []string{resources.CustomerResource}})
 
 		for i := 0; i < len(result.Data); i++ {
 			record, err := iter.Next()
@@ -100,3 +102,340 @@ func TestSnapshotIterator_Next(t *testing.T) {
 		}
 	})
 }
+
+func TestSnapshotIterator_NextStructuredPayload(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	object := map[string]interface{}{
+		models.KeyID:      "cus_LY6gsj",
+		models.KeyCreated: json.Number("1651153903"),
+		"balance":         json.Number("9007199254740993"),
+	}
+
+	pos := Position{
+		IteratorMode: modeSnapshot,
+		CreatedAt:    1652790765,
+	}
+
+	m := mock.NewMockStripe(ctrl)
+	m.EXPECT().ListResource(resources.CustomerResource, models.ListParams{StartingAfter: pos.Cursor}).Return(models.ResourceResponse{
+		Data: []map[string]interface{}{object},
+	}, nil)
+
+	iter := NewSnapshot(m, &pos, Options{
+		ResourceNames:     []string{resources.CustomerResource},
+		StructuredPayload: true,
+	})
+
+	record, err := iter.Next()
+	if err != nil {
+		t.Fatalf("next error = \"%s\"", err.Error())
+	}
+
+	if !reflect.DeepEqual(record.Payload.After, opencdc.StructuredData(object)) {
+		t.Errorf("payload: got = %v, want %v", record.Payload.After, object)
+	}
+
+	createdAt, err := record.Metadata.GetCreatedAt()
+	if err != nil {
+		t.Errorf("get created_at error = \"%s\"", err.Error())
+	}
+
+	if createdAtWant := time.Unix(1651153903, 0); !createdAt.Equal(createdAtWant) {
+		t.Errorf("created_at: got = %v, want %v", createdAt, createdAtWant)
+	}
+}
+
+func TestSnapshotIterator_NextResource(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	customers := models.ResourceResponse{
+		Data: []map[string]interface{}{
+			{
+				models.KeyID:      "cus_LY6gsj",
+				models.KeyObject:  "customer",
+				models.KeyCreated: float64(1651153903),
+			},
+		},
+	}
+
+	invoices := models.ResourceResponse{
+		Data: []map[string]interface{}{
+			{
+				models.KeyID:      "in_1LajCF",
+				models.KeyObject:  "invoice",
+				models.KeyCreated: float64(1651153850),
+			},
+		},
+	}
+
+	pos := Position{
+		IteratorMode: modeSnapshot,
+		CreatedAt:    1652790765,
+	}
+
+	m := mock.NewMockStripe(ctrl)
+	gomock.InOrder(
+		m.EXPECT().ListResource(resources.CustomerResource, models.ListParams{}).Return(customers, nil),
+		m.EXPECT().ListResource(resources.CustomerResource, models.ListParams{StartingAfter: "cus_LY6gsj"}).Return(models.ResourceResponse{}, nil),
+		m.EXPECT().ListResource(resources.InvoiceResource, models.ListParams{}).Return(invoices, nil),
+		m.EXPECT().ListResource(resources.InvoiceResource, models.ListParams{StartingAfter: "in_1LajCF"}).Return(models.ResourceResponse{}, nil),
+	)
+
+	iter := NewSnapshot(m, &pos, Options{ResourceNames: []string{resources.CustomerResource, resources.InvoiceResource}})
+
+	for _, want := range []struct {
+		id       string
+		resource string
+	}{
+		{id: "cus_LY6gsj", resource: resources.CustomerResource},
+		{id: "in_1LajCF", resource: resources.InvoiceResource},
+	} {
+		record, err := iter.Next()
+		if err != nil {
+			t.Errorf("next error = \"%s\"", err.Error())
+		}
+
+		if !reflect.DeepEqual(record.Key, opencdc.StructuredData{models.KeyID: want.id}) {
+			t.Errorf("key: got = %v, want %v", string(record.Key.Bytes()), want.id)
+		}
+
+		if record.Metadata[models.MetadataResource] != want.resource {
+			t.Errorf("resource: got = %v, want %v", record.Metadata[models.MetadataResource], want.resource)
+		}
+	}
+
+	// there are no more resources - switch to the `CDC` iterator
+	record, err := iter.Next()
+	if err != nil {
+		t.Errorf("next error = \"%s\"", err.Error())
+	}
+
+	if record.Key != nil {
+		t.Errorf("key: got = %v, want nil", string(record.Key.Bytes()))
+	}
+
+	if pos.IteratorMode != modeCDC || pos.Resource != "" || pos.Cursor != "" {
+		t.Errorf("position: got = %+v, want empty cdc position", pos)
+	}
+}
+
+func TestSnapshotIterator_NextFiltered(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	pos := Position{
+		IteratorMode: modeSnapshot,
+		CreatedAt:    1652790765,
+	}
+
+	filters := map[string]string{"status": "paid"}
+
+	m := mock.NewMockStripe(ctrl)
+	// the duration is relative to the start of the snapshot
+	m.EXPECT().ListResource(resources.InvoiceResource, models.ListParams{
+		CreatedGTE: pos.CreatedAt - 90*24*60*60,
+		CreatedLT:  1651153903,
+		Filters:    filters,
+	}).Return(models.ResourceResponse{}, nil)
+
+	iter := NewSnapshot(m, &pos, Options{
+		ResourceNames:         []string{resources.InvoiceResource},
+		SnapshotCreatedAfter:  models.TimeBound{Before: 90 * 24 * time.Hour},
+		SnapshotCreatedBefore: models.TimeBound{Time: time.Unix(1651153903, 0)},
+		ListFilters:           filters,
+	})
+
+	record, err := iter.Next()
+	if err != nil {
+		t.Fatalf("next error = \"%s\"", err.Error())
+	}
+
+	if record.Key != nil {
+		t.Errorf("key: got = %v, want nil", string(record.Key.Bytes()))
+	}
+}
+
+func TestSnapshotIterator_NextSearch(t *testing.T) {
+	const query = "metadata['tenant']:'acme'"
+
+	pages := map[string]models.ResourceResponse{
+		"": {
+			Data: []map[string]interface{}{
+				{models.KeyID: "cus_LY6gsj"},
+				{models.KeyID: "cus_LY6gsk"},
+			},
+			HasMore:  true,
+			NextPage: "page_2",
+		},
+		"page_2": {
+			Data: []map[string]interface{}{
+				{models.KeyID: "cus_LY6gsl"},
+			},
+		},
+	}
+
+	read := func(pos *Position, limit int) []string {
+		m := mock.NewMockStripe(gomock.NewController(t))
+		m.EXPECT().SearchResource(resources.CustomerResource, query, gomock.Any()).DoAndReturn(
+			func(_, _, page string) (models.ResourceResponse, error) {
+				return pages[page], nil
+			},
+		).AnyTimes()
+
+		iter := NewSnapshot(m, pos, Options{
+			ResourceNames: []string{resources.CustomerResource},
+			SearchQuery:   query,
+		})
+
+		var ids []string
+
+		for len(ids) < limit {
+			record, err := iter.Next()
+			if err != nil {
+				t.Fatalf("next error = \"%s\"", err.Error())
+			}
+
+			if record.Key == nil {
+				break
+			}
+
+			ids = append(ids, record.Key.(opencdc.StructuredData)[models.KeyID].(string))
+
+			if err := json.Unmarshal(record.Position, pos); err != nil {
+				t.Fatalf("unmarshal position error = \"%s\"", err.Error())
+			}
+		}
+
+		return ids
+	}
+
+	pos := &Position{IteratorMode: modeSnapshot, CreatedAt: 1652790765}
+
+	// read the first object, and resume reading the rest of them from the position, as after a restart
+	ids := read(pos, 1)
+
+	if pos.Page != "" || pos.Index != 1 {
+		t.Errorf("position: got = %+v, want the first page with one returned object", pos)
+	}
+
+	ids = append(ids, read(pos, math.MaxInt)...)
+
+	if want := []string{"cus_LY6gsj", "cus_LY6gsk", "cus_LY6gsl"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("ids: got = %v, want %v", ids, want)
+	}
+
+	if pos.IteratorMode != modeCDC || pos.Page != "" || pos.Index != 0 {
+		t.Errorf("position: got = %+v, want empty cdc position", pos)
+	}
+}
+
+func TestSnapshotIterator_NextChildResource(t *testing.T) {
+	m := mock.NewMockStripe(gomock.NewController(t))
+
+	// the customers are listed page by page, and the tax IDs are listed under every customer
+	gomock.InOrder(
+		m.EXPECT().ListResource(resources.CustomerResource, models.ListParams{WithoutExpand: true}).Return(
+			models.ResourceResponse{
+				Data:    []map[string]interface{}{{models.KeyID: "cus_1"}},
+				HasMore: true,
+			}, nil),
+		m.EXPECT().ListResource(resources.TaxIDResource, models.ListParams{ParentID: "cus_1"}).Return(
+			models.ResourceResponse{
+				Data:    []map[string]interface{}{{models.KeyID: "txi_1"}},
+				HasMore: true,
+			}, nil),
+		m.EXPECT().ListResource(resources.TaxIDResource, models.ListParams{ParentID: "cus_1", StartingAfter: "txi_1"}).Return(
+			models.ResourceResponse{
+				Data: []map[string]interface{}{{models.KeyID: "txi_2"}},
+			}, nil),
+		m.EXPECT().ListResource(
+			resources.CustomerResource, models.ListParams{StartingAfter: "cus_1", WithoutExpand: true},
+		).Return(
+			models.ResourceResponse{
+				Data: []map[string]interface{}{{models.KeyID: "cus_2"}, {models.KeyID: "cus_3"}},
+			}, nil),
+		m.EXPECT().ListResource(resources.TaxIDResource, models.ListParams{ParentID: "cus_2"}).Return(
+			models.ResourceResponse{}, nil),
+		m.EXPECT().ListResource(resources.TaxIDResource, models.ListParams{ParentID: "cus_3"}).Return(
+			models.ResourceResponse{
+				Data: []map[string]interface{}{{models.KeyID: "txi_3"}},
+			}, nil),
+	)
+
+	pos := &Position{IteratorMode: modeSnapshot, CreatedAt: 1652790765}
+
+	iter := NewSnapshot(m, pos, Options{ResourceNames: []string{resources.TaxIDResource}})
+
+	var keys []opencdc.Data
+
+	for {
+		record, err := iter.Next()
+		if err != nil {
+			t.Fatalf("next error = \"%s\"", err.Error())
+		}
+
+		if record.Key == nil {
+			break
+		}
+
+		if record.Metadata[models.MetadataParentID] != record.Key.(opencdc.StructuredData)[models.KeyParentID] {
+			t.Errorf("parent id: got = %s, want the parent id of the key", record.Metadata[models.MetadataParentID])
+		}
+
+		keys = append(keys, record.Key)
+	}
+
+	want := []opencdc.Data{
+		opencdc.StructuredData{models.KeyID: "txi_1", models.KeyParentID: "cus_1"},
+		opencdc.StructuredData{models.KeyID: "txi_2", models.KeyParentID: "cus_1"},
+		opencdc.StructuredData{models.KeyID: "txi_3", models.KeyParentID: "cus_3"},
+	}
+
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("keys: got = %v, want %v", keys, want)
+	}
+
+	if pos.IteratorMode != modeCDC || pos.Parent != "" || pos.Cursor != "" {
+		t.Errorf("position: got = %+v, want empty cdc position", pos)
+	}
+}
+
+func TestSnapshotIterator_NextEvents(t *testing.T) {
+	m := mock.NewMockStripe(gomock.NewController(t))
+
+	m.EXPECT().ListResource(resources.EventResource, models.ListParams{}).Return(models.ResourceResponse{
+		Data: []map[string]interface{}{
+			{models.KeyID: "evt_2", models.KeyType: "customer.updated"},
+			{models.KeyID: "evt_1", models.KeyType: "invoice.paid"},
+		},
+	}, nil)
+	m.EXPECT().ListResource(resources.EventResource, models.ListParams{StartingAfter: "evt_1"}).
+		Return(models.ResourceResponse{}, nil)
+
+	iter := NewSnapshot(m, &Position{IteratorMode: modeSnapshot}, Options{
+		ResourceNames: []string{resources.EventResource},
+		EventTypes:    []string{"invoice.*"},
+	})
+
+	record, err := iter.Next()
+	if err != nil {
+		t.Fatalf("next error = \"%s\"", err.Error())
+	}
+
+	if !reflect.DeepEqual(record.Key, opencdc.StructuredData{models.KeyID: "evt_1"}) {
+		t.Errorf("key: got = %v, want evt_1", record.Key)
+	}
+
+	if record.Metadata[models.MetadataEventType] != "invoice.paid" {
+		t.Errorf("event type: got = %s, want invoice.paid", record.Metadata[models.MetadataEventType])
+	}
+
+	record, err = iter.Next()
+	if err != nil {
+		t.Fatalf("next error = \"%s\"", err.Error())
+	}
+
+	if record.Key != nil {
+		t.Errorf("key: got = %v, want the end of the snapshot", record.Key)
+	}
+}
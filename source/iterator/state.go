@@ -0,0 +1,161 @@
+// Copyright © 2022 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// stateKey returns the key of the entry of the resource in a local state file, which is the resource name,
+// prefixed with the connected account, if any, and suffixed with the hash of the list filters, if any,
+// because the objects of the lists with other filters are other objects.
+func stateKey(account, resourceName string, filters map[string]string) string {
+	key := resourceName
+	if account != "" {
+		key = account + "/" + key
+	}
+
+	if len(filters) == 0 {
+		return key
+	}
+
+	params := make([]string, 0, len(filters))
+	for k, v := range filters {
+		params = append(params, k+"="+v)
+	}
+
+	slices.Sort(params)
+
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(strings.Join(params, "&")))
+
+	return key + "#" + strconv.FormatUint(hash.Sum64(), 16)
+}
+
+// readState reads the entries of the local state file, which are empty if the file does not exist.
+func readState[E any](path string) (map[string]*E, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return make(map[string]*E), nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("read file: %w", err)
+	}
+
+	state := make(map[string]*E)
+	if err = json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("unmarshal state: %w", err)
+	}
+
+	return state, nil
+}
+
+// writeState writes the entries to the local state file,
+// through a temporary file, which is renamed, so the state file is never partially written.
+func writeState[E any](path string, state map[string]*E) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("marshal state: %w", err)
+	}
+
+	tmp := path + ".tmp"
+
+	if err = os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("write temporary file: %w", err)
+	}
+
+	if err = os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("rename temporary file: %w", err)
+	}
+
+	return nil
+}
+
+// stateWriteInterval is the minimum time between the writes of a local state file,
+// so the acknowledgments of the records do not rewrite the file every time.
+const stateWriteInterval = time.Second
+
+// A stateFile represents a local state file, whose entries are stored in memory,
+// and written to the file at most once per stateWriteInterval, and when the file is flushed.
+// The entries stored since the last write are lost if the connector stops without flushing the file,
+// so their records are read again after a restart.
+type stateFile[E any] struct {
+	path string
+
+	// dirty are the entries stored since the last write, where the key is the key of the entry.
+	dirty map[string]*E
+	// written is the time of the last write.
+	written time.Time
+}
+
+// newStateFile initializes the local state file on the path, it returns nil if the path is empty.
+func newStateFile[E any](path string) *stateFile[E] {
+	if path == "" {
+		return nil
+	}
+
+	return &stateFile[E]{path: path, dirty: make(map[string]*E)}
+}
+
+// read reads the entries of the file, which are empty if the file does not exist.
+func (f *stateFile[E]) read() (map[string]*E, error) {
+	return readState[E](f.path)
+}
+
+// store stores the entry, which is written as it is at the time of the write,
+// once the write interval has passed since the last write.
+func (f *stateFile[E]) store(key string, entry *E) error {
+	f.dirty[key] = entry
+
+	if time.Since(f.written) < stateWriteInterval {
+		return nil
+	}
+
+	return f.flush()
+}
+
+// flush writes the entries stored since the last write, if any, to the file, which is read again,
+// so the entries of the other connected accounts are kept.
+func (f *stateFile[E]) flush() error {
+	if f == nil || len(f.dirty) == 0 {
+		return nil
+	}
+
+	state, err := readState[E](f.path)
+	if err != nil {
+		return err
+	}
+
+	maps.Copy(state, f.dirty)
+
+	if err = writeState(f.path, state); err != nil {
+		return err
+	}
+
+	clear(f.dirty)
+	f.written = time.Now()
+
+	return nil
+}
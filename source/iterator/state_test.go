@@ -0,0 +1,88 @@
+// Copyright © 2022 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/conduitio-labs/conduit-connector-stripe/models/resources"
+)
+
+func TestStateKey(t *testing.T) {
+	const account = "acct_1032D82eZvKYlo2C"
+
+	if key := stateKey(account, resources.ChargeResource, nil); key != account+"/"+resources.ChargeResource {
+		t.Errorf("key = %s, want %s/%s", key, account, resources.ChargeResource)
+	}
+
+	filtered := stateKey("", resources.ChargeResource, map[string]string{"customer": "cus_LY6gsj"})
+	if filtered == resources.ChargeResource {
+		t.Errorf("key = %s, want a key of the filters", filtered)
+	}
+
+	// the objects of the list with the other filters are other objects
+	if key := stateKey("", resources.ChargeResource, map[string]string{"customer": "cus_Lb7uMJ"}); key == filtered {
+		t.Errorf("key = %s, want a key other than %s", key, filtered)
+	}
+}
+
+func TestStateFile_Store(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	// the entries of the other connected accounts are kept
+	if err := writeState(path, map[string]*PollEntry{"acct_2/review": {Seen: map[string]int64{"prv_2": 2}}}); err != nil {
+		t.Fatalf("write state: %v", err)
+	}
+
+	file := newStateFile[PollEntry](path)
+
+	first := &PollEntry{Seen: map[string]int64{"prv_1": 1}}
+	second := &PollEntry{Seen: map[string]int64{"prv_1": 1, "prv_3": 3}}
+
+	// the first entry is written at once, and the second one is written only within the interval once it is flushed
+	for _, entry := range []*PollEntry{first, second} {
+		if err := file.store("acct_1/review", entry); err != nil {
+			t.Fatalf("store: %v", err)
+		}
+
+		state, err := readState[PollEntry](path)
+		if err != nil {
+			t.Fatalf("read state: %v", err)
+		}
+
+		if !reflect.DeepEqual(state["acct_1/review"], first) {
+			t.Errorf("entry = %+v, want %+v", state["acct_1/review"], first)
+		}
+	}
+
+	if err := file.flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+
+	state, err := readState[PollEntry](path)
+	if err != nil {
+		t.Fatalf("read state: %v", err)
+	}
+
+	want := map[string]*PollEntry{
+		"acct_1/review": second,
+		"acct_2/review": {Seen: map[string]int64{"prv_2": 2}},
+	}
+	if !reflect.DeepEqual(state, want) {
+		t.Errorf("state = %+v, want %+v", state, want)
+	}
+}
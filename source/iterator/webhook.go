@@ -0,0 +1,251 @@
+// Copyright © 2022 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/conduitio-labs/conduit-connector-stripe/models"
+	"github.com/conduitio-labs/conduit-connector-stripe/stripe"
+	"github.com/conduitio/conduit-commons/opencdc"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+)
+
+const (
+	// webhookMaxBodySize is the maximum size of the webhook request body.
+	webhookMaxBodySize = 1 << 20
+	// webhookReadHeaderTimeout is the amount of time allowed to read the webhook request headers.
+	webhookReadHeaderTimeout = 10 * time.Second
+	// webhookCatchUpInterval is the time between the catch-ups of the events since the cursor,
+	// which move the cursor over the events delivered to the listener.
+	webhookCatchUpInterval = 10 * time.Minute
+)
+
+// A Webhook represents a struct of webhook iterator.
+type Webhook struct {
+	cdc       *CDC
+	position  *Position
+	secret    string
+	tolerance time.Duration
+
+	server *http.Server
+
+	// events is a channel of the verified events received by the listener.
+	events chan models.EventData
+	// done is closed when the iterator is stopped, once, so the iterator can be stopped more than once.
+	done     chan struct{}
+	stopOnce sync.Once
+
+	// caughtUp reports whether the CDC iterator has read all the events since the position,
+	// caughtUpAt is the time of the latest catch-up, and catchUpInterval is the time between the catch-ups.
+	caughtUp        bool
+	caughtUpAt      time.Time
+	catchUpInterval time.Duration
+
+	// seen is a set of identifiers of the events read by the CDC iterator,
+	// to skip the same events delivered by Stripe to the listener during the catch-up.
+	seen map[string]struct{}
+	// delivered is a set of identifiers of the events delivered to the listener,
+	// to skip the same events read by the CDC iterator during the next catch-up.
+	delivered map[string]struct{}
+}
+
+// NewWebhook initializes webhook iterator, which listens for Stripe events on the address.
+// The iterator reads the events since the position with the CDC iterator first,
+// and then the events delivered to the listener.
+// The cursor of the position is moved only by the CDC iterator, because Stripe does not deliver the events in order,
+// so the events since the cursor are read again by the CDC iterator periodically, skipping the delivered events,
+// to move the cursor, and after a restart, when the delivered events since the cursor are read again.
+func NewWebhook(
+	ctx context.Context, cdc *CDC, pos *Position, addr, secret string, tolerance time.Duration,
+) (*Webhook, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listen on %s: %w", addr, err)
+	}
+
+	w := &Webhook{
+		cdc:       cdc,
+		position:  pos,
+		secret:    secret,
+		tolerance: tolerance,
+		events:    make(chan models.EventData),
+		done:      make(chan struct{}),
+		seen:      make(map[string]struct{}),
+		delivered: make(map[string]struct{}),
+
+		catchUpInterval: webhookCatchUpInterval,
+	}
+
+	w.server = &http.Server{
+		Handler:           w,
+		ReadHeaderTimeout: webhookReadHeaderTimeout,
+	}
+
+	go func() {
+		if err := w.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			sdk.Logger(ctx).Error().Err(err).Msg("webhook listener stopped")
+		}
+	}()
+
+	return w, nil
+}
+
+// Next returns the next record, it blocks until an event is delivered or the context is canceled.
+func (w *Webhook) Next(ctx context.Context) (opencdc.Record, error) {
+	if len(w.cdc.pending) > 0 {
+		return dequeue(&w.cdc.pending), nil
+	}
+
+	previous, err := w.cdc.previousPosition()
+	if err != nil {
+		return opencdc.Record{}, err
+	}
+
+	for {
+		if !w.caughtUp {
+			record, ok, err := w.catchUp(previous)
+			if err != nil || ok {
+				return record, err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return opencdc.Record{}, ctx.Err()
+		case <-time.After(time.Until(w.caughtUpAt.Add(w.catchUpInterval))):
+			w.caughtUp = false
+		case event := <-w.events:
+			if _, ok := w.seen[event.ID]; ok {
+				delete(w.seen, event.ID)
+
+				continue
+			}
+
+			resourceName, ok := w.cdc.eventResource(event.Type)
+			if !ok {
+				continue
+			}
+
+			w.delivered[event.ID] = struct{}{}
+
+			return w.cdc.buildRecords(event, resourceName, previous)
+		}
+	}
+}
+
+// catchUp returns the record of the next event since the cursor read by the CDC iterator,
+// which is not delivered to the listener yet, and reports whether there is such an event.
+func (w *Webhook) catchUp(previous opencdc.Position) (opencdc.Record, bool, error) {
+	for {
+		event, resourceName, err := w.cdc.nextEvent()
+		if errors.Is(err, sdk.ErrBackoffRetry) {
+			w.caughtUp = true
+			w.caughtUpAt = time.Now()
+
+			return opencdc.Record{}, false, nil
+		}
+
+		if err != nil {
+			return opencdc.Record{}, false, err
+		}
+
+		if _, ok := w.delivered[event.ID]; ok {
+			delete(w.delivered, event.ID)
+
+			continue
+		}
+
+		w.seen[event.ID] = struct{}{}
+
+		record, err := w.cdc.buildRecords(event, resourceName, previous)
+
+		return record, true, err
+	}
+}
+
+// reset makes the iterator read the events since the position with the CDC iterator again.
+func (w *Webhook) reset(cdc *CDC) {
+	w.cdc = cdc
+	w.caughtUp = false
+	w.seen = make(map[string]struct{})
+	w.delivered = make(map[string]struct{})
+}
+
+// Stop shuts down the listener, it can be called more than once.
+func (w *Webhook) Stop(ctx context.Context) error {
+	w.stopOnce.Do(func() { close(w.done) })
+
+	if err := w.server.Shutdown(ctx); err != nil {
+		return fmt.Errorf("shutdown webhook listener: %w", err)
+	}
+
+	return nil
+}
+
+// ServeHTTP verifies the signature of the event delivered by Stripe, and passes the event to the iterator.
+// The response is sent only after the event is read, so Stripe retries the delivery if the iterator is stopped.
+func (w *Webhook) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	payload, err := io.ReadAll(http.MaxBytesReader(rw, r.Body, webhookMaxBodySize))
+	if err != nil {
+		http.Error(rw, fmt.Sprintf("read body: %s", err), http.StatusBadRequest)
+
+		return
+	}
+
+	err = stripe.VerifySignature(payload, r.Header.Get(stripe.SignatureHeader), w.secret, w.tolerance, time.Now())
+	if err != nil {
+		http.Error(rw, fmt.Sprintf("verify signature: %s", err), http.StatusBadRequest)
+
+		return
+	}
+
+	var event models.EventData
+
+	// the numbers are decoded as json.Number, the same way as in the responses of the Stripe client
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.UseNumber()
+
+	err = dec.Decode(&event)
+	if err != nil {
+		http.Error(rw, fmt.Sprintf("unmarshal event: %s", err), http.StatusBadRequest)
+
+		return
+	}
+
+	select {
+	case <-r.Context().Done():
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	case <-w.done:
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	case w.events <- event:
+		rw.WriteHeader(http.StatusOK)
+	}
+}
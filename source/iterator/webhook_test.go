@@ -0,0 +1,287 @@
+// Copyright © 2022 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/conduitio-labs/conduit-connector-stripe/models"
+	"github.com/conduitio-labs/conduit-connector-stripe/models/resources"
+	"github.com/conduitio-labs/conduit-connector-stripe/source/iterator/mock"
+	"github.com/conduitio-labs/conduit-connector-stripe/stripe"
+	"github.com/conduitio/conduit-commons/opencdc"
+	"go.uber.org/mock/gomock"
+)
+
+const (
+	webhookSecret    = "whsec_test"
+	webhookTolerance = 5 * time.Minute
+)
+
+func TestWebhookIterator_ServeHTTP(t *testing.T) {
+	payload, err := json.Marshal(models.EventData{
+		ID:   "evt_1652447199",
+		Type: resources.CustomerCreatedEvent,
+	})
+	if err != nil {
+		t.Fatalf("marshal event error = \"%s\"", err.Error())
+	}
+
+	tests := []struct {
+		name       string
+		header     string
+		wantStatus int
+	}{
+		{
+			name:       "no signature",
+			header:     "",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "wrong secret",
+			header:     signWebhookPayload(payload, "whsec_wrong", time.Now()),
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "timestamp outside the tolerance",
+			header:     signWebhookPayload(payload, webhookSecret, time.Now().Add(-2*webhookTolerance)),
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	w, err := NewWebhook(context.Background(), NewCDC(nil, &Position{}, Options{}), &Position{},
+		"127.0.0.1:0", webhookSecret, webhookTolerance)
+	if err != nil {
+		t.Fatalf("new webhook error = \"%s\"", err.Error())
+	}
+	defer w.Stop(context.Background()) //nolint:errcheck // the error is irrelevant to the test
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(payload))
+			req.Header.Set(stripe.SignatureHeader, tt.header)
+
+			rec := httptest.NewRecorder()
+			w.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status: got = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestWebhookIterator_Next(t *testing.T) {
+	var (
+		ctrl = gomock.NewController(t)
+		m    = mock.NewMockStripe(ctrl)
+	)
+
+	polled := models.EventData{
+		ID:      "evt_1652447179",
+		Created: 1652447179,
+		Data: models.EventDataObject{Object: map[string]interface{}{
+			models.KeyID: "cus_1651153850",
+		}},
+		Type: resources.CustomerCreatedEvent,
+	}
+
+	delivered := models.EventData{
+		ID:      "evt_1652447199",
+		Created: 1652447199,
+		Data: models.EventDataObject{Object: map[string]interface{}{
+			models.KeyID: "cus_1651153850",
+		}},
+		Type: resources.CustomerUpdatedEvent,
+	}
+
+	pos := &Position{
+		IteratorMode: modeCDC,
+		Cursor:       cursor,
+		CreatedAt:    1652790765,
+	}
+
+	m.EXPECT().GetEvent(pos.CreatedAt, "", cursor).Return(models.EventResponse{
+		Data: models.EventsData{polled},
+	}, nil)
+	m.EXPECT().GetEvent(pos.CreatedAt, "", polled.ID).Return(models.EventResponse{}, nil)
+	expectNoExpansion(m)
+	expectEventsRetained(m)
+
+	cdc := NewCDC(m, pos, Options{ResourceNames: []string{resources.CustomerResource}})
+
+	w, err := NewWebhook(context.Background(), cdc, pos, "127.0.0.1:0", webhookSecret, webhookTolerance)
+	if err != nil {
+		t.Fatalf("new webhook error = \"%s\"", err.Error())
+	}
+	defer w.Stop(context.Background()) //nolint:errcheck // the error is irrelevant to the test
+
+	// the gap since the position is filled by polling the events
+	record, err := w.Next(context.Background())
+	if err != nil {
+		t.Errorf("next error = \"%s\"", err.Error())
+	}
+
+	if record.Operation != opencdc.OperationCreate {
+		t.Errorf("operation: got = %v, want %v", record.Operation, opencdc.OperationCreate)
+	}
+
+	// the polled event is delivered once again and must be skipped
+	for _, event := range []models.EventData{polled, delivered} {
+		go func(event models.EventData) {
+			payload, err := json.Marshal(event)
+			if err != nil {
+				t.Errorf("marshal event error = \"%s\"", err.Error())
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(payload))
+			req.Header.Set(stripe.SignatureHeader, signWebhookPayload(payload, webhookSecret, time.Now()))
+
+			w.ServeHTTP(httptest.NewRecorder(), req)
+		}(event)
+
+		if event.ID == polled.ID {
+			// wait for the duplicate to be delivered first
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	record, err = w.Next(ctx)
+	if err != nil {
+		t.Fatalf("next error = \"%s\"", err.Error())
+	}
+
+	if record.Operation != opencdc.OperationUpdate {
+		t.Errorf("operation: got = %v, want %v", record.Operation, opencdc.OperationUpdate)
+	}
+
+	// the cursor is moved only by the events read by the CDC iterator, because the deliveries are not ordered
+	if pos.Cursor != polled.ID {
+		t.Errorf("cursor: got = %v, want %v", pos.Cursor, polled.ID)
+	}
+
+	wantKey := opencdc.StructuredData{models.KeyID: delivered.Data.Object[models.KeyID]}
+	if !reflect.DeepEqual(record.Key, wantKey) {
+		t.Errorf("key: got = %v, want %v", string(record.Key.Bytes()), string(wantKey.Bytes()))
+	}
+}
+
+func TestWebhookIterator_NextCatchUp(t *testing.T) {
+	var (
+		ctrl = gomock.NewController(t)
+		m    = mock.NewMockStripe(ctrl)
+		pos  = &Position{IteratorMode: modeCDC, Cursor: cursor, CreatedAt: 1652790765}
+	)
+
+	delivered := models.EventData{
+		ID:      "evt_1652447199",
+		Created: 1652447199,
+		Data: models.EventDataObject{Object: map[string]interface{}{
+			models.KeyID: "cus_1651153850",
+		}},
+		Type: resources.CustomerUpdatedEvent,
+	}
+
+	gomock.InOrder(
+		m.EXPECT().GetEvent(pos.CreatedAt, "", cursor).Return(models.EventResponse{}, nil),
+		m.EXPECT().GetEvent(pos.CreatedAt, "", cursor).Return(models.EventResponse{
+			Data: models.EventsData{delivered},
+		}, nil),
+		m.EXPECT().GetEvent(pos.CreatedAt, "", delivered.ID).Return(models.EventResponse{}, nil).AnyTimes(),
+	)
+	expectNoExpansion(m)
+	expectEventsRetained(m)
+
+	w, err := NewWebhook(context.Background(), NewCDC(m, pos, Options{
+		ResourceNames: []string{resources.CustomerResource},
+	}), pos, "127.0.0.1:0", webhookSecret, webhookTolerance)
+	if err != nil {
+		t.Fatalf("new webhook error = \"%s\"", err.Error())
+	}
+	defer w.Stop(context.Background()) //nolint:errcheck // the error is irrelevant to the test
+
+	w.catchUpInterval = 50 * time.Millisecond
+
+	go func() {
+		payload, err := json.Marshal(delivered)
+		if err != nil {
+			t.Errorf("marshal event error = \"%s\"", err.Error())
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(payload))
+		req.Header.Set(stripe.SignatureHeader, signWebhookPayload(payload, webhookSecret, time.Now()))
+
+		w.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if _, err = w.Next(ctx); err != nil {
+		t.Fatalf("next error = \"%s\"", err.Error())
+	}
+
+	// the next catch-up moves the cursor over the delivered event, without returning it again
+	ctx, cancel = context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	if _, err = w.Next(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected error \"%s\", got \"%v\"", context.DeadlineExceeded, err)
+	}
+
+	if pos.Cursor != delivered.ID {
+		t.Errorf("cursor: got = %v, want %v", pos.Cursor, delivered.ID)
+	}
+}
+
+func signWebhookPayload(payload []byte, secret string, timestamp time.Time) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.%s", timestamp.Unix(), payload)
+
+	return fmt.Sprintf("t=%d,v1=%s", timestamp.Unix(), hex.EncodeToString(mac.Sum(nil)))
+}
+
+func TestWebhookIterator_StopTwice(t *testing.T) {
+	m := mock.NewMockStripe(gomock.NewController(t))
+
+	pos := &Position{IteratorMode: modeCDC, CreatedAt: 1652790765}
+	cdc := NewCDC(m, pos, Options{ResourceNames: []string{resources.CustomerResource}})
+
+	w, err := NewWebhook(context.Background(), cdc, pos, "127.0.0.1:0", webhookSecret, webhookTolerance)
+	if err != nil {
+		t.Fatalf("new webhook error = \"%s\"", err.Error())
+	}
+
+	for range 2 {
+		if err = w.Stop(context.Background()); err != nil {
+			t.Errorf("stop error = \"%s\"", err.Error())
+		}
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright © 2022 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package source
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/conduitio-labs/conduit-connector-stripe/models"
+	"github.com/conduitio-labs/conduit-connector-stripe/models/openapi"
+	"github.com/conduitio/conduit-commons/opencdc"
+	"github.com/conduitio/conduit-connector-sdk/schema"
+	"github.com/hamba/avro/v2"
+)
+
+const (
+	keySubjectFmt     = "%s.key"
+	payloadSubjectFmt = "%s.payload"
+)
+
+// A resourceSchema represents the key and payload schemas of a resource registered in the schema registry.
+type resourceSchema struct {
+	key     schema.Schema
+	payload schema.Schema
+	// payloadAvro is the Avro schema of the payload, to normalize the payloads for it.
+	payloadAvro avro.Schema
+}
+
+// attachSchemas attaches the key and payload schemas of the resource of the record to its metadata,
+// and normalizes the payload for the payload schema, whose nested objects are JSON strings already.
+// The configuration validates that all configured resources have a schema in the Stripe OpenAPI spec.
+func (s *Source) attachSchemas(ctx context.Context, record opencdc.Record) error {
+	resourceName := record.Metadata[models.MetadataResource]
+
+	sch, ok := s.schemas[resourceName]
+	if !ok {
+		var err error
+
+		sch, err = createResourceSchema(ctx, resourceName)
+		if err != nil {
+			return err
+		}
+
+		if s.schemas == nil {
+			s.schemas = make(map[string]*resourceSchema)
+		}
+
+		s.schemas[resourceName] = sch
+	}
+
+	for _, payload := range []opencdc.Data{record.Payload.Before, record.Payload.After} {
+		if data, ok := payload.(opencdc.StructuredData); ok {
+			openapi.Normalize(map[string]interface{}(data), sch.payloadAvro)
+		}
+	}
+
+	schema.AttachKeySchemaToRecord(record, sch.key)
+	schema.AttachPayloadSchemaToRecord(record, sch.payload)
+
+	return nil
+}
+
+// createResourceSchema derives the key and payload schemas of the resource from the Stripe OpenAPI spec,
+// and registers them in the schema registry.
+func createResourceSchema(ctx context.Context, resourceName string) (*resourceSchema, error) {
+	payloadAvro, err := openapi.AvroSchema(resourceName)
+	if err != nil {
+		return nil, fmt.Errorf("derive payload schema: %w", err)
+	}
+
+	// the keys of the records of the child resources and the nested lists contain the identifiers of their parents
+	_, child := models.ChildResourcesMap[resourceName]
+	if _, ok := models.NestedListResources[resourceName]; ok {
+		child = true
+	}
+
+	keyAvro, err := openapi.KeyAvroSchema(resourceName, child)
+	if err != nil {
+		return nil, fmt.Errorf("derive key schema: %w", err)
+	}
+
+	key, err := createSchema(ctx, fmt.Sprintf(keySubjectFmt, resourceName), keyAvro)
+	if err != nil {
+		return nil, fmt.Errorf("create key schema: %w", err)
+	}
+
+	payload, err := createSchema(ctx, fmt.Sprintf(payloadSubjectFmt, resourceName), payloadAvro)
+	if err != nil {
+		return nil, fmt.Errorf("create payload schema: %w", err)
+	}
+
+	return &resourceSchema{
+		key:         key,
+		payload:     payload,
+		payloadAvro: payloadAvro,
+	}, nil
+}
+
+// createSchema registers the Avro schema under the subject in the schema registry.
+func createSchema(ctx context.Context, subject string, sch avro.Schema) (schema.Schema, error) {
+	// the canonical form of the schema omits the defaults of the fields, so it is marshaled to JSON instead
+	data, err := json.Marshal(sch)
+	if err != nil {
+		return schema.Schema{}, fmt.Errorf("marshal avro schema: %w", err)
+	}
+
+	created, err := schema.Create(ctx, schema.TypeAvro, subject, data)
+	if err != nil {
+		return schema.Schema{}, fmt.Errorf("create schema %s: %w", subject, err)
+	}
+
+	return created, nil
+}
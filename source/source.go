@@ -16,10 +16,13 @@ package source
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/conduitio-labs/conduit-connector-stripe/clients/http"
 	"github.com/conduitio-labs/conduit-connector-stripe/config"
+	"github.com/conduitio-labs/conduit-connector-stripe/models"
+	"github.com/conduitio-labs/conduit-connector-stripe/models/openapi"
 	"github.com/conduitio-labs/conduit-connector-stripe/source/iterator"
 	"github.com/conduitio-labs/conduit-connector-stripe/stripe"
 	commonsConfig "github.com/conduitio/conduit-commons/config"
@@ -29,9 +32,14 @@ import (
 
 //go:generate mockgen -package mock -source source.go -destination ./mock/source.go
 
+// errNoConnectedAccounts occurs when the platform has no connected accounts to read.
+var errNoConnectedAccounts = errors.New("the platform has no connected accounts")
+
 // An Iterator defines the interface to iterator methods.
 type Iterator interface {
-	Next() (opencdc.Record, error)
+	Next(ctx context.Context) (opencdc.Record, error)
+	Ack(ctx context.Context, position opencdc.Position) error
+	Stop(ctx context.Context) error
 }
 
 // A Source represents the source connector.
@@ -40,6 +48,12 @@ type Source struct {
 	cfg      config.Config
 	iterator Iterator
 	httpCli  http.Client
+
+	// schemas are the schemas of the resources, which are created when the first record of the resource is read.
+	schemas map[string]*resourceSchema
+
+	// inFlight are the positions of the records, which are read but not acknowledged yet.
+	inFlight *inFlight
 }
 
 // NewSource initialises a new source.
@@ -69,40 +83,187 @@ func (s *Source) Configure(ctx context.Context, cfgRaw commonsConfig.Config) err
 
 // Open parses opencdc.Position and initializes a SnapshotIterator iterator.
 func (s *Source) Open(ctx context.Context, position opencdc.Position) error {
+	s.inFlight = &inFlight{committed: position}
+
 	pos, err := iterator.ParseSDKPosition(position)
 	if err != nil {
 		return err
 	}
 
-	s.httpCli = http.NewClient(ctx)
+	s.httpCli = http.NewClient(ctx, s.cfg.RequestsPerSecond())
+
+	stripeSvc := stripe.New(s.cfg, s.httpCli)
+
+	if s.cfg.APIVersion != "" {
+		if err = stripeSvc.ValidateAPIVersion(); err != nil {
+			return fmt.Errorf("validate api version: %w", err)
+		}
+	}
+
+	if len(s.cfg.ConnectedAccounts) > 0 {
+		s.iterator, err = s.newConnectedAccountsIterator(stripeSvc, pos)
+
+		return err
+	}
+
+	opts, err := s.iteratorOptions()
+	if err != nil {
+		return err
+	}
+
+	iter := iterator.New(stripeSvc, pos, opts)
 
-	s.iterator = iterator.New(stripe.New(s.cfg, s.httpCli), pos, s.cfg.Snapshot)
+	if s.cfg.CDCMode == config.CDCModeWebhook {
+		err = iter.ListenWebhook(ctx, s.cfg.WebhookAddress, s.cfg.WebhookSecret, s.cfg.WebhookTolerance)
+		if err != nil {
+			return err
+		}
+	}
+
+	s.iterator = iter
 
 	return nil
 }
 
 // Read returns the next opencdc.Record.
-func (s *Source) Read(_ context.Context) (opencdc.Record, error) {
-	record, err := s.iterator.Next()
+func (s *Source) Read(ctx context.Context) (opencdc.Record, error) {
+	record, err := s.iterator.Next(ctx)
 	if err != nil {
 		return opencdc.Record{}, err
 	}
 
+	// the iterator may set the API version the payload was rendered with, such as the version of an event
+	if _, ok := record.Metadata[models.MetadataAPIVersion]; !ok && s.cfg.APIVersion != "" {
+		record.Metadata[models.MetadataAPIVersion] = s.cfg.APIVersion
+	}
+
+	if s.cfg.NestedObjects == config.NestedObjectsJSON {
+		for _, payload := range []opencdc.Data{record.Payload.Before, record.Payload.After} {
+			if data, ok := payload.(opencdc.StructuredData); ok {
+				openapi.MarshalNestedObjects(data)
+			}
+		}
+	}
+
+	if s.cfg.ResourceSchema {
+		if err = s.attachSchemas(ctx, record); err != nil {
+			return opencdc.Record{}, fmt.Errorf("attach schemas: %w", err)
+		}
+	}
+
+	s.inFlight.Add(record.Position)
+
 	return record, nil
 }
 
-// Ack does nothing.
+// Ack acknowledges the position of the record, and passes the positions it commits to the iterator,
+// which stores the local state of the committed records, if any.
+// The positions which were not read by this source, such as the ones read before it was reopened,
+// are logged, because Conduit resumes from the last acknowledged position anyway.
 func (s *Source) Ack(ctx context.Context, position opencdc.Position) error {
-	sdk.Logger(ctx).Debug().Str("position", string(position)).Msg("got ack")
+	committed, err := s.inFlight.Ack(position)
+	if err != nil {
+		sdk.Logger(ctx).Warn().
+			Err(err).
+			Str("position", string(position)).
+			Msg("got ack of a position which was not read")
+
+		return nil
+	}
+
+	for _, pos := range committed {
+		if err = s.iterator.Ack(ctx, pos); err != nil {
+			return fmt.Errorf("ack iterator: %w", err)
+		}
+	}
+
+	sdk.Logger(ctx).Debug().
+		Str("position", string(position)).
+		Str("committed", string(s.inFlight.Committed())).
+		Msg("got ack")
 
 	return nil
 }
 
-// Teardown closes any connections which were previously connected from previous requests.
+// Teardown stops the iterator and closes any connections which were previously connected from previous requests.
 func (s *Source) Teardown(ctx context.Context) error {
 	sdk.Logger(ctx).Info().Msg("tearing down a stripe source")
 
+	if s.inFlight != nil && s.inFlight.Len() > 0 {
+		sdk.Logger(ctx).Info().
+			Int("records", s.inFlight.Len()).
+			Str("committed", string(s.inFlight.Committed())).
+			Msg("records were not acknowledged, they are read again after a restart")
+	}
+
+	if s.iterator != nil {
+		if err := s.iterator.Stop(ctx); err != nil {
+			return fmt.Errorf("stop iterator: %w", err)
+		}
+	}
+
 	s.httpCli.Close()
 
 	return nil
 }
+
+// newConnectedAccountsIterator initializes an iterator of the configured connected accounts,
+// where all connected accounts of the platform are requested from Stripe if needed.
+func (s *Source) newConnectedAccountsIterator(stripeSvc stripe.Stripe, pos *iterator.Position) (Iterator, error) {
+	accounts := s.cfg.ConnectedAccounts
+
+	if accounts[0] == config.AllConnectedAccounts {
+		var err error
+
+		accounts, err = stripeSvc.GetAccounts()
+		if err != nil {
+			return nil, fmt.Errorf("get connected accounts: %w", err)
+		}
+
+		if len(accounts) == 0 {
+			return nil, errNoConnectedAccounts
+		}
+	}
+
+	stripeSvcs := make(map[string]iterator.Stripe, len(accounts))
+	for _, account := range accounts {
+		stripeSvcs[account] = stripeSvc.WithAccount(account)
+	}
+
+	opts, err := s.iteratorOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	return iterator.NewConnectedAccounts(stripeSvcs, pos, opts), nil
+}
+
+// iteratorOptions returns the options of the iterators from the configuration.
+func (s *Source) iteratorOptions() (iterator.Options, error) {
+	after, before, err := s.cfg.SnapshotCreatedRange()
+	if err != nil {
+		return iterator.Options{}, fmt.Errorf("snapshot created range: %w", err)
+	}
+
+	return iterator.Options{
+		ResourceNames:          s.cfg.Resources(),
+		Snapshot:               s.cfg.Snapshot,
+		SnapshotCreatedAfter:   after,
+		SnapshotCreatedBefore:  before,
+		ListFilters:            s.cfg.ListFilters,
+		SearchQuery:            s.cfg.SearchQuery,
+		EventTypes:             s.cfg.EventTypes,
+		PollOverlap:            s.cfg.PollOverlap,
+		PollLookback:           s.cfg.PollLookback,
+		PollStrategy:           s.cfg.CDCStrategy == config.CDCStrategyPoll,
+		PollStateFile:          s.cfg.PollStateFile,
+		ReconcileInterval:      s.cfg.ReconcileInterval,
+		ReconcileStateFile:     s.cfg.ReconcileStateFile,
+		SnapshotWorkers:        s.cfg.SnapshotWorkers,
+		SnapshotOnly:           s.cfg.CDCMode == config.CDCModeNone,
+		InlineNestedLists:      s.cfg.NestedLists == config.NestedListsInline,
+		NestedListRecords:      s.cfg.NestedLists == config.NestedListsRecords,
+		StructuredPayload:      s.cfg.StructuredPayload,
+		SnapshotOnRetentionGap: s.cfg.OnRetentionGap == config.OnRetentionGapSnapshot,
+	}, nil
+}
@@ -16,10 +16,17 @@ package source
 
 import (
 	"context"
+	"encoding/json"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/conduitio-labs/conduit-connector-stripe/config"
+	"github.com/conduitio-labs/conduit-connector-stripe/models"
+	"github.com/conduitio-labs/conduit-connector-stripe/models/resources"
+	"github.com/conduitio-labs/conduit-connector-stripe/source/mock"
+	"github.com/conduitio/conduit-commons/opencdc"
+	"go.uber.org/mock/gomock"
 )
 
 func TestSource_Configure(t *testing.T) {
@@ -40,10 +47,20 @@ func TestSource_Configure(t *testing.T) {
 			},
 			want: Source{
 				cfg: config.Config{
-					SecretKey:    "sk_51JB",
-					ResourceName: "subscription",
-					Snapshot:     true,
-					BatchSize:    10,
+					SecretKey:        "sk_51JB",
+					ResourceName:     "subscription",
+					Snapshot:         true,
+					SnapshotWorkers:  1,
+					BatchSize:        10,
+					CDCMode:          config.CDCModePoll,
+					CDCStrategy:      config.CDCStrategyEvents,
+					PollOverlap:      10 * time.Minute,
+					WebhookAddress:   ":8080",
+					WebhookTolerance: 5 * time.Minute,
+					BaseURL:          models.BaseURL,
+					OnRetentionGap:   config.OnRetentionGapFail,
+					NestedLists:      config.NestedListsNone,
+					NestedObjects:    config.NestedObjectsStructured,
 				},
 			},
 		},
@@ -85,3 +102,106 @@ func TestSource_Configure(t *testing.T) {
 		})
 	}
 }
+
+func TestSource_ReadAPIVersion(t *testing.T) {
+	const apiVersion = "2022-11-15"
+
+	tests := []struct {
+		name     string
+		metadata opencdc.Metadata
+		want     string
+	}{
+		{
+			name:     "configured api version",
+			metadata: opencdc.Metadata{},
+			want:     apiVersion,
+		},
+		{
+			name:     "api version of the iterator",
+			metadata: opencdc.Metadata{models.MetadataAPIVersion: "2020-08-27"},
+			want:     "2020-08-27",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			it := mock.NewMockIterator(ctrl)
+			it.EXPECT().Next(gomock.Any()).Return(opencdc.Record{Metadata: tt.metadata}, nil)
+
+			source := &Source{
+				cfg:      config.Config{APIVersion: apiVersion},
+				iterator: it,
+				inFlight: &inFlight{},
+			}
+
+			record, err := source.Read(context.Background())
+			if err != nil {
+				t.Errorf("read error = \"%s\"", err.Error())
+			}
+
+			if record.Metadata[models.MetadataAPIVersion] != tt.want {
+				t.Errorf("api version: got = %v, want %v", record.Metadata[models.MetadataAPIVersion], tt.want)
+			}
+		})
+	}
+}
+
+func TestSource_ReadResourceSchema(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	it := mock.NewMockIterator(ctrl)
+	it.EXPECT().Next(gomock.Any()).Return(opencdc.Record{
+		Metadata: opencdc.Metadata{models.MetadataResource: resources.CustomerResource},
+		Key:      opencdc.StructuredData{models.KeyID: "cus_LY6gsj"},
+		Payload: opencdc.Change{After: opencdc.StructuredData{
+			models.KeyID: "cus_LY6gsj",
+			"balance":    json.Number("1099"),
+			"address":    map[string]interface{}{"city": "Berlin"},
+		}},
+	}, nil)
+
+	source := &Source{
+		cfg: config.Config{
+			StructuredPayload: true,
+			NestedObjects:     config.NestedObjectsJSON,
+			ResourceSchema:    true,
+		},
+		iterator: it,
+		inFlight: &inFlight{},
+	}
+
+	record, err := source.Read(context.Background())
+	if err != nil {
+		t.Fatalf("read error = \"%s\"", err.Error())
+	}
+
+	for _, want := range []struct {
+		get     func() (string, error)
+		subject string
+	}{
+		{get: record.Metadata.GetKeySchemaSubject, subject: "customer.key"},
+		{get: record.Metadata.GetPayloadSchemaSubject, subject: "customer.payload"},
+	} {
+		subject, err := want.get()
+		if err != nil {
+			t.Errorf("get schema subject error = \"%s\"", err.Error())
+		}
+
+		if subject != want.subject {
+			t.Errorf("schema subject: got = %v, want %v", subject, want.subject)
+		}
+	}
+
+	after := record.Payload.After.(opencdc.StructuredData)
+
+	if after["balance"] != int64(1099) {
+		t.Errorf("balance: got = %#v, want %#v", after["balance"], int64(1099))
+	}
+
+	// the nested objects are JSON strings, the same as in the payload schema
+	if after["address"] != `{"city":"Berlin"}` {
+		t.Errorf("address: got = %#v, want %#v", after["address"], `{"city":"Berlin"}`)
+	}
+}
@@ -27,8 +27,8 @@ var version = "v0.0.0-dev"
 func Specification() sdk.Specification {
 	return sdk.Specification{
 		Name:        "stripe",
-		Summary:     "A Stripe source plugin for Conduit, written in Go.",
-		Description: "The Stripe connector is one of Conduit plugins. It provides a source Stripe connector.",
+		Summary:     "A Stripe source and destination plugin for Conduit, written in Go.",
+		Description: "The Stripe connector is one of Conduit plugins. It provides both, a source and a destination Stripe connector.",
 		Version:     version,
 		Author:      "Meroxa, Inc.",
 	}
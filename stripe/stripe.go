@@ -15,14 +15,19 @@
 package stripe
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	nethttp "net/http"
 	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/conduitio-labs/conduit-connector-stripe/clients/http"
 	"github.com/conduitio-labs/conduit-connector-stripe/config"
 	"github.com/conduitio-labs/conduit-connector-stripe/models"
+	"github.com/conduitio-labs/conduit-connector-stripe/models/resources"
 )
 
 const (
@@ -31,33 +36,176 @@ const (
 	startingAfterKey = "starting_after"
 	endingBeforeKey  = "ending_before"
 	typesKey         = "types[]"
+	expandKey        = "expand[]"
+	// expandListPrefix is the prefix of the expansion paths of the objects in a list response.
+	expandListPrefix = "data."
 	createdKey       = "created[gt]"
+	queryKey         = "query"
+	pageKey          = "page"
+	pathSearch       = "/search"
+	createdGTEKey    = "created[gte]"
+	createdLTKey     = "created[lt]"
+	formNestedKeyFmt = "%s[%s]"
+
+	// eventTypePatternChars are the special characters of the patterns of the event types.
+	eventTypePatternChars = `*?[\`
+
+	// maxEventTypes is the maximum number of event types Stripe accepts in the `types[]` parameter.
+	maxEventTypes = 20
 )
 
+// ErrEventMissing occurs when the event the events are listed relative to no longer exists in Stripe.
+var ErrEventMissing = errors.New("event is missing")
+
 // A Stripe represents Stripe client struct.
 type Stripe struct {
 	cfg     config.Config
 	httpCli http.Client
+
+	// apiURL is the URL of the Stripe API, including the API path.
+	apiURL string
+
+	// account is the identifier of the connected account the client makes requests on behalf of.
+	account string
 }
 
-// New initialises a new Stripe client.
+// New initialises a new Stripe client, which makes requests to the base URL of the configuration,
+// or to the Stripe API if it is empty.
 func New(cfg config.Config, httpCli http.Client) Stripe {
+	baseURL := models.BaseURL
+	if cfg.BaseURL != "" {
+		baseURL = strings.TrimSuffix(cfg.BaseURL, "/")
+	}
+
 	return Stripe{
 		cfg:     cfg,
 		httpCli: httpCli,
+		apiURL:  baseURL + models.APIPath,
+	}
+}
+
+// WithAccount returns a copy of the client, which makes requests on behalf of the connected account.
+func (s Stripe) WithAccount(account string) Stripe {
+	s.account = account
+
+	return s
+}
+
+// GetAccounts returns the identifiers of all connected accounts of the platform.
+func (s Stripe) GetAccounts() ([]string, error) {
+	var (
+		accounts      []string
+		startingAfter string
+	)
+
+	for {
+		resp, err := s.GetResource(resources.AccountResource, startingAfter)
+		if err != nil {
+			return nil, fmt.Errorf("get list of accounts: %w", err)
+		}
+
+		for i := range resp.Data {
+			if id, ok := resp.Data[i][models.KeyID].(string); ok {
+				accounts = append(accounts, id)
+			}
+		}
+
+		if !resp.HasMore || len(resp.Data) == 0 {
+			return accounts, nil
+		}
+
+		startingAfter = accounts[len(accounts)-1]
 	}
 }
 
-// GetResource returns a list of resource objects.
-func (s Stripe) GetResource(startingAfter string) (models.ResourceResponse, error) {
+// ValidateAPIVersion makes a minimal request to Stripe, which fails if Stripe does not support the API version.
+func (s Stripe) ValidateAPIVersion() error {
+	reqURL, err := url.Parse(s.apiURL)
+	if err != nil {
+		return fmt.Errorf("parse api url: %w", err)
+	}
+
+	reqURL.Path += pathEvents
+
+	values := reqURL.Query()
+	values.Add(batchSize, "1")
+
+	reqURL.RawQuery = values.Encode()
+
+	_, err = s.httpCli.Get(reqURL.String(), s.header())
+	if err != nil {
+		return fmt.Errorf("get data from stripe with api version %s: %w", s.cfg.APIVersion, err)
+	}
+
+	return nil
+}
+
+// GetResource returns a list of objects of the resource.
+func (s Stripe) GetResource(resourceName, startingAfter string) (models.ResourceResponse, error) {
+	return s.ListResource(resourceName, models.ListParams{StartingAfter: startingAfter})
+}
+
+// ListResource returns a list of objects of the resource, which are filtered by the parameters.
+func (s Stripe) ListResource(resourceName string, params models.ListParams) (models.ResourceResponse, error) {
 	var resp models.ResourceResponse
 
-	reqURL, err := url.Parse(models.APIURL)
+	reqURL, err := url.Parse(s.apiURL)
 	if err != nil {
 		return resp, fmt.Errorf("parse api url: %w", err)
 	}
 
-	reqURL.Path += fmt.Sprintf(models.PathFmt, models.ResourcesMap[s.cfg.ResourceName])
+	reqURL.Path += fmt.Sprintf(models.PathFmt, listPath(resourceName, params.ParentID))
+
+	values := reqURL.Query()
+	values.Add(batchSize, strconv.Itoa(s.cfg.BatchSize))
+
+	if !params.WithoutExpand {
+		for _, path := range s.cfg.ExpandPaths(resourceName) {
+			values.Add(expandKey, expandListPrefix+path)
+		}
+	}
+
+	if params.StartingAfter != "" {
+		values.Add(startingAfterKey, params.StartingAfter)
+	}
+
+	if params.CreatedGTE != 0 {
+		values.Add(createdGTEKey, strconv.FormatInt(params.CreatedGTE, 10))
+	}
+
+	if params.CreatedLT != 0 {
+		values.Add(createdLTKey, strconv.FormatInt(params.CreatedLT, 10))
+	}
+
+	for k, v := range params.Filters {
+		values.Add(k, v)
+	}
+
+	reqURL.RawQuery = values.Encode()
+
+	data, err := s.httpCli.Get(reqURL.String(), s.header())
+	if err != nil {
+		return resp, fmt.Errorf("get data from stripe, by url %s and header: %w", reqURL.String(), err)
+	}
+
+	err = decode(data, &resp)
+	if err != nil {
+		return resp, fmt.Errorf("unmarshal response data: %w", err)
+	}
+
+	return resp, nil
+}
+
+// ListNested returns a list of the objects of the list nested in an object by the path of the list
+// relative to the API path, such as `/invoices/in_1MtHbELkdIwHu7ixl4OzzPMv/lines`.
+func (s Stripe) ListNested(path, startingAfter string) (models.ResourceResponse, error) {
+	var resp models.ResourceResponse
+
+	// the path may contain the parameters of the list, such as the subscription of the subscription items
+	reqURL, err := url.Parse(s.apiURL + path)
+	if err != nil {
+		return resp, fmt.Errorf("parse api url: %w", err)
+	}
 
 	values := reqURL.Query()
 	values.Add(batchSize, strconv.Itoa(s.cfg.BatchSize))
@@ -68,15 +216,51 @@ func (s Stripe) GetResource(startingAfter string) (models.ResourceResponse, erro
 
 	reqURL.RawQuery = values.Encode()
 
-	header := make(map[string]string, 1)
-	header[models.HeaderAuthKey] = fmt.Sprintf(models.HeaderAuthValueFormat, s.cfg.SecretKey)
+	data, err := s.httpCli.Get(reqURL.String(), s.header())
+	if err != nil {
+		return resp, fmt.Errorf("get data from stripe, by url %s and header: %w", reqURL.String(), err)
+	}
 
-	data, err := s.httpCli.Get(reqURL.String(), header)
+	err = decode(data, &resp)
+	if err != nil {
+		return resp, fmt.Errorf("unmarshal response data: %w", err)
+	}
+
+	return resp, nil
+}
+
+// SearchResource returns a page of the objects of the resource, which match the query of the Stripe Search API,
+// where the page is the token of the page, or empty for the first page.
+func (s Stripe) SearchResource(resourceName, query, page string) (models.ResourceResponse, error) {
+	var resp models.ResourceResponse
+
+	reqURL, err := url.Parse(s.apiURL)
+	if err != nil {
+		return resp, fmt.Errorf("parse api url: %w", err)
+	}
+
+	reqURL.Path += fmt.Sprintf(models.PathFmt, models.ResourcesMap[resourceName]) + pathSearch
+
+	values := reqURL.Query()
+	values.Add(queryKey, query)
+	values.Add(batchSize, strconv.Itoa(s.cfg.BatchSize))
+
+	for _, path := range s.cfg.ExpandPaths(resourceName) {
+		values.Add(expandKey, expandListPrefix+path)
+	}
+
+	if page != "" {
+		values.Add(pageKey, page)
+	}
+
+	reqURL.RawQuery = values.Encode()
+
+	data, err := s.httpCli.Get(reqURL.String(), s.header())
 	if err != nil {
 		return resp, fmt.Errorf("get data from stripe, by url %s and header: %w", reqURL.String(), err)
 	}
 
-	err = json.Unmarshal(data, &resp)
+	err = decode(data, &resp)
 	if err != nil {
 		return resp, fmt.Errorf("unmarshal response data: %w", err)
 	}
@@ -84,11 +268,54 @@ func (s Stripe) GetResource(startingAfter string) (models.ResourceResponse, erro
 	return resp, nil
 }
 
-// GetEvent returns a list of event objects.
+// ExpandObject retrieves the object of the resource with the expansions configured for the resource,
+// because the objects of the events contain only identifiers of the related objects,
+// and reports whether the object is retrieved, in which case it is rendered with the API version of the client.
+// The object is returned as is if there are no expansions.
+func (s Stripe) ExpandObject(
+	resourceName string, object map[string]interface{},
+) (map[string]interface{}, bool, error) {
+	paths := s.cfg.ExpandPaths(resourceName)
+
+	id, ok := object[models.KeyID].(string)
+	if len(paths) == 0 || !ok {
+		return object, false, nil
+	}
+
+	reqURL, err := s.resourceURL(resourceName, models.ParentID(resourceName, object), id)
+	if err != nil {
+		return nil, false, err
+	}
+
+	values := url.Values{}
+	for _, path := range paths {
+		values.Add(expandKey, path)
+	}
+
+	reqURL += "?" + values.Encode()
+
+	data, err := s.httpCli.Get(reqURL, s.header())
+	if err != nil {
+		return nil, false, fmt.Errorf("get data from stripe, by url %s and header: %w", reqURL, err)
+	}
+
+	var resp map[string]interface{}
+
+	err = decode(data, &resp)
+	if err != nil {
+		return nil, false, fmt.Errorf("unmarshal response data: %w", err)
+	}
+
+	return resp, true, nil
+}
+
+// GetEvent returns a list of event objects of all configured resources.
+// If the resources have more event types than Stripe accepts in one request,
+// the types are not filtered by Stripe, and the caller has to skip unrelated events.
 func (s Stripe) GetEvent(createdAt int64, startingAfter, endingBefore string) (models.EventResponse, error) {
 	var resp models.EventResponse
 
-	reqURL, err := url.Parse(models.APIURL)
+	reqURL, err := url.Parse(s.apiURL)
 	if err != nil {
 		return resp, fmt.Errorf("parse api url: %w", err)
 	}
@@ -99,9 +326,9 @@ func (s Stripe) GetEvent(createdAt int64, startingAfter, endingBefore string) (m
 	values.Add(createdKey, strconv.FormatInt(createdAt, 10))
 	values.Add(batchSize, strconv.Itoa(s.cfg.BatchSize))
 
-	if events, ok := models.EventsMap[s.cfg.ResourceName]; ok {
-		for i := range events {
-			values.Add(typesKey, events[i])
+	if types := s.eventTypes(); len(types) <= maxEventTypes {
+		for i := range types {
+			values.Add(typesKey, types[i])
 		}
 	}
 
@@ -115,18 +342,218 @@ func (s Stripe) GetEvent(createdAt int64, startingAfter, endingBefore string) (m
 
 	reqURL.RawQuery = values.Encode()
 
-	header := make(map[string]string, 1)
-	header[models.HeaderAuthKey] = fmt.Sprintf(models.HeaderAuthValueFormat, s.cfg.SecretKey)
-
-	data, err := s.httpCli.Get(reqURL.String(), header)
+	data, err := s.httpCli.Get(reqURL.String(), s.header())
 	if err != nil {
+		if endingBefore != "" && isResourceMissing(err) {
+			return resp, fmt.Errorf("list events before %s: %w", endingBefore, ErrEventMissing)
+		}
+
 		return resp, fmt.Errorf("get data from stripe, by url %s and header: %w", reqURL.String(), err)
 	}
 
-	err = json.Unmarshal(data, &resp)
+	err = decode(data, &resp)
 	if err != nil {
 		return resp, fmt.Errorf("unmarshal response data: %w", err)
 	}
 
 	return resp, nil
 }
+
+// EventExists returns whether the event still exists in Stripe, which retains events only for 30 days.
+func (s Stripe) EventExists(id string) (bool, error) {
+	reqURL, err := url.Parse(s.apiURL)
+	if err != nil {
+		return false, fmt.Errorf("parse api url: %w", err)
+	}
+
+	reqURL.Path += pathEvents + fmt.Sprintf(models.PathFmt, id)
+
+	_, err = s.httpCli.Get(reqURL.String(), s.header())
+	if err != nil {
+		if isResourceMissing(err) {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("get data from stripe, by url %s and header: %w", reqURL.String(), err)
+	}
+
+	return true, nil
+}
+
+// isResourceMissing returns whether the error is Stripe's error of a missing object.
+func isResourceMissing(err error) bool {
+	var respErr *http.ResponseError
+	if !errors.As(err, &respErr) {
+		return false
+	}
+
+	return respErr.StatusCode == nethttp.StatusNotFound || respErr.Code == models.ErrorCodeResourceMissing
+}
+
+// eventTypes returns the union of event types of all configured resources,
+// where the event types of the event resource are the configured ones,
+// or none if some of them are patterns, so all events are listed and matched by the iterator.
+func (s Stripe) eventTypes() []string {
+	var types []string
+
+	for _, resourceName := range s.cfg.Resources() {
+		if resourceName != resources.EventResource {
+			types = append(types, models.EventsMap[resourceName]...)
+
+			continue
+		}
+
+		for _, eventType := range s.cfg.EventTypes {
+			if strings.ContainsAny(eventType, eventTypePatternChars) {
+				return nil
+			}
+		}
+
+		types = append(types, s.cfg.EventTypes...)
+	}
+
+	return types
+}
+
+// CreateResource creates a new resource object with the parameters and returns it,
+// where the idempotency key, if any, makes Stripe create the object only once, when the request is retried.
+func (s Stripe) CreateResource(idempotencyKey string, params map[string]interface{}) (map[string]interface{}, error) {
+	reqURL, err := s.resourceURL(s.cfg.ResourceName, "", "")
+	if err != nil {
+		return nil, err
+	}
+
+	return s.post(reqURL, idempotencyKey, params)
+}
+
+// UpdateResource updates the resource object by its identifier with the parameters and returns it,
+// where the idempotency key, if any, makes Stripe update the object only once, when the request is retried.
+func (s Stripe) UpdateResource(
+	id, idempotencyKey string, params map[string]interface{},
+) (map[string]interface{}, error) {
+	reqURL, err := s.resourceURL(s.cfg.ResourceName, "", id)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.post(reqURL, idempotencyKey, params)
+}
+
+// DeleteResource deletes the resource object by its identifier.
+func (s Stripe) DeleteResource(id string) error {
+	reqURL, err := s.resourceURL(s.cfg.ResourceName, "", id)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.httpCli.Delete(reqURL, s.header())
+	if err != nil {
+		return fmt.Errorf("delete data from stripe, by url %s and header: %w", reqURL, err)
+	}
+
+	return nil
+}
+
+// resourceURL returns the URL of the resource endpoint, or of the resource object if the id is not empty,
+// where the parent is the identifier of the parent object of a child resource.
+func (s Stripe) resourceURL(resourceName, parentID, id string) (string, error) {
+	reqURL, err := url.Parse(s.apiURL)
+	if err != nil {
+		return "", fmt.Errorf("parse api url: %w", err)
+	}
+
+	reqURL.Path += fmt.Sprintf(models.PathFmt, listPath(resourceName, parentID))
+
+	if id != "" {
+		reqURL.Path += fmt.Sprintf(models.PathFmt, id)
+	}
+
+	return reqURL.String(), nil
+}
+
+// listPath returns the path of the list of the resource relative to the API path,
+// where the parent is the identifier of the parent object of a child resource.
+func listPath(resourceName, parentID string) string {
+	if _, ok := models.ChildResourcesMap[resourceName]; ok {
+		return fmt.Sprintf(models.ResourcesMap[resourceName], parentID)
+	}
+
+	return models.ResourcesMap[resourceName]
+}
+
+// header returns the header with the authorization of the client, and the connected account if any.
+func (s Stripe) header() map[string]string {
+	header := make(map[string]string, 3)
+	header[models.HeaderAuthKey] = fmt.Sprintf(models.HeaderAuthValueFormat, s.cfg.SecretKey)
+
+	if s.account != "" {
+		header[models.HeaderAccountKey] = s.account
+	}
+
+	if s.cfg.APIVersion != "" {
+		header[models.HeaderVersionKey] = s.cfg.APIVersion
+	}
+
+	return header
+}
+
+// post makes a POST request with the form-encoded parameters to the URL and returns the resulting object,
+// the request has the idempotency key, if any, so its retries are not applied twice.
+func (s Stripe) post(reqURL, idempotencyKey string, params map[string]interface{}) (map[string]interface{}, error) {
+	values := url.Values{}
+	for k, v := range params {
+		encodeFormValue(values, k, v)
+	}
+
+	header := s.header()
+	if idempotencyKey != "" {
+		header[models.HeaderIdempotencyKey] = idempotencyKey
+	}
+
+	data, err := s.httpCli.Post(reqURL, values, header)
+	if err != nil {
+		return nil, fmt.Errorf("post data to stripe, by url %s and header: %w", reqURL, err)
+	}
+
+	var resp map[string]interface{}
+
+	err = decode(data, &resp)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal response data: %w", err)
+	}
+
+	return resp, nil
+}
+
+// encodeFormValue adds the value to the values using Stripe's form encoding,
+// where nested objects are encoded as `key[field]` and arrays as `key[index]`.
+func encodeFormValue(values url.Values, key string, value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k := range v {
+			encodeFormValue(values, fmt.Sprintf(formNestedKeyFmt, key, k), v[k])
+		}
+	case []interface{}:
+		for i := range v {
+			encodeFormValue(values, fmt.Sprintf(formNestedKeyFmt, key, strconv.Itoa(i)), v[i])
+		}
+	case nil:
+		// an empty value unsets the field in Stripe
+		values.Add(key, "")
+	case string:
+		values.Add(key, v)
+	case float64:
+		values.Add(key, strconv.FormatFloat(v, 'f', -1, 64))
+	default:
+		values.Add(key, fmt.Sprint(v))
+	}
+}
+
+// decode unmarshals the Stripe response data into the value,
+// where the numbers are decoded as json.Number to keep them exactly as Stripe sent them.
+func decode(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	return dec.Decode(v)
+}
@@ -0,0 +1,406 @@
+// Copyright © 2022 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stripe
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	nethttp "net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/conduitio-labs/conduit-connector-stripe/clients/http"
+	"github.com/conduitio-labs/conduit-connector-stripe/config"
+	"github.com/conduitio-labs/conduit-connector-stripe/models"
+	"github.com/conduitio-labs/conduit-connector-stripe/models/resources"
+	"github.com/matryer/is"
+)
+
+const (
+	testSecretKey  = "sk_test_123456789"
+	testAPIVersion = "2022-11-15"
+	testAccount    = "acct_1032D82eZvKYlo2C"
+)
+
+func TestStripe_GetResource(t *testing.T) {
+	is := is.New(t)
+
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		is.Equal(r.Method, nethttp.MethodGet)
+		is.Equal(r.URL.Path, "/v1/customers")
+		is.Equal(r.URL.Query().Get(batchSize), "10")
+		is.Equal(r.URL.Query().Get(startingAfterKey), "cus_LY6gsj")
+		is.Equal(r.Header.Get(models.HeaderAuthKey), "Bearer "+testSecretKey)
+		is.Equal(r.Header.Get(models.HeaderVersionKey), testAPIVersion)
+		is.Equal(r.Header.Get(models.HeaderAccountKey), testAccount)
+
+		_, _ = w.Write([]byte(`{"data":[{"id":"cus_LY6gsk","balance":9007199254740993}],"has_more":true}`))
+	}))
+	defer server.Close()
+
+	httpCli := http.NewClient(context.Background(), models.TestModeRequestsPerSecond)
+	defer httpCli.Close()
+
+	stripeSvc := New(config.Config{
+		SecretKey:  testSecretKey,
+		BatchSize:  10,
+		APIVersion: testAPIVersion,
+		BaseURL:    server.URL + "/",
+	}, httpCli).WithAccount(testAccount)
+
+	resp, err := stripeSvc.GetResource(resources.CustomerResource, "cus_LY6gsj")
+	is.NoErr(err)
+	is.True(resp.HasMore)
+	is.Equal(resp.Data, []map[string]interface{}{{
+		models.KeyID: "cus_LY6gsk",
+		"balance":    json.Number("9007199254740993"),
+	}})
+}
+
+func TestStripe_UpdateResource(t *testing.T) {
+	is := is.New(t)
+
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		is.Equal(r.Method, nethttp.MethodPost)
+		is.Equal(r.URL.Path, "/v1/customers/cus_LY6gsj")
+		is.Equal(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded")
+		is.Equal(r.Header.Get("Idempotency-Key"), "3f2a9c")
+		is.NoErr(r.ParseForm())
+		is.Equal(r.PostForm, url.Values{
+			"name":                     {"Jenny Rosen"},
+			"balance":                  {"1099"},
+			"metadata[tenant]":         {"acme"},
+			"preferred_locales[0]":     {"en"},
+			"preferred_locales[1]":     {"de"},
+			"invoice_settings[footer]": {""},
+		})
+
+		_, _ = w.Write([]byte(`{"id":"cus_LY6gsj"}`))
+	}))
+	defer server.Close()
+
+	httpCli := http.NewClient(context.Background(), models.TestModeRequestsPerSecond)
+	defer httpCli.Close()
+
+	stripeSvc := New(config.Config{
+		SecretKey:    testSecretKey,
+		ResourceName: resources.CustomerResource,
+		BaseURL:      server.URL,
+	}, httpCli)
+
+	resp, err := stripeSvc.UpdateResource("cus_LY6gsj", "3f2a9c", map[string]interface{}{
+		"name":              "Jenny Rosen",
+		"balance":           float64(1099),
+		"metadata":          map[string]interface{}{"tenant": "acme"},
+		"preferred_locales": []interface{}{"en", "de"},
+		"invoice_settings":  map[string]interface{}{"footer": nil},
+	})
+	is.NoErr(err)
+	is.Equal(resp[models.KeyID], "cus_LY6gsj")
+}
+
+func TestStripe_Expand(t *testing.T) {
+	is := is.New(t)
+
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		is.Equal(r.Method, nethttp.MethodGet)
+
+		switch r.URL.Path {
+		case "/v1/customers":
+			want := []string{"data.default_source", "data.test_clock"}
+			// the list of the parent objects is not expanded
+			if r.URL.Query().Get(startingAfterKey) == "cus_LY6gsj" {
+				want = nil
+			}
+
+			is.Equal(r.URL.Query()[expandKey], want)
+
+			_, _ = w.Write([]byte(`{"data":[],"has_more":false}`))
+		case "/v1/customers/cus_LY6gsj":
+			is.Equal(r.URL.Query()[expandKey], []string{"default_source", "test_clock"})
+
+			_, _ = w.Write([]byte(`{"id":"cus_LY6gsj","default_source":{"id":"card_1LajCF"}}`))
+		case "/v1/products":
+			// the expansions of the other resources are not sent
+			is.Equal(len(r.URL.Query()[expandKey]), 0)
+
+			_, _ = w.Write([]byte(`{"data":[],"has_more":false}`))
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	httpCli := http.NewClient(context.Background(), models.TestModeRequestsPerSecond)
+	defer httpCli.Close()
+
+	stripeSvc := New(config.Config{
+		SecretKey: testSecretKey,
+		BatchSize: 10,
+		BaseURL:   server.URL,
+		Expand:    map[string]string{resources.CustomerResource: "default_source,test_clock"},
+	}, httpCli)
+
+	_, err := stripeSvc.GetResource(resources.CustomerResource, "")
+	is.NoErr(err)
+
+	_, err = stripeSvc.GetResource(resources.ProductResource, "")
+	is.NoErr(err)
+
+	_, err = stripeSvc.ListResource(resources.CustomerResource, models.ListParams{
+		StartingAfter: "cus_LY6gsj",
+		WithoutExpand: true,
+	})
+	is.NoErr(err)
+
+	object, retrieved, err := stripeSvc.ExpandObject(resources.CustomerResource, map[string]interface{}{
+		models.KeyID:     "cus_LY6gsj",
+		"default_source": "card_1LajCF",
+	})
+	is.NoErr(err)
+	is.True(retrieved)
+	is.Equal(object["default_source"], map[string]interface{}{models.KeyID: "card_1LajCF"})
+
+	// the objects of the resources without expansions are not retrieved
+	product := map[string]interface{}{models.KeyID: "prod_LY6gsj"}
+
+	object, retrieved, err = stripeSvc.ExpandObject(resources.ProductResource, product)
+	is.NoErr(err)
+	is.True(!retrieved)
+	is.Equal(object, product)
+}
+
+func TestStripe_EventExists(t *testing.T) {
+	is := is.New(t)
+
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		is.Equal(r.Method, nethttp.MethodGet)
+
+		switch r.URL.Path {
+		case "/v1/events/evt_1652447179":
+			_, _ = w.Write([]byte(`{"id":"evt_1652447179"}`))
+		case "/v1/events/evt_1652447199", "/v1/events":
+			w.WriteHeader(nethttp.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":{"code":"resource_missing","message":"No such event"}}`))
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	httpCli := http.NewClient(context.Background(), models.TestModeRequestsPerSecond)
+	defer httpCli.Close()
+
+	stripeSvc := New(config.Config{
+		SecretKey:    testSecretKey,
+		ResourceName: resources.CustomerResource,
+		BatchSize:    10,
+		BaseURL:      server.URL,
+	}, httpCli)
+
+	exists, err := stripeSvc.EventExists("evt_1652447179")
+	is.NoErr(err)
+	is.True(exists)
+
+	exists, err = stripeSvc.EventExists("evt_1652447199")
+	is.NoErr(err)
+	is.True(!exists)
+
+	_, err = stripeSvc.GetEvent(1652790765, "", "evt_1652447199")
+	is.True(errors.Is(err, ErrEventMissing))
+}
+
+func TestStripe_ListResource(t *testing.T) {
+	is := is.New(t)
+
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		is.Equal(r.URL.Path, "/v1/charges")
+		is.Equal(r.URL.Query().Get(startingAfterKey), "ch_1LajCF")
+		is.Equal(r.URL.Query().Get(createdGTEKey), "1651153850")
+		is.Equal(r.URL.Query().Get(createdLTKey), "1651153903")
+		is.Equal(r.URL.Query().Get("status"), "paid")
+
+		_, _ = w.Write([]byte(`{"data":[],"has_more":false}`))
+	}))
+	defer server.Close()
+
+	httpCli := http.NewClient(context.Background(), models.TestModeRequestsPerSecond)
+	defer httpCli.Close()
+
+	stripeSvc := New(config.Config{
+		SecretKey: testSecretKey,
+		BatchSize: 10,
+		BaseURL:   server.URL,
+	}, httpCli)
+
+	_, err := stripeSvc.ListResource(resources.ChargeResource, models.ListParams{
+		StartingAfter: "ch_1LajCF",
+		CreatedGTE:    1651153850,
+		CreatedLT:     1651153903,
+		Filters:       map[string]string{"status": "paid"},
+	})
+	is.NoErr(err)
+}
+
+func TestStripe_ListResourceChild(t *testing.T) {
+	is := is.New(t)
+
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		is.Equal(r.URL.Path, "/v1/customers/cus_LY6gsj/tax_ids")
+		is.Equal(r.URL.Query().Get(startingAfterKey), "txi_1")
+
+		_, _ = w.Write([]byte(`{"data":[],"has_more":false}`))
+	}))
+	defer server.Close()
+
+	httpCli := http.NewClient(context.Background(), models.TestModeRequestsPerSecond)
+	defer httpCli.Close()
+
+	stripeSvc := New(config.Config{
+		SecretKey: testSecretKey,
+		BatchSize: 10,
+		BaseURL:   server.URL,
+	}, httpCli)
+
+	_, err := stripeSvc.ListResource(resources.TaxIDResource, models.ListParams{
+		ParentID:      "cus_LY6gsj",
+		StartingAfter: "txi_1",
+	})
+	is.NoErr(err)
+}
+
+func TestStripe_ListResourceTreasury(t *testing.T) {
+	is := is.New(t)
+
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		is.Equal(r.URL.Path, "/v1/treasury/transactions")
+		is.Equal(r.URL.Query().Get("financial_account"), "fa_1LajCFJit566F2Yt")
+		is.Equal(r.URL.Query().Get(createdGTEKey), "1651153850")
+
+		_, _ = w.Write([]byte(`{"data":[],"has_more":false}`))
+	}))
+	defer server.Close()
+
+	httpCli := http.NewClient(context.Background(), models.TestModeRequestsPerSecond)
+	defer httpCli.Close()
+
+	stripeSvc := New(config.Config{
+		SecretKey: testSecretKey,
+		BatchSize: 10,
+		BaseURL:   server.URL,
+	}, httpCli)
+
+	_, err := stripeSvc.ListResource(resources.TreasuryTransactionResource, models.ListParams{
+		CreatedGTE: 1651153850,
+		Filters:    map[string]string{"financial_account": "fa_1LajCFJit566F2Yt"},
+	})
+	is.NoErr(err)
+}
+
+func TestStripe_SearchResource(t *testing.T) {
+	is := is.New(t)
+
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		is.Equal(r.URL.Path, "/v1/customers/search")
+		is.Equal(r.URL.Query().Get(queryKey), "metadata['tenant']:'acme'")
+		is.Equal(r.URL.Query().Get(pageKey), "page_2")
+		is.Equal(r.URL.Query().Get(batchSize), "10")
+
+		_, _ = w.Write([]byte(`{"data":[{"id":"cus_LY6gsj"}],"has_more":true,"next_page":"page_3"}`))
+	}))
+	defer server.Close()
+
+	httpCli := http.NewClient(context.Background(), models.TestModeRequestsPerSecond)
+	defer httpCli.Close()
+
+	stripeSvc := New(config.Config{
+		SecretKey: testSecretKey,
+		BatchSize: 10,
+		BaseURL:   server.URL,
+	}, httpCli)
+
+	resp, err := stripeSvc.SearchResource(resources.CustomerResource, "metadata['tenant']:'acme'", "page_2")
+	is.NoErr(err)
+	is.True(resp.HasMore)
+	is.Equal(resp.NextPage, "page_3")
+	is.Equal(resp.Data, []map[string]interface{}{{models.KeyID: "cus_LY6gsj"}})
+}
+
+func TestStripe_ListNested(t *testing.T) {
+	is := is.New(t)
+
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		is.Equal(r.URL.Path, "/v1/subscription_items")
+		is.Equal(r.URL.Query().Get("subscription"), "sub_1")
+		is.Equal(r.URL.Query().Get(startingAfterKey), "si_1")
+		is.Equal(r.URL.Query().Get(batchSize), "10")
+
+		_, _ = w.Write([]byte(`{"data":[{"id":"si_2"}],"has_more":false}`))
+	}))
+	defer server.Close()
+
+	httpCli := http.NewClient(context.Background(), models.TestModeRequestsPerSecond)
+	defer httpCli.Close()
+
+	stripeSvc := New(config.Config{
+		SecretKey: testSecretKey,
+		BatchSize: 10,
+		BaseURL:   server.URL,
+	}, httpCli)
+
+	resp, err := stripeSvc.ListNested("/subscription_items?subscription=sub_1", "si_1")
+	is.NoErr(err)
+	is.True(!resp.HasMore)
+	is.Equal(resp.Data, []map[string]interface{}{{models.KeyID: "si_2"}})
+}
+
+func TestStripe_EventTypes(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.Config
+		want []string
+	}{
+		{
+			name: "resources",
+			cfg:  config.Config{ResourceNames: []string{resources.CustomerResource, resources.FileResource}},
+			want: []string{
+				resources.CustomerCreatedEvent,
+				resources.CustomerDeletedEvent,
+				resources.CustomerUpdatedEvent,
+				resources.FileCreatedEvent,
+			},
+		},
+		{
+			name: "event_types",
+			cfg:  config.Config{ResourceName: resources.EventResource, EventTypes: []string{"invoice.paid"}},
+			want: []string{"invoice.paid"},
+		},
+		{
+			name: "event_type_patterns",
+			cfg:  config.Config{ResourceName: resources.EventResource, EventTypes: []string{"invoice.paid", "*.failed"}},
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := is.New(t)
+
+			is.Equal(Stripe{cfg: tt.cfg}.eventTypes(), tt.want)
+		})
+	}
+}
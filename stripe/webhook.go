@@ -0,0 +1,98 @@
+// Copyright © 2022 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stripe
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// SignatureHeader is the header of the webhook request which contains the Stripe signature.
+	SignatureHeader = "Stripe-Signature"
+
+	signatureTimestampKey = "t"
+	signatureSchemeKey    = "v1"
+	signedPayloadFmt      = "%d.%s"
+)
+
+var (
+	// ErrInvalidSignatureHeader occurs when the signature header cannot be parsed.
+	ErrInvalidSignatureHeader = errors.New("invalid signature header")
+	// ErrNoValidSignature occurs when none of the signatures in the header matches the payload.
+	ErrNoValidSignature = errors.New("no valid signature found")
+	// ErrTimestampOutOfTolerance occurs when the timestamp of the signature is outside the tolerance.
+	ErrTimestampOutOfTolerance = errors.New("timestamp is outside the tolerance")
+)
+
+// VerifySignature verifies the `Stripe-Signature` header of the webhook payload,
+// which contains a timestamp and HMAC-SHA256 signatures of the payload computed with the signing secret.
+func VerifySignature(payload []byte, header, secret string, tolerance time.Duration, now time.Time) error {
+	var (
+		timestamp  int64
+		signatures [][]byte
+	)
+
+	for _, pair := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return ErrInvalidSignatureHeader
+		}
+
+		switch key {
+		case signatureTimestampKey:
+			t, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return ErrInvalidSignatureHeader
+			}
+
+			timestamp = t
+		case signatureSchemeKey:
+			signature, err := hex.DecodeString(value)
+			if err != nil {
+				// Stripe may add signatures of other formats, so they are skipped
+				continue
+			}
+
+			signatures = append(signatures, signature)
+		}
+	}
+
+	if timestamp == 0 || len(signatures) == 0 {
+		return ErrInvalidSignatureHeader
+	}
+
+	if diff := now.Sub(time.Unix(timestamp, 0)); diff > tolerance || diff < -tolerance {
+		return ErrTimestampOutOfTolerance
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, signedPayloadFmt, timestamp, payload)
+	expected := mac.Sum(nil)
+
+	for i := range signatures {
+		if hmac.Equal(expected, signatures[i]) {
+			return nil
+		}
+	}
+
+	return ErrNoValidSignature
+}